@@ -1,5 +1,16 @@
 package cmds
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// ErrArgumentNotSet is returned by the typed argument accessors of Request
+// when the argument wasn't given.
+var ErrArgumentNotSet = errors.New("cmds: argument not set")
+
 type ArgumentType int
 
 const (
@@ -12,9 +23,14 @@ type Argument struct {
 	Type          ArgumentType
 	Required      bool // error if no value is specified
 	Variadic      bool // unlimited values can be specfied
+	MinCount      int  // minimum number of values for a variadic argument
 	SupportsStdin bool // can accept stdin as a value
 	Recursive     bool // supports recursive file adding (with '-r' flag)
 	Description   string
+
+	// ValueType is the type the values of a string argument are converted
+	// to: String (the default), Int or Bool.
+	ValueType reflect.Kind
 }
 
 func StringArg(name string, required, variadic bool, description string) Argument {
@@ -46,6 +62,35 @@ func (a Argument) EnableStdin() Argument {
 	return a
 }
 
+// WithMinCount requires at least n values for a variadic argument.
+func (a Argument) WithMinCount(n int) Argument {
+	if !a.Variadic {
+		panic("Only variadic arguments can have a minimum count")
+	}
+
+	a.MinCount = n
+	return a
+}
+
+// WithValueType sets the type the values of a string argument are checked
+// against, which must be String, Int or Bool.
+func (a Argument) WithValueType(t reflect.Kind) Argument {
+	if a.Type != ArgString {
+		panic("Only StringArgs can have a value type")
+	}
+	switch t {
+	case String, Int, Bool:
+	default:
+		panic("Unsupported argument value type " + t.String())
+	}
+
+	a.ValueType = t
+	return a
+}
+
+// EnableRecursive allows directories to be passed to a file argument when the
+// recursive option is set. Symlinks inside a directory are passed on as
+// symlinks rather than followed, so cycles can't cause infinite recursion.
 func (a Argument) EnableRecursive() Argument {
 	if a.Type != ArgFile {
 		panic("Only FileArgs can enable recursive")
@@ -54,3 +99,71 @@ func (a Argument) EnableRecursive() Argument {
 	a.Recursive = true
 	return a
 }
+
+// ArgError is returned when the value of an argument can't be converted to
+// the value type of the argument.
+type ArgError struct {
+	// Name is the name of the argument.
+	Name string
+	// Position is the position of the value among the string arguments of
+	// the request, starting at 1.
+	Position int
+	// Value is the value that couldn't be converted.
+	Value string
+	// Type is the value type of the argument.
+	Type reflect.Kind
+	// Err is the cause, strconv.ErrSyntax or strconv.ErrRange.
+	Err error
+}
+
+func (e *ArgError) Error() string {
+	var reason string
+	switch {
+	case errors.Is(e.Err, strconv.ErrRange):
+		reason = "is out of range"
+	case e.Type == Int:
+		reason = "is not an integer"
+	case e.Type == Bool:
+		reason = "is not a boolean"
+	default:
+		reason = "is invalid"
+	}
+	return fmt.Sprintf("error in argument %d (%s): %q %s", e.Position, e.Name, e.Value, reason)
+}
+
+func (e *ArgError) Unwrap() error {
+	return e.Err
+}
+
+// convertArgument converts value, the value at position pos, to the value
+// type of argDef.
+func convertArgument(argDef Argument, pos int, value string) (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch argDef.ValueType {
+	case Int:
+		var i int64
+		i, err = strconv.ParseInt(value, 0, 0)
+		v = int(i)
+	case Bool:
+		v, err = strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			err = numErr.Err
+		}
+		return nil, &ArgError{
+			Name:     argDef.Name,
+			Position: pos,
+			Value:    value,
+			Type:     argDef.ValueType,
+			Err:      err,
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,131 @@
+package cmds
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Result is the outcome of a request run by RunBatch.
+type Result struct {
+	// Values are the values emitted by the command, in order.
+	Values []interface{}
+
+	// Err is the error the command failed with, if any.
+	Err error
+}
+
+// RunBatch runs reqs in the current process, at most concurrency at a time,
+// and returns their results in the order of reqs. The commands are called
+// with a nil Environment.
+//
+// Once ctx is canceled no more requests are started and the ones that weren't
+// fail with the error of ctx. The contexts of the running requests are
+// canceled along with ctx.
+func RunBatch(ctx context.Context, reqs []*Request, concurrency int) []Result {
+	return RunBatchEnv(ctx, reqs, nil, concurrency)
+}
+
+// RunBatchEnv is like RunBatch, but calls the commands with env.
+func RunBatchEnv(ctx context.Context, reqs []*Request, env Environment, concurrency int) []Result {
+	return runBatch(ctx, reqs, nil, env, concurrency)
+}
+
+// RunBatchWith is like RunBatchEnv, but runs the requests with x rather than
+// calling the commands in the current process.
+func RunBatchWith(ctx context.Context, reqs []*Request, x Executor, env Environment, concurrency int) []Result {
+	return runBatch(ctx, reqs, x, env, concurrency)
+}
+
+// runBatch runs reqs with x, or with an executor for the root of each request
+// if x is nil.
+func runBatch(ctx context.Context, reqs []*Request, x Executor, env Environment, concurrency int) []Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		results = make([]Result, len(reqs))
+		next    = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = runBatched(ctx, reqs[i], x, env)
+			}
+		}()
+	}
+
+	i := 0
+dispatch:
+	for ; i < len(reqs); i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	for ; i < len(reqs); i++ {
+		results[i].Err = ctx.Err()
+	}
+	return results
+}
+
+// runBatched runs a request of RunBatch and collects its result.
+func runBatched(ctx context.Context, req *Request, x Executor, env Environment) Result {
+	if err := ctx.Err(); err != nil {
+		return Result{Err: err}
+	}
+
+	// run a clone so the request passed in keeps its context
+	r := req.Clone()
+	if r.Context == nil {
+		r.Context = ctx
+	} else {
+		rctx, cancel := context.WithCancel(r.Context)
+		defer cancel()
+		stop := context.AfterFunc(ctx, cancel)
+		defer stop()
+		r.Context = rctx
+	}
+
+	var (
+		result Result
+		done   = make(chan struct{})
+	)
+	re, res := NewChanResponsePair(r)
+	go func() {
+		defer close(done)
+		for {
+			v, err := res.Next()
+			if err != nil {
+				if err != io.EOF {
+					result.Err = err
+				}
+				return
+			}
+			result.Values = append(result.Values, v)
+		}
+	}()
+
+	if x == nil {
+		x = NewExecutor(r.Root)
+	}
+	err := x.Execute(r, re, env)
+	if err != nil {
+		// errors returned before Run leave the emitter open
+		re.CloseWithError(err)
+	}
+	<-done
+
+	if result.Err == nil {
+		result.Err = err
+	}
+	return result
+}
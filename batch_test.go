@@ -0,0 +1,115 @@
+package cmds
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBatch(t *testing.T) {
+	var running, maxRunning int32
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"echo": {
+				Arguments: []Argument{
+					StringArg("n", true, false, "the number to echo"),
+				},
+				Run: func(req *Request, re ResponseEmitter, env Environment) error {
+					n := atomic.AddInt32(&running, 1)
+					defer atomic.AddInt32(&running, -1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+							break
+						}
+					}
+
+					i, err := strconv.Atoi(req.Arguments[0])
+					if err != nil {
+						return err
+					}
+					// later requests finish first
+					time.Sleep(time.Duration(20-i) * time.Millisecond)
+					if i%5 == 4 {
+						return errors.New("failed " + req.Arguments[0])
+					}
+					return re.Emit(i)
+				},
+			},
+		},
+	}
+
+	reqs := make([]*Request, 20)
+	for i := range reqs {
+		req, err := NewRequest(context.Background(), []string{"echo"}, nil, []string{strconv.Itoa(i)}, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs[i] = req
+	}
+
+	results := RunBatch(context.Background(), reqs, 3)
+	if len(results) != len(reqs) {
+		t.Fatalf("expected %d results, got %d", len(reqs), len(results))
+	}
+	for i, res := range results {
+		if i%5 == 4 {
+			if res.Err == nil || res.Err.Error() != "failed "+strconv.Itoa(i) {
+				t.Errorf("result %d: expected the request to fail, got %v", i, res.Err)
+			}
+			continue
+		}
+		if res.Err != nil {
+			t.Errorf("result %d: %s", i, res.Err)
+		} else if len(res.Values) != 1 || res.Values[0] != i {
+			t.Errorf("result %d: expected [%d], got %v", i, i, res.Values)
+		}
+	}
+	if max := atomic.LoadInt32(&maxRunning); max > 3 {
+		t.Errorf("expected at most 3 requests at once, got %d", max)
+	}
+}
+
+func TestRunBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	root := &Command{
+		Run: func(req *Request, re ResponseEmitter, env Environment) error {
+			started <- struct{}{}
+			<-req.Context.Done()
+			return req.Context.Err()
+		},
+	}
+
+	reqs := make([]*Request, 4)
+	for i := range reqs {
+		req, err := NewRequest(context.Background(), nil, nil, nil, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs[i] = req
+	}
+
+	go func() {
+		<-started
+		<-started
+		cancel()
+	}()
+	results := RunBatch(ctx, reqs, 2)
+	for i, res := range results {
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("result %d: expected the request to be canceled, got %v", i, res.Err)
+		}
+	}
+	// the requests passed in keep their contexts
+	for i, req := range reqs {
+		if req.Context.Err() != nil {
+			t.Errorf("request %d: expected the context to be left alone", i)
+		}
+	}
+}
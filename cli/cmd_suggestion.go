@@ -50,7 +50,10 @@ func suggestUnknownCmd(args []string, root *cmds.Command) []string {
 	}
 
 	// Start with a simple strings.Contains check
-	for name := range root.Subcommands {
+	for name, sub := range root.Subcommands {
+		if sub.Hidden {
+			continue
+		}
 		if strings.Contains(arg, name) {
 			suggestions = append(suggestions, name)
 		}
@@ -61,7 +64,10 @@ func suggestUnknownCmd(args []string, root *cmds.Command) []string {
 		return suggestions
 	}
 
-	for name := range root.Subcommands {
+	for name, sub := range root.Subcommands {
+		if sub.Hidden {
+			continue
+		}
 		lev := levenshtein.DistanceForStrings([]rune(arg), []rune(name), options)
 		if lev <= MinLevenshtein {
 			sortableSuggestions = append(sortableSuggestions, &suggestion{name, lev})
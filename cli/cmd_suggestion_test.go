@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestUnknownCommandSuggestions(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat":    {},
+			"config": {},
+			"debug":  {Hidden: true},
+		},
+	}
+
+	for _, tc := range []struct {
+		args     words
+		expected string
+	}{
+		// names contained in the input
+		{args: words{"catfile"}, expected: "Unknown Command \"catfile\"\n\nDid you mean this?\n\n\tcat"},
+		// prefixes, as inserting is cheap
+		{args: words{"con"}, expected: "Unknown Command \"con\"\n\nDid you mean this?\n\n\tconfig"},
+		{args: words{"cnfig"}, expected: "Unknown Command \"cnfig\"\n\nDid you mean this?\n\n\tconfig"},
+		{args: words{"cot"}, expected: "Unknown Command \"cot\"\n\nDid you mean this?\n\n\tcat"},
+		// hidden commands are never suggested
+		{args: words{"debag"}, expected: "Unknown Command \"debag\"\n"},
+		{args: words{"debugger"}, expected: "Unknown Command \"debugger\"\n"},
+		{args: words{"xyzzy"}, expected: "Unknown Command \"xyzzy\"\n"},
+	} {
+		_, err := Parse(context.Background(), tc.args, nil, root)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("%v: expected error %q, got %v", tc.args, tc.expected, err)
+		}
+	}
+}
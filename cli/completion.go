@@ -0,0 +1,433 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const bashCompletionHeader = `# bash completion for %[1]s
+
+_%[2]s_words() {
+	subs=""
+	opts=""
+	vals=""
+	dynamic=""
+	case "$1" in
+`
+
+const bashCompletionFooter = `	esac
+}
+
+_%[2]s() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+	local path=%[3]s subs opts vals dynamic word i
+
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		word="${COMP_WORDS[i]}"
+		_%[2]s_words "${path}"
+		if [[ " ${subs} " == *" ${word} "* ]]; then
+			path="${path} ${word}"
+		fi
+	done
+
+	_%[2]s_words "${path}" "${prev}"
+	if [[ -n "${dynamic}" ]]; then
+		COMPREPLY=($(compgen -W "$(%[3]s %[4]s "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)" -- "${cur}"))
+	elif [[ -n "${vals}" ]]; then
+		COMPREPLY=($(compgen -W "${vals}" -- "${cur}"))
+	elif [[ "${cur}" == -* ]]; then
+		COMPREPLY=($(compgen -W "${opts}" -- "${cur}"))
+	else
+		COMPREPLY=($(compgen -W "${subs}" -- "${cur}"))
+	fi
+}
+
+complete -F _%[2]s %[3]s
+`
+
+// completeCmd is the hidden subcommand the completion scripts call for the
+// values of options with a CompleteFunc. It's passed the words following the
+// tool name up to the word being completed, which comes last and may be
+// empty.
+const completeCmd = "__complete"
+
+// BashCompletion writes a bash completion script for the command tree to out.
+//
+// The script completes subcommand names at every level of the tree and, for
+// words starting with a dash, the options registered on the command at that
+// level. The values of options with an enum are completed from the enum, those
+// of options with a CompleteFunc by calling the tool.
+func BashCompletion(rootName string, root *cmds.Command, out io.Writer) error {
+	fn := shellIdentifier(rootName)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, bashCompletionHeader, rootName, fn)
+	walkCompletion(rootName, root, func(path string, cmd *cmds.Command) {
+		fmt.Fprintf(&b, "\t%s)\n", shellQuote(path))
+		if subs := sortedSubcommands(cmd); len(subs) > 0 {
+			fmt.Fprintf(&b, "\t\tsubs=%s\n", shellQuote(strings.Join(subs, " ")))
+		}
+		if opts := optionFlags(cmd); len(opts) > 0 {
+			fmt.Fprintf(&b, "\t\topts=%s\n", shellQuote(strings.Join(opts, " ")))
+		}
+		if opts := completedOptions(cmd); len(opts) > 0 {
+			b.WriteString("\t\tcase \"$2\" in\n")
+			for _, opt := range opts {
+				flags := make([]string, len(opt.Names()))
+				for i, name := range opt.Names() {
+					flags[i] = shellQuote(optionFlag(name))
+				}
+				fmt.Fprintf(&b, "\t\t%s)\n", strings.Join(flags, "|"))
+				if enum := cmds.Details(opt).Enum(); len(enum) > 0 {
+					fmt.Fprintf(&b, "\t\t\tvals=%s\n", shellQuote(strings.Join(enum, " ")))
+				} else {
+					b.WriteString("\t\t\tdynamic=1\n")
+				}
+				b.WriteString("\t\t\t;;\n")
+			}
+			b.WriteString("\t\tesac\n")
+		}
+		b.WriteString("\t\t;;\n")
+	})
+	fmt.Fprintf(&b, bashCompletionFooter, rootName, fn, shellQuote(rootName), completeCmd)
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// walkCompletion calls fn for root and all of its subcommands that aren't
+// hidden, in sorted order, with the space separated command path starting at
+// rootName.
+func walkCompletion(rootName string, root *cmds.Command, fn func(string, *cmds.Command)) {
+	var walk func(path string, cmd *cmds.Command)
+	walk = func(path string, cmd *cmds.Command) {
+		fn(path, cmd)
+		for _, name := range sortedSubcommands(cmd) {
+			walk(path+" "+name, cmd.Subcommands[name])
+		}
+	}
+	walk(rootName, root)
+}
+
+// sortedSubcommands returns the sorted names of the subcommands of cmd that
+// aren't hidden.
+func sortedSubcommands(cmd *cmds.Command) []string {
+	names := make([]string, 0, len(cmd.Subcommands))
+	for name, sub := range cmd.Subcommands {
+		if !sub.Hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// optionFlags returns the flags of all options registered on cmd that aren't
+// hidden, e.g. "--recursive" and "-r".
+func optionFlags(cmd *cmds.Command) []string {
+	var flags []string
+	for _, opt := range visibleOptions(&helpConfig{}, cmd) {
+		for _, name := range opt.Names() {
+			flags = append(flags, optionFlag(name))
+		}
+	}
+	return flags
+}
+
+// completedOptions returns the options registered on cmd that aren't hidden
+// and have values to complete, through an enum or a CompleteFunc.
+func completedOptions(cmd *cmds.Command) []cmds.Option {
+	var opts []cmds.Option
+	for _, opt := range visibleOptions(&helpConfig{}, cmd) {
+		if opt.Type() != cmds.Bool && (len(cmds.Details(opt).Enum()) > 0 || cmds.Details(opt).CompleteFunc() != nil) {
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// completeCmdline returns the command line calling the tool to complete the
+// value of opt of the command at path, without the word being completed.
+func completeCmdline(path string, opt cmds.Option) string {
+	words := strings.Fields(path)
+	cmdline := []string{shellQuote(words[0]), completeCmd}
+	for _, word := range words[1:] {
+		cmdline = append(cmdline, shellQuote(word))
+	}
+	return strings.Join(append(cmdline, optionFlag(opt.Name())), " ")
+}
+
+// shellQuote quotes s as a single word for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellIdentifier turns name into something usable as a shell function name.
+func shellIdentifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// ZshCompletion writes a zsh completion script for the command tree to out.
+//
+// Every command gets its own completion function that completes its options,
+// with the option descriptions as hints, its arguments and, through
+// _describe, its subcommands. Option values are completed like in
+// BashCompletion.
+func ZshCompletion(rootName string, root *cmds.Command, out io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", rootName)
+	walkCompletion(rootName, root, func(path string, cmd *cmds.Command) {
+		b.WriteString("\n")
+		writeZshFunction(&b, path, cmd)
+	})
+	fmt.Fprintf(&b, "\n_%s \"$@\"\n", shellIdentifier(rootName))
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+func writeZshFunction(b *strings.Builder, path string, cmd *cmds.Command) {
+	subs := sortedSubcommands(cmd)
+
+	fmt.Fprintf(b, "_%s() {\n", shellIdentifier(path))
+	if len(subs) > 0 {
+		b.WriteString("\tlocal line state\n\n")
+		b.WriteString("\t_arguments -C")
+	} else {
+		b.WriteString("\t_arguments")
+	}
+
+	for _, opt := range visibleOptions(&helpConfig{}, cmd) {
+		names := opt.Names()
+		flags := make([]string, len(names))
+		for i, name := range names {
+			flags[i] = optionFlag(name)
+		}
+		exclusive := ""
+		if len(flags) > 1 {
+			exclusive = "(" + strings.Join(flags, " ") + ")"
+		}
+		for _, flag := range flags {
+			spec := flag
+			if opt.Type() != cmds.Bool {
+				if len(flag) == 2 {
+					spec += "+"
+				} else {
+					spec += "="
+				}
+			}
+			spec += "[" + zshEscape(opt.Description()) + "]"
+			if opt.Type() != cmds.Bool {
+				spec += ":" + zshEscape(opt.Name()) + ":"
+				if enum := cmds.Details(opt).Enum(); len(enum) > 0 {
+					spec += "(" + zshEscape(strings.Join(enum, " ")) + ")"
+				} else if cmds.Details(opt).CompleteFunc() != nil {
+					spec += zshEscape(`{compadd -- ${(f)"$(` + completeCmdline(path, opt) + ` "$PREFIX" 2>/dev/null)"}}`)
+				}
+			}
+			fmt.Fprintf(b, " \\\n\t\t'%s%s'", exclusive, spec)
+		}
+	}
+
+	if len(subs) > 0 {
+		b.WriteString(" \\\n\t\t'1: :->cmds' \\\n\t\t'*:: :->args'\n\n")
+		b.WriteString("\tcase $state in\n\tcmds)\n\t\tlocal -a subcmds\n\t\tsubcmds=(\n")
+		for _, name := range subs {
+			fmt.Fprintf(b, "\t\t\t'%s:%s'\n",
+				strings.ReplaceAll(zshEscape(name), ":", `\:`),
+				zshEscape(cmd.Subcommands[name].Helptext.Tagline))
+		}
+		b.WriteString("\t\t)\n\t\t_describe 'command' subcmds\n\t\t;;\n")
+		b.WriteString("\targs)\n\t\tcase $line[1] in\n")
+		for _, name := range subs {
+			fmt.Fprintf(b, "\t\t'%s')\n\t\t\t_%s\n\t\t\t;;\n", zshEscape(name), shellIdentifier(path+" "+name))
+		}
+		b.WriteString("\t\tesac\n\t\t;;\n\tesac\n")
+	} else {
+		for i, arg := range cmd.Arguments {
+			spec := fmt.Sprint(i + 1)
+			if arg.Variadic {
+				spec = "*"
+			}
+			spec += ":"
+			if !arg.Required {
+				spec += ":"
+			}
+			spec += zshEscape(arg.Name) + ":"
+			if arg.Type == cmds.ArgFile {
+				spec += "_files"
+			}
+			fmt.Fprintf(b, " \\\n\t\t'%s'", spec)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+}
+
+// zshEscape escapes s for use inside a single quoted _arguments spec.
+func zshEscape(s string) string {
+	return strings.NewReplacer(
+		`'`, `'\''`,
+		`[`, `\[`,
+		`]`, `\]`,
+	).Replace(s)
+}
+
+// FishCompletion writes fish completion commands for the command tree to out.
+//
+// Subcommands are only offered until one of them has been typed, while the
+// options of a command are offered once the command has been selected. Option
+// values are completed like in BashCompletion.
+func FishCompletion(rootName string, root *cmds.Command, out io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", rootName)
+	walkCompletion(rootName, root, func(path string, cmd *cmds.Command) {
+		seen := strings.Fields(path)[1:]
+
+		subs := sortedSubcommands(cmd)
+		subCond := fishSeen(seen)
+		if len(seen) == 0 {
+			subCond = "__fish_use_subcommand"
+		}
+		if len(subs) > 0 && len(seen) > 0 {
+			subCond += "; and not __fish_seen_subcommand_from " + strings.Join(subs, " ")
+		}
+		for _, name := range subs {
+			fmt.Fprintf(&b, "complete -c %s -f -n %s -a %s -d %s\n",
+				rootName, fishQuote(subCond), fishQuote(name),
+				fishQuote(cmd.Subcommands[name].Helptext.Tagline))
+		}
+
+		for _, opt := range visibleOptions(&helpConfig{}, cmd) {
+			line := "complete -c " + rootName
+			if len(seen) > 0 {
+				line += " -n " + fishQuote(fishSeen(seen))
+			}
+			for _, name := range sortByLength(opt.Names()) {
+				if len(name) == 1 {
+					line += " -s " + fishQuote(name)
+				} else {
+					line += " -l " + fishQuote(name)
+				}
+			}
+			if opt.Type() != cmds.Bool {
+				line += " -r"
+				if enum := cmds.Details(opt).Enum(); len(enum) > 0 {
+					line += " -f -a " + fishQuote(strings.Join(enum, " "))
+				} else if cmds.Details(opt).CompleteFunc() != nil {
+					line += " -f -a " + fishQuote("("+completeCmdline(path, opt)+" (commandline -ct))")
+				}
+			}
+			if desc := opt.Description(); desc != "" {
+				line += " -d " + fishQuote(desc)
+			}
+			b.WriteString(line + "\n")
+		}
+	})
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// fishSeen returns a fish condition that is true once all of the given
+// subcommands have been typed.
+func fishSeen(path []string) string {
+	conds := make([]string, len(path))
+	for i, name := range path {
+		conds[i] = "__fish_seen_subcommand_from " + name
+	}
+	return strings.Join(conds, "; and ")
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// Complete writes the candidates for the last of words, the word being
+// completed, to out, one per line. The other words are the ones following the
+// tool name, and select the command whose options and subcommands are
+// offered. Words following an option that takes a value are completed from the
+// enum or the CompleteFunc of the option. Hidden commands and options are
+// never offered.
+//
+// Run calls Complete for the hidden __complete subcommand used by the
+// completion scripts.
+func Complete(root *cmds.Command, words []string, out io.Writer) error {
+	cur := ""
+	if len(words) > 0 {
+		cur = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	cmd := root
+	var valueOpt cmds.Option
+	argsSeen := false
+	for _, word := range words {
+		switch {
+		case valueOpt != nil:
+			valueOpt = nil
+		case argsSeen:
+		case word == "--":
+			argsSeen = true
+		case strings.HasPrefix(word, "-"):
+			if opt := findFlag(cmd, word); opt != nil && opt.Type() != cmds.Bool {
+				valueOpt = opt
+			}
+		default:
+			if sub, ok := cmd.Subcommands[word]; ok && !sub.Hidden {
+				cmd = sub
+			} else {
+				argsSeen = true
+			}
+		}
+	}
+
+	var candidates []string
+	switch {
+	case valueOpt != nil:
+		if enum := cmds.Details(valueOpt).Enum(); len(enum) > 0 {
+			candidates = enum
+		} else if fn := cmds.Details(valueOpt).CompleteFunc(); fn != nil {
+			candidates = fn(cur)
+		}
+	case argsSeen:
+	case strings.HasPrefix(cur, "-"):
+		candidates = optionFlags(cmd)
+	default:
+		candidates = sortedSubcommands(cmd)
+	}
+
+	var b strings.Builder
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			b.WriteString(c + "\n")
+		}
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// findFlag returns the option of cmd that isn't hidden and is named by flag,
+// e.g. "--recursive" or "-r", or nil if there's none. Flags with an inline
+// value never take the next word, so they aren't matched.
+func findFlag(cmd *cmds.Command, flag string) cmds.Option {
+	for _, opt := range visibleOptions(&helpConfig{}, cmd) {
+		for _, name := range opt.Names() {
+			if optionFlag(name) == flag {
+				return opt
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var completionRoot = &cmds.Command{
+	Options: []cmds.Option{
+		cmds.BoolOption("help", "h", "Show the help."),
+	},
+	Subcommands: map[string]*cmds.Command{
+		"config": {
+			Helptext: cmds.HelpText{Tagline: "Manage the config."},
+			Options: []cmds.Option{
+				cmds.BoolOption("json", "Output JSON."),
+				cmds.StringOption("format", "f", "Output format.").WithEnum("json", "text", "xml"),
+			},
+			Subcommands: map[string]*cmds.Command{
+				"show": {Helptext: cmds.HelpText{Tagline: "Show the config."}},
+				"edit": {
+					Helptext: cmds.HelpText{Tagline: "Edit the config."},
+					Arguments: []cmds.Argument{
+						cmds.StringArg("editor", true, false, "Editor to use."),
+					},
+				},
+			},
+		},
+		"add": {
+			Helptext: cmds.HelpText{Tagline: "Add files."},
+			Options: []cmds.Option{
+				cmds.BoolOption("recursive", "r", "Add directories recursively."),
+				cmds.StringOption("pin", "Pin the result."),
+				cmds.StringOption("profile", "Profile to apply.").WithCompleteFunc(func(prefix string) []string {
+					return []string{"server", "test"}
+				}),
+			},
+		},
+	},
+}
+
+func TestBashCompletion(t *testing.T) {
+	var buf strings.Builder
+	if err := BashCompletion("my-tool", completionRoot, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "bash_completion.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(golden) {
+		t.Fatalf("generated script does not match golden file, got:\n%s", buf.String())
+	}
+}
+
+// hiddenRoot has hidden subcommands and options that completions must not
+// offer.
+var hiddenRoot = &cmds.Command{
+	Options: []cmds.Option{
+		cmds.BoolOption("debug-internals", "Dump internal state.").WithHidden(true),
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status": {
+			Helptext: cmds.HelpText{Tagline: "Show the status."},
+			Options: []cmds.Option{
+				cmds.BoolOption("verbose", "v", "Show more."),
+				cmds.BoolOption("trace-internals", "Trace internals.").WithHidden(true),
+			},
+		},
+		"secret": {
+			Helptext: cmds.HelpText{Tagline: "Not for users."},
+			Subcommands: map[string]*cmds.Command{
+				"dump": {},
+			},
+			Hidden: true,
+		},
+	},
+}
+
+func assertNoHidden(t *testing.T, script string) {
+	t.Helper()
+	for _, name := range []string{"debug-internals", "trace-internals", "secret", "dump"} {
+		if strings.Contains(script, name) {
+			t.Errorf("expected %q to be left out, got:\n%s", name, script)
+		}
+	}
+}
+
+func TestBashCompletionHidden(t *testing.T) {
+	var buf strings.Builder
+	if err := BashCompletion("it's", hiddenRoot, &buf); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+
+	assertNoHidden(t, script)
+	for _, line := range []string{
+		`	'it'\''s status')`,
+		`		opts='--verbose -v'`,
+		`	local path='it'\''s' subs opts vals dynamic word i`,
+	} {
+		if !strings.Contains(script, line+"\n") {
+			t.Errorf("expected line %q, got:\n%s", line, script)
+		}
+	}
+}
+
+func TestZshCompletion(t *testing.T) {
+	var first, second strings.Builder
+	if err := ZshCompletion("my-tool", completionRoot, &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := ZshCompletion("my-tool", completionRoot, &second); err != nil {
+		t.Fatal(err)
+	}
+	script := first.String()
+	t.Logf("script is:\n%s", script)
+
+	if script != second.String() {
+		t.Fatal("expected generating the script to be deterministic")
+	}
+	for _, want := range []string{
+		"#compdef my-tool\n",
+		"'(--help -h)--help[Show the help.]'",
+		"'--pin=[Pin the result.]:pin:'",
+		"'config:Manage the config.'",
+		"_describe 'command' subcmds",
+		"'1:editor:'",
+		"'(--format -f)-f+[Output format.]:format:(json text xml)'",
+		`:profile:{compadd -- ${(f)"$('\''my-tool'\'' __complete '\''add'\'' --profile "$PREFIX" 2>/dev/null)"}}'`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q", want)
+		}
+	}
+
+	zsh, err := exec.LookPath("zsh")
+	if err != nil {
+		t.Skip("zsh not available, skipping syntax check")
+	}
+	cmd := exec.Command(zsh, "-n")
+	cmd.Stdin = strings.NewReader(script)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("zsh failed to parse the script: %s\n%s", err, out)
+	}
+}
+
+func TestFishCompletion(t *testing.T) {
+	var buf strings.Builder
+	if err := FishCompletion("my-tool", completionRoot, &buf); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+	t.Logf("script is:\n%s", script)
+
+	for _, want := range []string{
+		"complete -c my-tool -s 'h' -l 'help' -d 'Show the help.'\n",
+		"complete -c my-tool -f -n '__fish_use_subcommand' -a 'config' -d 'Manage the config.'\n",
+		"complete -c my-tool -n '__fish_seen_subcommand_from add' -s 'r' -l 'recursive' -d 'Add directories recursively.'\n",
+		"complete -c my-tool -n '__fish_seen_subcommand_from add' -l 'pin' -r -d 'Pin the result.'\n",
+		"complete -c my-tool -f -n '__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from edit show' -a 'show' -d 'Show the config.'\n",
+		"complete -c my-tool -n '__fish_seen_subcommand_from config' -s 'f' -l 'format' -r -f -a 'json text xml' -d 'Output format.'\n",
+		"complete -c my-tool -n '__fish_seen_subcommand_from add' -l 'profile' -r -f -a '(\\'my-tool\\' __complete \\'add\\' --profile (commandline -ct))' -d 'Profile to apply.'\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q", want)
+		}
+	}
+}
+
+func TestZshCompletionHidden(t *testing.T) {
+	var buf strings.Builder
+	if err := ZshCompletion("my-tool", hiddenRoot, &buf); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+
+	assertNoHidden(t, script)
+	if !strings.Contains(script, "'status:Show the status.'") {
+		t.Errorf("expected the status command to be offered, got:\n%s", script)
+	}
+	if !strings.Contains(script, "--verbose[Show more.]") {
+		t.Errorf("expected the verbose option to be offered, got:\n%s", script)
+	}
+}
+
+func TestFishCompletionHidden(t *testing.T) {
+	var buf strings.Builder
+	if err := FishCompletion("my-tool", hiddenRoot, &buf); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+
+	assertNoHidden(t, script)
+	if !strings.Contains(script, "-l 'verbose'") {
+		t.Errorf("expected the verbose option to be offered, got:\n%s", script)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	for _, tc := range []struct {
+		root  *cmds.Command
+		words []string
+		want  []string
+	}{
+		{completionRoot, nil, []string{"add", "config"}},
+		{completionRoot, []string{""}, []string{"add", "config"}},
+		{completionRoot, []string{"c"}, []string{"config"}},
+		{completionRoot, []string{"-"}, []string{"--help", "-h"}},
+		{completionRoot, []string{"config", ""}, []string{"edit", "show"}},
+		{completionRoot, []string{"config", "--"}, []string{"--json", "--format"}},
+		{completionRoot, []string{"config", "--format", ""}, []string{"json", "text", "xml"}},
+		{completionRoot, []string{"config", "-f", "t"}, []string{"text"}},
+		{completionRoot, []string{"config", "--json", "s"}, []string{"show"}},
+		{completionRoot, []string{"config", "edit", ""}, nil},
+		{completionRoot, []string{"add", "--profile", ""}, []string{"server", "test"}},
+		{completionRoot, []string{"add", "-r", "--profile", "s"}, []string{"server"}},
+		{completionRoot, []string{"add", "file", "c"}, nil},
+		{completionRoot, []string{"add", "--", "-"}, nil},
+		{hiddenRoot, []string{""}, []string{"status"}},
+		{hiddenRoot, []string{"-"}, nil},
+		{hiddenRoot, []string{"status", "-"}, []string{"--verbose", "-v"}},
+		{hiddenRoot, []string{"secret", "d"}, nil},
+	} {
+		var buf strings.Builder
+		if err := Complete(tc.root, tc.words, &buf); err != nil {
+			t.Errorf("completing %q: %s", tc.words, err)
+			continue
+		}
+		got := strings.Fields(buf.String())
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("completing %q: expected %q, got %q", tc.words, tc.want, got)
+		}
+	}
+}
+
+func TestRunComplete(t *testing.T) {
+	devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+	stdout, err := os.CreateTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+
+	err = Run(
+		context.Background(),
+		completionRoot,
+		[]string{"my-tool", completeCmd, "config", "--format", "x"},
+		devnull, stdout, devnull,
+		func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
+			return nil, nil
+		},
+		func(req *cmds.Request, env interface{}) (cmds.Executor, error) {
+			return cmds.NewExecutor(req.Root), nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "xml\n" {
+		t.Fatalf("expected xml to be offered, got %q", out)
+	}
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// Config maps option names to the values used when the options are passed
+// neither on the command line nor through their environment variables.
+type Config map[string]interface{}
+
+// LoadConfig reads a Config from the JSON object in the file at path. A
+// missing file results in an empty Config.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Config{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep numbers as written so they are parsed like command line values
+	dec.UseNumber()
+
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+type configKey struct{}
+
+// WithConfig returns a copy of ctx that makes Parse and Run take option
+// values from cfg. Flags and environment variables take precedence over the
+// config, which takes precedence over the defaults of the options.
+func WithConfig(ctx context.Context, cfg Config) context.Context {
+	return context.WithValue(ctx, configKey{}, cfg)
+}
+
+// fillFromConfig sets the options that weren't passed on the command line or
+// through the environment from the config in the request context.
+func fillFromConfig(req *cmds.Request) error {
+	if req.Context == nil {
+		return nil
+	}
+	cfg, _ := req.Context.Value(configKey{}).(Config)
+	if len(cfg) == 0 {
+		return nil
+	}
+
+	optDefs, err := req.Root.GetOptions(req.Path)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(optDefs))
+	for name, opt := range optDefs {
+		if name == opt.Name() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+Outer:
+	for _, name := range names {
+		opt := optDefs[name]
+		for _, n := range opt.Names() {
+			if _, ok := req.Options[n]; ok {
+				continue Outer
+			}
+		}
+
+		cv, ok := cfg[name]
+		if !ok {
+			continue
+		}
+		v, err := configValue(opt, cv)
+		if err != nil {
+			return fmt.Errorf("invalid value %v for option %s in config: %w", cv, optionFlag(name), err)
+		}
+		req.Options[name] = v
+	}
+	return nil
+}
+
+// configValue converts a value decoded from a JSON config to the type of opt.
+func configValue(opt cmds.Option, v interface{}) (interface{}, error) {
+	switch opt.Type() {
+	case cmds.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool, got %s", jsonTypeName(v))
+		}
+		return b, nil
+	case cmds.Strings:
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected array, got %s", jsonTypeName(v))
+		}
+		values := make([]string, len(items))
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected array of strings, got %s at index %d", jsonTypeName(item), i)
+			}
+			values[i] = s
+		}
+		if err := cmds.Details(opt).Validate(values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	case cmds.StringMap:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected object, got %s", jsonTypeName(v))
+		}
+		values := make(map[string]string, len(obj))
+		for key, item := range obj {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected object of strings, got %s for %q", jsonTypeName(item), key)
+			}
+			values[key] = s
+		}
+		return values, nil
+	case cmds.String:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %s", jsonTypeName(v))
+		}
+		return opt.Parse(s)
+	default:
+		// numbers
+		switch v := v.(type) {
+		case json.Number:
+			return opt.Parse(v.String())
+		case string:
+			return opt.Parse(v)
+		default:
+			return nil, fmt.Errorf("expected %s, got %s", cmds.Details(opt).TypeName(), jsonTypeName(v))
+		}
+	}
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestConfigDefaults(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.IntOption("retries", "r", "number of retries").WithEnvVar("MYTOOL_RETRIES").WithDefault(1),
+			cmds.StringsOption("peer", "peers"),
+			cmds.DurationOption("wait", "how long to wait"),
+			cmds.BoolOption("quiet", "q", "write less output"),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"run": {},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"retries": 3, "peer": ["a", "b"], "wait": "30s", "other": "ignored"}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithConfig(context.Background(), cfg)
+
+	req, err := Parse(ctx, []string{"run"}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := cmds.OptMap{
+		"retries": 3,
+		"peer":    []string{"a", "b"},
+		"wait":    30 * time.Second,
+	}
+	for name, v := range expected {
+		if !reflect.DeepEqual(req.Options[name], v) {
+			t.Errorf("expected %s to be %v, got %v", name, v, req.Options[name])
+		}
+	}
+
+	req, err = Parse(ctx, []string{"run", "-r", "7"}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := req.Options["retries"]; v != 7 {
+		t.Errorf("expected the flag to override the config, got %v", v)
+	}
+
+	t.Setenv("MYTOOL_RETRIES", "5")
+	req, err = Parse(ctx, []string{"run"}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := req.Options["retries"]; v != 5 {
+		t.Errorf("expected the environment to override the config, got %v", v)
+	}
+
+	if cfg, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(cfg) != 0 {
+		t.Errorf("expected an empty config for a missing file, got %v %v", cfg, err)
+	}
+}
+
+func TestConfigTypeMismatch(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.IntOption("retries", "number of retries"),
+			cmds.BoolOption("quiet", "write less output"),
+		},
+	}
+
+	for _, tc := range []struct {
+		cfg Config
+		err string
+	}{
+		{cfg: Config{"retries": "many"}, err: `invalid value many for option --retries in config: strconv.ParseInt: parsing "many": invalid syntax`},
+		{cfg: Config{"retries": true}, err: `invalid value true for option --retries in config: expected int, got bool`},
+		{cfg: Config{"quiet": "yes"}, err: `invalid value yes for option --quiet in config: expected bool, got string`},
+	} {
+		_, err := Parse(WithConfig(context.Background(), tc.cfg), nil, nil, root)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%v: expected error %q, got %v", tc.cfg, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// HelpJSON writes the help of the command at path to out as JSON, using the
+// cmds.CommandHelp schema.
+func HelpJSON(rootName string, root *cmds.Command, path []string, out io.Writer) error {
+	help, err := cmds.NewCommandHelp(rootName, root, path)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(help)
+}
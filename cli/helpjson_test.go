@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestHelpJSON(t *testing.T) {
+	var buf strings.Builder
+	if err := HelpJSON("my-tool", completionRoot, []string{"config"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.String()), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if v := raw["schemaVersion"]; v != float64(cmds.HelpSchemaVersion) {
+		t.Fatalf("expected schemaVersion %d, got %v", cmds.HelpSchemaVersion, v)
+	}
+
+	var help cmds.CommandHelp
+	if err := json.Unmarshal([]byte(buf.String()), &help); err != nil {
+		t.Fatal(err)
+	}
+	expected := cmds.CommandHelp{
+		SchemaVersion: cmds.HelpSchemaVersion,
+		Path:          []string{"my-tool", "config"},
+		Tagline:       "Manage the config.",
+		Arguments:     []cmds.ArgumentHelp{},
+		Options: []cmds.OptionHelp{
+			{Names: []string{"json"}, Type: "bool", Description: "Output JSON."},
+			{Names: []string{"format", "f"}, Type: "string", Description: "Output format."},
+		},
+		Subcommands: []cmds.SubcommandHelp{
+			{Name: "edit", Tagline: "Edit the config."},
+			{Name: "show", Tagline: "Show the config."},
+		},
+	}
+	if !reflect.DeepEqual(help, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, help)
+	}
+
+	buf.Reset()
+	if err := HelpJSON("my-tool", completionRoot, []string{"config", "edit"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	help = cmds.CommandHelp{}
+	if err := json.Unmarshal([]byte(buf.String()), &help); err != nil {
+		t.Fatal(err)
+	}
+	if len(help.Arguments) != 1 || help.Arguments[0] != (cmds.ArgumentHelp{Name: "editor", Required: true, Description: "Editor to use."}) {
+		t.Fatalf("unexpected arguments: %#v", help.Arguments)
+	}
+
+	if err := HelpJSON("my-tool", completionRoot, []string{"nope"}, &buf); err == nil {
+		t.Fatal("expected an error for an unknown command")
+	}
+}
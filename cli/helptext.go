@@ -8,13 +8,16 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"unicode/utf8"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	runewidth "github.com/mattn/go-runewidth"
 	terminal "golang.org/x/term"
 )
 
 const (
 	defaultTerminalWidth = 80
+	defaultTaglineLimit  = 60
 	requiredArg          = "<%v>"
 	optionalArg          = "[<%v>]"
 	variadicArg          = "%v..."
@@ -25,6 +28,11 @@ const (
 	whitespace = "\r\n\t "
 
 	indentStr = "  "
+
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiCyan  = "\x1b[36m"
 )
 
 type helpFields struct {
@@ -41,7 +49,16 @@ type helpFields struct {
 	DeprecatedSubcommands   string
 	RemovedSubcommands      string
 	Description             string
+	Examples                string
+	SeeAlso                 string
 	MoreHelp                bool
+
+	color bool
+}
+
+// Header formats a section header, making it bold when color is enabled.
+func (f helpFields) Header(name string) string {
+	return colorize(f.color, ansiBold, name)
 }
 
 // TrimNewlines removes extra newlines from fields. This makes aligning
@@ -66,6 +83,8 @@ func (f *helpFields) TrimNewlines() {
 	f.DeprecatedSubcommands = strings.Trim(f.DeprecatedSubcommands, "\n")
 	f.RemovedSubcommands = strings.Trim(f.RemovedSubcommands, "\n")
 	f.Description = strings.Trim(f.Description, "\n")
+	f.Examples = strings.Trim(f.Examples, "\n")
+	f.SeeAlso = strings.Trim(f.SeeAlso, "\n")
 }
 
 // Indent adds whitespace the lines of fields.
@@ -87,91 +106,195 @@ func (f *helpFields) IndentAll() {
 	f.ExperimentalSubcommands = indent(f.ExperimentalSubcommands)
 	f.RemovedSubcommands = indent(f.RemovedSubcommands)
 	f.Description = indent(f.Description)
+	f.Examples = indent(f.Examples)
+	f.SeeAlso = indent(f.SeeAlso)
 }
 
 const longHelpFormat = `{{if .Warning}}WARNING: {{.Warning}}
 
-{{end}}USAGE
+{{end}}{{.Header "USAGE"}}
 {{.Usage}}
 
-{{if .Synopsis}}SYNOPSIS
+{{if .Synopsis}}{{.Header "SYNOPSIS"}}
 {{.Synopsis}}
 
-{{end}}{{if .Arguments}}ARGUMENTS
+{{end}}{{if .Arguments}}{{.Header "ARGUMENTS"}}
 
 {{.Arguments}}
 
-{{end}}{{if .Options}}OPTIONS
+{{end}}{{if .Options}}{{.Header "OPTIONS"}}
 
 {{.Options}}
 
-{{end}}{{if .Description}}DESCRIPTION
+{{end}}{{if .Description}}{{.Header "DESCRIPTION"}}
 
 {{.Description}}
 
-{{end}}{{if .Subcommands}}SUBCOMMANDS
+{{end}}{{if .Examples}}{{.Header "EXAMPLES"}}
+
+{{.Examples}}
+
+{{end}}{{if .Subcommands}}{{.Header "SUBCOMMANDS"}}
 {{.Subcommands}}
 
 {{.Indent}}For more information about each command, use:
 {{.Indent}}'{{.Path}} <subcmd> --help'
 
-{{end}}{{if .ExperimentalSubcommands}}EXPERIMENTAL SUBCOMMANDS
+{{end}}{{if .ExperimentalSubcommands}}{{.Header "EXPERIMENTAL SUBCOMMANDS"}}
 {{.ExperimentalSubcommands}}
 
-{{end}}{{if .DeprecatedSubcommands}}DEPRECATED SUBCOMMANDS
+{{end}}{{if .DeprecatedSubcommands}}{{.Header "DEPRECATED SUBCOMMANDS"}}
 {{.DeprecatedSubcommands}}
 
-{{end}}{{if .RemovedSubcommands}}REMOVED SUBCOMMANDS
+{{end}}{{if .RemovedSubcommands}}{{.Header "REMOVED SUBCOMMANDS"}}
 {{.RemovedSubcommands}}
 
+{{end}}{{if .SeeAlso}}{{.Header "SEE ALSO"}}
+{{.SeeAlso}}
+
 {{end}}
 `
 const shortHelpFormat = `{{if .Warning}}WARNING: {{.Warning}}
 
-{{end}}USAGE
+{{end}}{{.Header "USAGE"}}
 {{.Usage}}
 {{if .Synopsis}}
 {{.Synopsis}}
 {{end}}{{if .Description}}
 {{.Description}}
 {{end}}{{if .Subcommands}}
-SUBCOMMANDS
+{{.Header "SUBCOMMANDS"}}
 {{.Subcommands}}
 {{end}}{{if .MoreHelp}}
 {{.Indent}}For more information about each command, use:
 {{.Indent}}'{{.Path}} <subcmd> --help'
 
-{{end}}{{if .ExperimentalSubcommands}}EXPERIMENTAL SUBCOMMANDS
+{{end}}{{if .ExperimentalSubcommands}}{{.Header "EXPERIMENTAL SUBCOMMANDS"}}
 {{.ExperimentalSubcommands}}
 
-{{end}}{{if .DeprecatedSubcommands}}DEPRECATED SUBCOMMANDS
+{{end}}{{if .DeprecatedSubcommands}}{{.Header "DEPRECATED SUBCOMMANDS"}}
 {{.DeprecatedSubcommands}}
 
-{{end}}{{if .RemovedSubcommands}}REMOVED SUBCOMMANDS
+{{end}}{{if .RemovedSubcommands}}{{.Header "REMOVED SUBCOMMANDS"}}
 {{.RemovedSubcommands}}
 
 {{end}}
 `
+const usageHelpFormat = `{{.Usage}}
+
+Use '{{.Path}} --help' for more information.
+`
 
 var longHelpTemplate *template.Template
 var shortHelpTemplate *template.Template
+var usageHelpTemplate *template.Template
 
-func getTerminalWidth(out io.Writer) int {
+// helpConfig holds the settings applied by HelpOptions.
+type helpConfig struct {
+	width int
+
+	color    bool
+	colorSet bool
+
+	sortOptions bool
+	showHidden  bool
+
+	taglineLimit    int
+	taglineLimitSet bool
+}
+
+// HelpOption is an option that can be passed to LongHelp and ShortHelp.
+type HelpOption func(*helpConfig)
+
+// HelpWithWidth sets the column count the help text is wrapped to. By
+// default, the width of the terminal behind the output is used, falling back
+// to 80 columns if the output is not a terminal.
+func HelpWithWidth(width int) HelpOption {
+	return func(cfg *helpConfig) {
+		cfg.width = width
+	}
+}
+
+// HelpWithColor forces ANSI colors in the help text on or off. By default,
+// colors are only used when the output is a terminal.
+func HelpWithColor(enabled bool) HelpOption {
+	return func(cfg *helpConfig) {
+		cfg.color = enabled
+		cfg.colorSet = true
+	}
+}
+
+// HelpWithSortedOptions lists options sorted by their longest name instead of
+// the order they were registered in.
+func HelpWithSortedOptions(sorted bool) HelpOption {
+	return func(cfg *helpConfig) {
+		cfg.sortOptions = sorted
+	}
+}
+
+// HelpWithHidden includes hidden options and subcommands in the help text.
+// This is meant for developers of the command tree.
+func HelpWithHidden(show bool) HelpOption {
+	return func(cfg *helpConfig) {
+		cfg.showHidden = show
+	}
+}
+
+// HelpWithTaglineLimit sets the number of characters subcommand taglines are
+// truncated to in the SUBCOMMANDS section. The full tagline is still shown in
+// the help of the subcommand itself. A limit of zero or less disables the
+// truncation. Defaults to 60.
+func HelpWithTaglineLimit(limit int) HelpOption {
+	return func(cfg *helpConfig) {
+		cfg.taglineLimit = limit
+		cfg.taglineLimitSet = true
+	}
+}
+
+func newHelpConfig(out io.Writer, opts []HelpOption) *helpConfig {
+	cfg := &helpConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.width <= 0 {
+		cfg.width = getTerminalWidth(out)
+	}
+	if !cfg.colorSet {
+		cfg.color = isTerminal(out)
+	}
+	if !cfg.taglineLimitSet {
+		cfg.taglineLimit = defaultTaglineLimit
+	}
+	return cfg
+}
+
+func isTerminal(out io.Writer) bool {
 	file, ok := out.(*os.File)
-	if ok {
-		if terminal.IsTerminal(int(file.Fd())) {
-			width, _, err := terminal.GetSize(int(file.Fd()))
-			if err == nil {
-				return width
-			}
+	return ok && terminal.IsTerminal(int(file.Fd()))
+}
+
+func getTerminalWidth(out io.Writer) int {
+	if isTerminal(out) {
+		width, _, err := terminal.GetSize(int(out.(*os.File).Fd()))
+		if err == nil {
+			return width
 		}
 	}
 	return defaultTerminalWidth
 }
 
+// colorize wraps s in the given ANSI escape code if color is enabled.
+func colorize(color bool, code, s string) string {
+	if !color || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
 func init() {
 	longHelpTemplate = template.Must(template.New("longHelp").Parse(longHelpFormat))
 	shortHelpTemplate = template.Must(template.New("shortHelp").Parse(shortHelpFormat))
+	usageHelpTemplate = template.Must(template.New("usageHelp").Parse(usageHelpFormat))
 }
 
 // ErrNoHelpRequested returns when request for help help does not include the
@@ -193,8 +316,16 @@ func HandleHelp(appName string, req *cmds.Request, out io.Writer) error {
 	}
 }
 
+// HelpFunc returns a cmds.HelpFunc writing the LongHelp of the command of a
+// request, for cmds.NewExecutorWithHelp.
+func HelpFunc(appName string, opts ...HelpOption) cmds.HelpFunc {
+	return func(req *cmds.Request, w io.Writer) error {
+		return LongHelp(appName, req.Root, req.Path, w, opts...)
+	}
+}
+
 // LongHelp writes a formatted CLI helptext string to a Writer for the given command
-func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer) error {
+func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer, opts ...HelpOption) error {
 	cmd, err := root.Get(path)
 	if err != nil {
 		return err
@@ -218,11 +349,16 @@ func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer)
 		MoreHelp:    (cmd != root),
 	}
 
-	width := getTerminalWidth(out) - len(indentStr)
+	cfg := newHelpConfig(out, opts)
+	width := cfg.width - len(indentStr)
+	fields.color = cfg.color
 
 	if len(cmd.Helptext.LongDescription) > 0 {
 		fields.Description = cmd.Helptext.LongDescription
 	}
+	fields.Description = wrapLines(fields.Description, width)
+	fields.Examples = strings.Join(exampleText(width, cmd), "\n\n")
+	fields.SeeAlso = strings.Join(seeAlsoText(width, root, rootName, cmd), "\n")
 
 	// autogen fields that are empty
 	fields.Warning = generateWarningText(cmd)
@@ -235,16 +371,16 @@ func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer)
 		fields.Arguments = strings.Join(argumentText(width, cmd), "\n")
 	}
 	if len(fields.Options) == 0 {
-		fields.Options = strings.Join(optionText(width, cmd), "\n")
+		fields.Options = strings.Join(optionText(cfg, width, cmd), "\n")
 	}
 	if len(fields.Subcommands) == 0 {
-		fields.Subcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Active), "\n")
-		fields.ExperimentalSubcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Experimental), "\n")
-		fields.DeprecatedSubcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Deprecated), "\n")
-		fields.RemovedSubcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Removed), "\n")
+		fields.Subcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Active), "\n")
+		fields.ExperimentalSubcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Experimental), "\n")
+		fields.DeprecatedSubcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Deprecated), "\n")
+		fields.RemovedSubcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Removed), "\n")
 	}
 	if len(fields.Synopsis) == 0 {
-		fields.Synopsis = generateSynopsis(width, cmd, pathStr)
+		fields.Synopsis = generateSynopsis(cfg, width, cmd, pathStr)
 	}
 
 	// trim the extra newlines (see TrimNewlines doc)
@@ -253,11 +389,18 @@ func LongHelp(rootName string, root *cmds.Command, path []string, out io.Writer)
 	// indent all fields that have been set
 	fields.IndentAll()
 
-	return longHelpTemplate.Execute(out, fields)
+	tmpl := longHelpTemplate
+	if cmd.HelpTemplate != "" {
+		tmpl, err = template.New("longHelp").Parse(cmd.HelpTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid help template for %q: %w", pathStr, err)
+		}
+	}
+	return tmpl.Execute(out, fields)
 }
 
 // ShortHelp writes a formatted CLI helptext string to a Writer for the given command
-func ShortHelp(rootName string, root *cmds.Command, path []string, out io.Writer) error {
+func ShortHelp(rootName string, root *cmds.Command, path []string, out io.Writer, opts ...HelpOption) error {
 	cmd, err := root.Get(path)
 	if err != nil {
 		return err
@@ -283,7 +426,10 @@ func ShortHelp(rootName string, root *cmds.Command, path []string, out io.Writer
 		MoreHelp:    (cmd != root),
 	}
 
-	width := getTerminalWidth(out) - len(indentStr)
+	cfg := newHelpConfig(out, opts)
+	width := cfg.width - len(indentStr)
+	fields.color = cfg.color
+	fields.Description = wrapLines(fields.Description, width)
 
 	// autogen fields that are empty
 	fields.Warning = generateWarningText(cmd)
@@ -293,13 +439,13 @@ func ShortHelp(rootName string, root *cmds.Command, path []string, out io.Writer
 		fields.Usage = commandUsageText(width, cmd, rootName, path)
 	}
 	if len(fields.Subcommands) == 0 {
-		fields.Subcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Active), "\n")
-		fields.ExperimentalSubcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Experimental), "\n")
-		fields.DeprecatedSubcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Deprecated), "\n")
-		fields.RemovedSubcommands = strings.Join(subcommandText(width, cmd, rootName, path, cmds.Removed), "\n")
+		fields.Subcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Active), "\n")
+		fields.ExperimentalSubcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Experimental), "\n")
+		fields.DeprecatedSubcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Deprecated), "\n")
+		fields.RemovedSubcommands = strings.Join(subcommandText(cfg, width, cmd, rootName, path, cmds.Removed), "\n")
 	}
 	if len(fields.Synopsis) == 0 {
-		fields.Synopsis = generateSynopsis(width, cmd, pathStr)
+		fields.Synopsis = generateSynopsis(cfg, width, cmd, pathStr)
 	}
 
 	// trim the extra newlines (see TrimNewlines doc)
@@ -311,7 +457,36 @@ func ShortHelp(rootName string, root *cmds.Command, path []string, out io.Writer
 	return shortHelpTemplate.Execute(out, fields)
 }
 
-func generateSynopsis(width int, cmd *cmds.Command, path string) string {
+// UsageHelp writes only the usage line of the given command, followed by a
+// pointer to the full help. It is meant to be shown when the command was
+// invoked incorrectly, e.g. with the wrong number of arguments.
+func UsageHelp(rootName string, root *cmds.Command, path []string, out io.Writer, opts ...HelpOption) error {
+	cmd, err := root.Get(path)
+	if err != nil {
+		return err
+	}
+
+	pathStr := rootName
+	if len(path) > 0 {
+		pathStr += " " + strings.Join(path, " ")
+	}
+
+	cfg := newHelpConfig(out, opts)
+	width := cfg.width - len(indentStr)
+	fields := helpFields{
+		Path:  pathStr,
+		Usage: commandUsageText(width, cmd, rootName, path),
+	}
+	if len(cmd.Helptext.Usage) > 0 {
+		fields.Usage = cmd.Helptext.Usage
+	}
+
+	fields.TrimNewlines()
+
+	return usageHelpTemplate.Execute(out, fields)
+}
+
+func generateSynopsis(cfg *helpConfig, width int, cmd *cmds.Command, path string) string {
 	res := path
 	currentLineLength := len(res)
 	appendText := func(text string) {
@@ -322,7 +497,7 @@ func generateSynopsis(width int, cmd *cmds.Command, path string) string {
 		currentLineLength += len(text) + 1
 		res += " " + text
 	}
-	for _, opt := range cmd.Options {
+	for _, opt := range visibleOptions(cfg, cmd) {
 		valopt, ok := cmd.Helptext.SynopsisOptionsValues[opt.Name()]
 		if !ok {
 			valopt = opt.Name()
@@ -388,8 +563,44 @@ func argumentText(width int, cmd *cmds.Command) []string {
 	return lines
 }
 
+// exampleText returns the examples of cmd, each with the command line on the
+// first line and the wrapped description indented below it.
+func exampleText(width int, cmd *cmds.Command) []string {
+	examples := make([]string, len(cmd.Examples))
+	for i, ex := range cmd.Examples {
+		examples[i] = ex.Command
+		if ex.Description != "" {
+			examples[i] += "\n" + appendWrapped(indentStr, ex.Description, width)
+		}
+	}
+	return examples
+}
+
+// seeAlsoText returns the path and tagline of every command referenced by
+// cmd. References that don't resolve to a command are skipped.
+func seeAlsoText(width int, root *cmds.Command, rootName string, cmd *cmds.Command) []string {
+	var lines []string
+	var refs []*cmds.Command
+	for _, ref := range cmd.SeeAlso {
+		path := strings.Fields(ref)
+		other, err := root.Get(path)
+		if err != nil {
+			continue
+		}
+		lines = append(lines, strings.Join(append([]string{rootName}, path...), " "))
+		refs = append(refs, other)
+	}
+
+	lines = align(lines)
+	for i, other := range refs {
+		lines[i] += " - "
+		lines[i] = appendWrapped(lines[i], other.Helptext.Tagline, width)
+	}
+	return lines
+}
+
 func appendWrapped(prefix, text string, width int) string {
-	offset := len(prefix)
+	offset := runewidth.StringWidth(prefix)
 	bWidth := width - offset
 
 	text = strings.Trim(text, whitespace)
@@ -399,8 +610,8 @@ func appendWrapped(prefix, text string, width int) string {
 		return prefix
 	}
 
-	for len(text) > bWidth {
-		idx := strings.LastIndexAny(text[:bWidth], whitespace)
+	for runewidth.StringWidth(text) > bWidth {
+		idx := strings.LastIndexAny(runewidth.Truncate(text, bWidth, ""), whitespace)
 		if idx < 0 {
 			idx = strings.IndexAny(text, whitespace)
 		}
@@ -414,6 +625,21 @@ func appendWrapped(prefix, text string, width int) string {
 	return prefix
 }
 
+// wrapLines wraps every line of text that is longer than width. Continuation
+// lines keep the indentation of the line they were split from, so paragraph
+// breaks and indented blocks written by the command author are preserved.
+func wrapLines(text string, width int) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if runewidth.StringWidth(line) <= width {
+			continue
+		}
+		body := strings.TrimLeft(line, whitespace)
+		lines[i] = appendWrapped(line[:len(line)-len(body)], body, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func optionFlag(flag string) string {
 	if len(flag) == 1 {
 		return fmt.Sprintf(shortFlag, flag)
@@ -421,40 +647,164 @@ func optionFlag(flag string) string {
 	return fmt.Sprintf(longFlag, flag)
 }
 
-func optionText(width int, cmd ...*cmds.Command) []string {
+func optionText(cfg *helpConfig, width int, cmd ...*cmds.Command) []string {
 	// get a slice of the options we want to list out
 	options := make([]cmds.Option, 0)
 	for _, c := range cmd {
-		options = append(options, c.Options...)
+		options = append(options, visibleOptions(cfg, c)...)
+	}
+	if cfg.sortOptions {
+		sort.SliceStable(options, func(i, j int) bool {
+			return longestName(options[i]) < longestName(options[j])
+		})
+	}
+
+	// options without a group come first, followed by the groups in the
+	// order they first appear in
+	var groups []string
+	grouped := make(map[string][]cmds.Option)
+	for _, opt := range options {
+		group := cmds.Details(opt).Group()
+		if _, ok := grouped[group]; !ok && group != "" {
+			groups = append(groups, group)
+		}
+		grouped[group] = append(grouped[group], opt)
 	}
 
+	// flags of the options each option can't be combined with
+	conflicts := make(map[string][]string)
+	for _, c := range cmd {
+		for _, set := range c.MutuallyExclusive {
+			for _, name := range set {
+				for _, other := range set {
+					if other != name {
+						conflicts[name] = append(conflicts[name], optionFlag(other))
+					}
+				}
+			}
+		}
+	}
+
+	lines := optionLines(cfg, width, grouped[""], conflicts)
+	for _, group := range groups {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, colorize(cfg.color, ansiBold, group+":"))
+		for _, line := range optionLines(cfg, width-len(indentStr), grouped[group], conflicts) {
+			lines = append(lines, indentString(line, indentStr))
+		}
+	}
+
+	return lines
+}
+
+// optionLines returns the aligned help lines of options.
+func optionLines(cfg *helpConfig, width int, options []cmds.Option, conflicts map[string][]string) []string {
 	// add option names to output
 	lines := make([]string, len(options))
+	flagLens := make([]int, len(options))
 	for i, opt := range options {
 		flags := sortByLength(opt.Names())
 		for j, f := range flags {
 			flags[j] = optionFlag(f)
+			// point out that every long bool flag can be negated with --no-<flag>
+			if len(f) > 1 && opt.Type() == cmds.Bool {
+				flags[j] = fmt.Sprintf(longFlag, "[no-]"+f)
+			}
 		}
 		lines[i] = strings.Join(flags, ", ")
+		flagLens[i] = len(lines[i])
 	}
 	lines = align(lines)
 
 	// add option types to output
+	typeStarts := make([]int, len(options))
 	for i, opt := range options {
-		lines[i] += "  " + fmt.Sprintf("%v", opt.Type())
+		lines[i] += "  "
+		typeStarts[i] = len(lines[i])
+		lines[i] += cmds.Details(opt).TypeName()
+	}
+	lines = align(lines)
+
+	// add default values to output
+	for i, opt := range options {
+		if def := opt.Default(); def != nil {
+			if cmds.Details(opt).Secret() {
+				def = cmds.SecretMask
+			}
+			lines[i] += fmt.Sprintf(" (default: %v)", def)
+		}
 	}
 	lines = align(lines)
 
 	// add option descriptions to output
 	for i, opt := range options {
+		desc := cmds.Details(opt).RawDescription()
+		if cmds.Details(opt).Required() {
+			desc = strings.TrimSpace("(required) " + desc)
+		}
+		if aliases := cmds.Details(opt).Aliases(); len(aliases) > 0 {
+			flags := make([]string, len(aliases))
+			for j, a := range aliases {
+				flags[j] = optionFlag(a)
+			}
+			desc = strings.TrimSpace(fmt.Sprintf("(alias: %s) %s", strings.Join(flags, ", "), desc))
+		}
+		if enum := cmds.Details(opt).Enum(); len(enum) > 0 {
+			desc = strings.TrimSpace(fmt.Sprintf("%s (one of: %s)", desc, strings.Join(enum, ", ")))
+		}
+		for _, name := range opt.Names() {
+			if others := conflicts[name]; len(others) > 0 {
+				desc = strings.TrimSpace(fmt.Sprintf("%s (conflicts with %s)", desc, strings.Join(others, ", ")))
+				break
+			}
+		}
+		if env := cmds.Details(opt).EnvVar(); env != "" {
+			desc = strings.TrimSpace(fmt.Sprintf("%s [env: %s]", desc, env))
+		}
+		if msg := cmds.Details(opt).Deprecated(); msg != "" {
+			desc = strings.TrimSpace(fmt.Sprintf("%s (DEPRECATED: %s)", desc, msg))
+		}
 		lines[i] += " - "
-		lines[i] = appendWrapped(lines[i], opt.Description(), width)
+		lines[i] = appendWrapped(lines[i], desc, width)
+	}
+
+	// colors are added last so the escape codes don't disturb the alignment
+	if cfg.color {
+		for i, opt := range options {
+			line := lines[i]
+			typeEnd := typeStarts[i] + len(cmds.Details(opt).TypeName())
+			lines[i] = colorize(true, ansiCyan, line[:flagLens[i]]) +
+				line[flagLens[i]:typeStarts[i]] +
+				colorize(true, ansiDim, line[typeStarts[i]:typeEnd]) +
+				line[typeEnd:]
+		}
 	}
 
 	return lines
 }
 
-func subcommandText(width int, cmd *cmds.Command, rootName string, path []string, status cmds.Status) []string {
+// visibleOptions returns the options of cmd that should be shown in the help.
+func visibleOptions(cfg *helpConfig, cmd *cmds.Command) []cmds.Option {
+	if cfg.showHidden {
+		return cmd.Options
+	}
+	options := make([]cmds.Option, 0, len(cmd.Options))
+	for _, opt := range cmd.Options {
+		if !cmds.Details(opt).Hidden() {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+func longestName(opt cmds.Option) string {
+	names := sortByLength(opt.Names())
+	return names[len(names)-1]
+}
+
+func subcommandText(cfg *helpConfig, width int, cmd *cmds.Command, rootName string, path []string, status cmds.Status) []string {
 	prefix := fmt.Sprintf("%v %v", rootName, strings.Join(path, " "))
 	if len(path) > 0 {
 		prefix += " "
@@ -463,37 +813,99 @@ func subcommandText(width int, cmd *cmds.Command, rootName string, path []string
 	subCmds := make(map[string]*cmds.Command, len(cmd.Subcommands))
 	// Sorting fixes changing order bug #2981.
 	sortedNames := make([]string, 0)
+	categorized := false
 	for name, c := range cmd.Subcommands {
-		if c.Status == status {
+		if c.Status == status && (cfg.showHidden || !c.Hidden) {
 			sortedNames = append(sortedNames, name)
 			subCmds[name] = c
+			categorized = categorized || c.Category != ""
 		}
 	}
 	sort.Strings(sortedNames)
 
-	subcmds := make([]*cmds.Command, len(subCmds))
-	lines := make([]string, len(subCmds))
+	if !categorized {
+		return subcommandLines(cfg, width, prefix, sortedNames, subCmds)
+	}
 
-	for i, name := range sortedNames {
+	// list the categories in order, with the uncategorized commands last
+	var categories []string
+	byCategory := make(map[string][]string)
+	for _, name := range sortedNames {
+		category := subCmds[name].Category
+		if _, ok := byCategory[category]; !ok && category != "" {
+			categories = append(categories, category)
+		}
+		byCategory[category] = append(byCategory[category], name)
+	}
+	sort.Strings(categories)
+	if _, ok := byCategory[""]; ok {
+		categories = append(categories, "")
+	}
+
+	var lines []string
+	for _, category := range categories {
+		names := byCategory[category]
+		if category == "" {
+			category = "Other"
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, colorize(cfg.color, ansiBold, category+":"))
+		for _, line := range subcommandLines(cfg, width-len(indentStr), prefix, names, subCmds) {
+			lines = append(lines, indentString(line, indentStr))
+		}
+	}
+	return lines
+}
+
+// subcommandLines returns the aligned help lines of the named subcommands.
+func subcommandLines(cfg *helpConfig, width int, prefix string, names []string, subCmds map[string]*cmds.Command) []string {
+	subcmds := make([]*cmds.Command, len(names))
+	lines := make([]string, len(names))
+
+	for i, name := range names {
 		sub := subCmds[name]
 		usage := usageText(sub)
 		if len(usage) > 0 {
 			usage = " " + usage
 		}
 
-		lines[i] = prefix + name + usage
+		aliases := ""
+		if len(sub.Aliases) > 0 {
+			aliases = fmt.Sprintf(" (alias: %s)", strings.Join(sub.Aliases, ", "))
+		}
+
+		lines[i] = prefix + name + aliases + usage
 		subcmds[i] = sub
 	}
 
 	lines = align(lines)
 	for i, sub := range subcmds {
+		tagline := truncate(sub.Helptext.Tagline, cfg.taglineLimit)
+		if sub.Deprecated != "" {
+			tagline = strings.TrimSpace(fmt.Sprintf("%s (DEPRECATED: %s)", tagline, sub.Deprecated))
+		}
 		lines[i] += " - "
-		lines[i] = appendWrapped(lines[i], sub.Helptext.Tagline, width)
+		lines[i] = appendWrapped(lines[i], tagline, width)
 	}
 
 	return lines
 }
 
+// truncate shortens s to at most limit runes, ending it with an ellipsis if
+// anything was cut off.
+func truncate(s string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	const ellipsis = "..."
+	if limit <= len(ellipsis) {
+		return string([]rune(s)[:limit])
+	}
+	return strings.TrimRight(string([]rune(s)[:limit-len(ellipsis)]), whitespace) + ellipsis
+}
+
 // Text printed at the beginning of --help,
 // after 'WARNING: ' tag at the start of the command.
 func generateWarningText(cmd *cmds.Command) string {
@@ -550,17 +962,18 @@ func argUsageText(arg cmds.Argument) string {
 	return s
 }
 
+// align pads lines with spaces so they all have the same display width.
 func align(lines []string) []string {
 	longest := 0
 	for _, line := range lines {
-		length := len(line)
+		length := runewidth.StringWidth(line)
 		if length > longest {
 			longest = length
 		}
 	}
 
 	for i, line := range lines {
-		length := len(line)
+		length := runewidth.StringWidth(line)
 		if length > 0 {
 			lines[i] += strings.Repeat(" ", longest-length)
 		}
@@ -1,10 +1,15 @@
 package cli
 
 import (
+	"context"
+	"io"
+	"regexp"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	runewidth "github.com/mattn/go-runewidth"
 )
 
 func TestSynopsisGenerator(t *testing.T) {
@@ -24,7 +29,7 @@ func TestSynopsisGenerator(t *testing.T) {
 		},
 	}
 	terminalWidth := 100
-	syn := generateSynopsis(terminalWidth, command, "cmd")
+	syn := generateSynopsis(&helpConfig{}, terminalWidth, command, "cmd")
 	t.Logf("Synopsis is: %s", syn)
 	if !strings.HasPrefix(syn, "cmd ") {
 		t.Fatal("Synopsis should start with command name")
@@ -48,3 +53,768 @@ func TestSynopsisGenerator(t *testing.T) {
 		t.Fatal("Synopsis should contain options finalizer")
 	}
 }
+
+func TestLongHelpWrapsToWidth(t *testing.T) {
+	command := &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Print a very long description.",
+			LongDescription: `
+This description is much longer than the configured width and therefore has to be wrapped onto several lines.
+
+    an indented line that is also long enough that it needs to be wrapped as well, too
+`,
+		},
+		Options: []cmds.Option{
+			cmds.StringOption("opt", "o", "An option with a description long enough to wrap at least once."),
+		},
+	}
+
+	const width = 60
+	var buf strings.Builder
+	if err := LongHelp("cmd", command, nil, &buf, HelpWithWidth(width)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	t.Logf("help is:\n%s", out)
+
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) > width {
+			t.Errorf("line exceeds %d columns: %q", width, line)
+		}
+	}
+	if !strings.Contains(out, "lines.\n  \n      an indented line") {
+		t.Error("expected paragraph break and indentation to be preserved")
+	}
+	if !strings.Contains(out, "\n      needs to be wrapped as well, too") {
+		t.Error("expected continuation line to keep the original indentation")
+	}
+}
+
+func TestWrapLinesKeepsLongWords(t *testing.T) {
+	word := strings.Repeat("x", 60)
+	got := wrapLines("short words then "+word+" and more", 40)
+	if !strings.Contains(got, word) {
+		t.Fatalf("long word was broken up: %q", got)
+	}
+}
+
+func TestWrapLinesUsesDisplayWidth(t *testing.T) {
+	// 38 columns, but 50 bytes
+	line := strings.TrimSpace(strings.Repeat("größe ", 6)) + " ok"
+	if got := wrapLines(line, 40); got != line {
+		t.Errorf("expected %q to fit in 40 columns, got %q", line, got)
+	}
+
+	got := wrapLines(strings.Repeat("größe ", 10), 40)
+	for _, l := range strings.Split(got, "\n") {
+		if w := runewidth.StringWidth(l); w > 40 {
+			t.Errorf("expected lines of at most 40 columns, got %d: %q", w, l)
+		}
+	}
+}
+
+func TestAlignUsesDisplayWidth(t *testing.T) {
+	lines := align([]string{"größe", "size", "大小"})
+	for _, l := range lines {
+		if w := runewidth.StringWidth(l); w != 5 {
+			t.Errorf("expected %q to be 5 columns wide, got %d", l, w)
+		}
+	}
+
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.IntOption("größe", "Size of the thing."),
+			cmds.StringOption("name", "Name of the thing."),
+		},
+	}
+	lines = optionText(&helpConfig{}, 80, command)
+	first := runewidth.StringWidth(lines[0][:strings.Index(lines[0], " - ")])
+	second := runewidth.StringWidth(lines[1][:strings.Index(lines[1], " - ")])
+	if first != second {
+		t.Fatalf("option columns are not aligned:\n%s\n%s", lines[0], lines[1])
+	}
+}
+
+func TestLongHelpColor(t *testing.T) {
+	command := &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Do things.",
+		},
+		Arguments: []cmds.Argument{
+			cmds.StringArg("thing", true, false, "The thing."),
+		},
+		Options: []cmds.Option{
+			cmds.BoolOption("verbose", "v", "Be verbose."),
+		},
+	}
+
+	var plain, colored strings.Builder
+	if err := LongHelp("cmd", command, nil, &plain, HelpWithColor(false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := LongHelp("cmd", command, nil, &colored, HelpWithColor(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Fatal("expected no escape codes when color is disabled")
+	}
+	for _, want := range []string{
+		ansiBold + "USAGE" + ansiReset,
+		ansiBold + "OPTIONS" + ansiReset,
+		ansiCyan + "-v, --[no-]verbose" + ansiReset,
+		ansiDim + "bool" + ansiReset,
+	} {
+		if !strings.Contains(colored.String(), want) {
+			t.Errorf("expected colored help to contain %q", want)
+		}
+	}
+
+	stripped := regexp.MustCompile("\x1b\\[[0-9]*m").ReplaceAllString(colored.String(), "")
+	if stripped != plain.String() {
+		t.Fatalf("colored help differs from plain help once escape codes are removed:\n%s\n---\n%s", stripped, plain.String())
+	}
+}
+
+func TestUsageHelp(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat": {
+				Helptext: cmds.HelpText{
+					Tagline:          "Show file contents.",
+					ShortDescription: "This should not be shown.",
+				},
+				Arguments: []cmds.Argument{
+					cmds.StringArg("path", true, true, "The path."),
+				},
+				Options: []cmds.Option{
+					cmds.BoolOption("raw", "Neither should this."),
+				},
+			},
+		},
+	}
+
+	var buf strings.Builder
+	if err := UsageHelp("tool", root, []string{"cat"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "tool cat <path>... - Show file contents.\n\nUse 'tool cat --help' for more information.\n"
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%q\ngot:\n%q", expected, buf.String())
+	}
+}
+
+func TestUsageHelpWidth(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat": {
+				Helptext: cmds.HelpText{
+					Tagline: strings.Repeat("Show file contents. ", 5),
+				},
+				Arguments: []cmds.Argument{
+					cmds.StringArg("path", true, true, "The path."),
+				},
+			},
+		},
+	}
+
+	var buf strings.Builder
+	if err := UsageHelp("tool", root, []string{"cat"}, &buf, HelpWithWidth(60)); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if w := runewidth.StringWidth(line); w > 60-len(indentStr) {
+			t.Errorf("expected lines of at most %d columns, got %d: %q", 60-len(indentStr), w, line)
+		}
+	}
+}
+
+func TestSubcommandTextSorted(t *testing.T) {
+	command := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"zeta":  {Helptext: cmds.HelpText{Tagline: "Last."}},
+			"alpha": {Helptext: cmds.HelpText{Tagline: "First."}},
+			"mu":    {Helptext: cmds.HelpText{Tagline: "Middle."}},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		lines := subcommandText(&helpConfig{}, 80, command, "tool", nil, cmds.Active)
+		expected := []string{
+			"tool alpha - First.",
+			"tool mu    - Middle.",
+			"tool zeta  - Last.",
+		}
+		if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+		}
+	}
+}
+
+func TestOptionTextSorting(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("z", "all", "Short name sorts last, long name first."),
+			cmds.BoolOption("verbose", "v", "Verbose."),
+			cmds.BoolOption("b", "Only a short name."),
+		},
+	}
+
+	flags := func(lines []string) []string {
+		out := make([]string, len(lines))
+		for i, l := range lines {
+			out[i] = strings.TrimSpace(l[:strings.Index(l, "  ")])
+		}
+		return out
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := []string{"-z, --[no-]all", "-v, --[no-]verbose", "-b"}
+	if got := flags(lines); strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Fatalf("expected source order %v, got %v", expected, got)
+	}
+
+	lines = optionText(&helpConfig{sortOptions: true}, 80, command)
+	expected = []string{"-z, --[no-]all", "-b", "-v, --[no-]verbose"}
+	if got := flags(lines); strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Fatalf("expected sorted order %v, got %v", expected, got)
+	}
+}
+
+func TestHiddenOptionsHelp(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("public", "A public option."),
+			cmds.BoolOption("internal", "An internal option.").WithHidden(true),
+		},
+	}
+
+	var buf strings.Builder
+	if err := LongHelp("cmd", command, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "--public") {
+		t.Fatal("expected public option in help")
+	}
+	if strings.Contains(buf.String(), "--internal") {
+		t.Fatalf("expected hidden option to be absent from help:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := LongHelp("cmd", command, nil, &buf, HelpWithHidden(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "--internal") {
+		t.Fatalf("expected hidden option in developer help:\n%s", buf.String())
+	}
+
+	// hidden options can still be passed
+	req := &cmds.Request{}
+	if err := parse(req, []string{"--internal"}, command); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := req.Options["internal"].(bool); !v {
+		t.Fatal("expected hidden option to be parsed")
+	}
+}
+
+func TestHiddenSubcommands(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"add":   {Helptext: cmds.HelpText{Tagline: "Add files."}},
+			"debug": {Helptext: cmds.HelpText{Tagline: "Debug internals."}, Hidden: true},
+		},
+	}
+
+	if _, err := root.Get([]string{"debug"}); err != nil {
+		t.Fatalf("expected hidden subcommand to be reachable: %s", err)
+	}
+
+	var buf strings.Builder
+	if err := LongHelp("tool", root, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "tool add") {
+		t.Fatal("expected subcommand in help")
+	}
+	if strings.Contains(buf.String(), "debug") {
+		t.Fatalf("expected hidden subcommand to be absent from help:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := LongHelp("tool", root, nil, &buf, HelpWithHidden(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "tool debug") {
+		t.Fatalf("expected hidden subcommand in developer help:\n%s", buf.String())
+	}
+}
+
+func TestOptionTextDefaults(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("name", "The name to use.").WithDefault("bob"),
+			cmds.IntOption("timeout", "Seconds to wait.").WithDefault(30),
+			cmds.BoolOption("quiet", "Write less output.").WithDefault(true),
+			cmds.BoolOption("verbose", "Write more output."),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := []string{
+		"--name          string (default: bob)  - The name to use.",
+		"--timeout       int    (default: 30)   - Seconds to wait.",
+		"--[no-]quiet    bool   (default: true) - Write less output.",
+		"--[no-]verbose  bool                   - Write more output.",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d: %q", len(expected), len(lines), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestOptionTextSecret(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("password", "The password to use.").WithSecret(true).WithDefault("hunter2"),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := "--password  string (default: ****) - The password to use."
+	if len(lines) != 1 || lines[0] != expected {
+		t.Fatalf("expected %q, got %q", expected, lines)
+	}
+
+	var buf strings.Builder
+	if err := ManPage("tool", command, nil, 1, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("the man page shows the secret default:\n%s", buf.String())
+	}
+}
+
+func TestOptionTextEnvVar(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.IntOption("timeout", "Seconds to wait.").WithEnvVar("MYTOOL_TIMEOUT").WithDefault(30),
+			cmds.StringsOption("peer", "Peers to dial.").WithEnvVar("MYTOOL_PEERS"),
+			cmds.BoolOption("quiet", "Write less output."),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := []string{
+		"--timeout     int   (default: 30) - Seconds to wait. [env: MYTOOL_TIMEOUT]",
+		"--peer        array               - Peers to dial. [env: MYTOOL_PEERS]",
+		"--[no-]quiet  bool                - Write less output.",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+		if n := strings.Count(lines[i], "[env:"); i < 2 && n != 1 {
+			t.Errorf("line %d: expected one env annotation, got %d", i, n)
+		}
+	}
+}
+
+func TestLongHelpExamples(t *testing.T) {
+	command := &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Add files.",
+		},
+		Examples: []cmds.Example{
+			{Command: "tool add -r ./dir", Description: "Add a directory recursively."},
+			{Command: "tool add file.txt"},
+		},
+	}
+
+	var buf strings.Builder
+	if err := LongHelp("tool", command, nil, &buf, HelpWithWidth(80)); err != nil {
+		t.Fatal(err)
+	}
+	want := "EXAMPLES\n\n  tool add -r ./dir\n    Add a directory recursively.\n  \n  tool add file.txt\n\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected help to contain %q, got:\n%s", want, buf.String())
+	}
+
+	command.Examples = []cmds.Example{}
+	buf.Reset()
+	if err := LongHelp("tool", command, nil, &buf, HelpWithWidth(80)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "EXAMPLES") {
+		t.Fatalf("expected no EXAMPLES section, got:\n%s", buf.String())
+	}
+}
+
+func TestLongHelpCustomTemplate(t *testing.T) {
+	command := &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Add files.",
+		},
+		HelpTemplate: "{{.Path}}: {{.Tagline}}\n",
+	}
+
+	var buf strings.Builder
+	if err := LongHelp("tool", command, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "tool: Add files.\n" {
+		t.Fatalf("expected custom template to be used, got %q", buf.String())
+	}
+
+	command.HelpTemplate = "{{.Path"
+	if err := LongHelp("tool", command, nil, &buf); err == nil {
+		t.Fatal("expected an error for an invalid template")
+	}
+}
+
+func TestOptionTextAliases(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("recursive", "r", "Add directories recursively.").WithAliases("R", "recurse"),
+			cmds.StringOption("name", "The name to use."),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 100, command)
+	expected := []string{
+		"-r, --[no-]recursive  bool   - (alias: -R, --recurse) Add directories recursively.",
+		"--name                string - The name to use.",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestDeprecatedHelp(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("old", "Do it the old way.").WithDeprecated("use --new instead"),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"legacy": {
+				Helptext:   cmds.HelpText{Tagline: "Legacy command."},
+				Deprecated: "use 'tool modern'",
+			},
+		},
+	}
+
+	var buf strings.Builder
+	if err := LongHelp("tool", root, nil, &buf, HelpWithWidth(120)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"--[no-]old  bool - Do it the old way. (DEPRECATED: use --new instead)\n",
+		"tool legacy - Legacy command. (DEPRECATED: use 'tool modern')\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected help to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestOptionTextGroups(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("json", "Output JSON.").WithGroup("Output options"),
+			cmds.BoolOption("help", "Show the help."),
+			cmds.StringOption("api", "The API address.").WithGroup("Network options"),
+			cmds.BoolOption("quiet", "q", "Write less output.").WithGroup("Output options"),
+			cmds.IntOption("timeout", "Seconds to wait.").WithGroup("Network options"),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := []string{
+		"--[no-]help  bool - Show the help.",
+		"",
+		"Output options:",
+		"  --[no-]json       bool - Output JSON.",
+		"  -q, --[no-]quiet  bool - Write less output.",
+		"",
+		"Network options:",
+		"  --api      string - The API address.",
+		"  --timeout  int    - Seconds to wait.",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d: %q", len(expected), len(lines), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestLongHelpSeeAlso(t *testing.T) {
+	var buf strings.Builder
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"add": {
+				Helptext: cmds.HelpText{
+					Tagline: "Add files.",
+				},
+				SeeAlso: []string{"config show", "nope"},
+			},
+			"config": completionRoot.Subcommands["config"],
+		},
+	}
+	if err := LongHelp("tool", root, []string{"add"}, &buf, HelpWithWidth(80)); err != nil {
+		t.Fatal(err)
+	}
+	want := "SEE ALSO\n  tool config show - Show the config.\n\n\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Fatalf("expected help to end with %q, got:\n%s", want, buf.String())
+	}
+	if strings.Contains(buf.String(), "nope") {
+		t.Fatalf("expected dangling reference to be skipped, got:\n%s", buf.String())
+	}
+}
+
+func TestSubcommandTaglineTruncated(t *testing.T) {
+	tagline := "Ĉiu ŝanĝo de la agordo estas konservita en la deponejo kaj povas esti malfarita poste."
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"config": {Helptext: cmds.HelpText{Tagline: tagline}},
+		},
+	}
+
+	lines := subcommandText(&helpConfig{taglineLimit: 20}, 200, root, "tool", nil, cmds.Active)
+	expected := "tool config - Ĉiu ŝanĝo de la a..."
+	if len(lines) != 1 || lines[0] != expected {
+		t.Fatalf("expected %q, got %q", expected, lines)
+	}
+
+	var buf strings.Builder
+	if err := LongHelp("tool", root, nil, &buf, HelpWithWidth(200)); err != nil {
+		t.Fatal(err)
+	}
+	short := truncate(tagline, defaultTaglineLimit)
+	if n := utf8.RuneCountInString(short); n > defaultTaglineLimit || !strings.HasSuffix(short, "...") {
+		t.Fatalf("unexpected truncated tagline %q (%d runes)", short, n)
+	}
+	if !strings.Contains(buf.String(), "tool config - "+short+"\n") {
+		t.Fatalf("expected truncated tagline in help, got:\n%s", buf.String())
+	}
+
+	// the subcommand's own help shows the full tagline
+	buf.Reset()
+	if err := LongHelp("tool", root, []string{"config"}, &buf, HelpWithWidth(200)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), tagline) {
+		t.Fatalf("expected full tagline in subcommand help, got:\n%s", buf.String())
+	}
+}
+
+func TestSubcommandTextCategories(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"get":     {Helptext: cmds.HelpText{Tagline: "Get data."}, Category: "Data"},
+			"add":     {Helptext: cmds.HelpText{Tagline: "Add data."}, Category: "Data"},
+			"swarm":   {Helptext: cmds.HelpText{Tagline: "Manage peers."}, Category: "Network"},
+			"config":  {Helptext: cmds.HelpText{Tagline: "Manage the config."}, Category: "Config"},
+			"version": {Helptext: cmds.HelpText{Tagline: "Show the version."}},
+		},
+	}
+
+	lines := subcommandText(&helpConfig{}, 80, root, "tool", nil, cmds.Active)
+	expected := []string{
+		"Config:",
+		"  tool config - Manage the config.",
+		"",
+		"Data:",
+		"  tool add - Add data.",
+		"  tool get - Get data.",
+		"",
+		"Network:",
+		"  tool swarm - Manage peers.",
+		"",
+		"Other:",
+		"  tool version - Show the version.",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d: %q", len(expected), len(lines), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestSubcommandTextAliases(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"remove": {Helptext: cmds.HelpText{Tagline: "Remove files."}, Aliases: []string{"rm", "del"}},
+			"add":    {Helptext: cmds.HelpText{Tagline: "Add files."}},
+		},
+	}
+
+	lines := subcommandText(&helpConfig{}, 80, root, "tool", nil, cmds.Active)
+	expected := []string{
+		"tool add                     - Add files.",
+		"tool remove (alias: rm, del) - Remove files.",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestOptionTextEnum(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("format", "Output format.").WithEnum("json", "text", "xml"),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := "--format  string - Output format. (one of: json, text, xml)"
+	if len(lines) != 1 || lines[0] != expected {
+		t.Fatalf("expected %q, got %q", expected, lines)
+	}
+}
+
+func TestOptionTextMutuallyExclusive(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("json", "Output JSON."),
+			cmds.BoolOption("xml", "Output XML."),
+		},
+		MutuallyExclusive: [][]string{{"json", "xml"}},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := []string{
+		"--[no-]json  bool - Output JSON. (conflicts with --xml)",
+		"--[no-]xml   bool - Output XML. (conflicts with --json)",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestOptionTextRequired(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("api-url", "The API to use.").WithRequired(true),
+			cmds.BoolOption("quiet", "Write less output."),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := []string{
+		"--api-url     string - (required) The API to use.",
+		"--[no-]quiet  bool   - Write less output.",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestOptionTextDuration(t *testing.T) {
+	command := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.DurationOption("timeout", "How long to wait."),
+		},
+	}
+
+	lines := optionText(&helpConfig{}, 80, command)
+	expected := "--timeout  duration - How long to wait."
+	if len(lines) != 1 || lines[0] != expected {
+		t.Fatalf("expected %q, got %q", expected, lines)
+	}
+}
+
+func TestHelpExecutor(t *testing.T) {
+	var ran bool
+	run := func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		ran = true
+		return nil
+	}
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption(cmds.OptLongHelp, cmds.OptShortHelp, "Show the help."),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"add": {
+				Helptext: cmds.HelpText{Tagline: "Add a file."},
+				Arguments: []cmds.Argument{
+					cmds.StringArg("path", true, false, "The path."),
+				},
+				Run: run,
+			},
+			"pin": {
+				Subcommands: map[string]*cmds.Command{
+					"ls": {
+						Helptext: cmds.HelpText{Tagline: "List pins."},
+						Run:      run,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		cmdline []string
+		tagline string
+	}{
+		{[]string{"add", "--help"}, "Add a file."},
+		{[]string{"pin", "-h", "ls"}, "List pins."},
+	} {
+		cmdline := tc.cmdline
+		ran = false
+		// the missing argument of add is a parse error, help is asked
+		// for anyway
+		req, _ := Parse(context.Background(), cmdline, nil, root)
+
+		var expected strings.Builder
+		if err := LongHelp("tool", root, req.Path, &expected); err != nil {
+			t.Fatal(err)
+		}
+
+		re, res := cmds.NewChanResponsePair(req)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- cmds.NewExecutorWithHelp(root, HelpFunc("tool")).Execute(req, re, nil)
+		}()
+
+		v, err := cmds.ExpectOne(res)
+		if err != nil {
+			t.Fatalf("%v: %s", cmdline, err)
+		}
+		text, err := io.ReadAll(v.(io.Reader))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(text), tc.tagline) {
+			t.Errorf("%v: expected the help of the command, got:\n%s", cmdline, text)
+		}
+		if string(text) != expected.String() {
+			t.Errorf("%v: expected:\n%s\ngot:\n%s", cmdline, expected.String(), text)
+		}
+		if err := <-errCh; err != nil {
+			t.Errorf("%v: %s", cmdline, err)
+		}
+		if ran {
+			t.Errorf("%v: expected the command not to run", cmdline)
+		}
+	}
+}
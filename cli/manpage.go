@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// ManPage writes the help for the given command as a roff man page in the
+// given manual section.
+//
+// Subcommands are cross-referenced by the name of their own man page, which
+// is the command path joined with dashes (e.g. "ipfs-config-show").
+func ManPage(rootName string, root *cmds.Command, path []string, section int, out io.Writer) error {
+	cmd, err := root.Get(path)
+	if err != nil {
+		return err
+	}
+
+	name := manPageName(rootName, path)
+	pathStr := strings.TrimSpace(rootName + " " + strings.Join(path, " "))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %s %d\n", strings.ToUpper(manEscape(name)), section)
+
+	b.WriteString(".SH NAME\n")
+	b.WriteString(manEscape(name))
+	if cmd.Helptext.Tagline != "" {
+		b.WriteString(` \- ` + manEscape(cmd.Helptext.Tagline))
+	}
+	b.WriteString("\n")
+
+	b.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&b, ".B %s\n", manEscape(pathStr))
+	if usage := usageText(cmd); usage != "" {
+		b.WriteString(manEscape(usage) + "\n")
+	}
+
+	description := cmd.Helptext.ShortDescription
+	if cmd.Helptext.LongDescription != "" {
+		description = cmd.Helptext.LongDescription
+	}
+	if description = strings.Trim(description, whitespace); description != "" {
+		b.WriteString(".SH DESCRIPTION\n")
+		for _, line := range strings.Split(description, "\n") {
+			if strings.TrimSpace(line) == "" {
+				b.WriteString(".PP\n")
+				continue
+			}
+			b.WriteString(manEscape(line) + "\n")
+		}
+	}
+
+	if len(cmd.Arguments) > 0 {
+		b.WriteString(".SH ARGUMENTS\n")
+		for _, arg := range cmd.Arguments {
+			fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", manEscape(argUsageText(arg)), manEscape(arg.Description))
+		}
+	}
+
+	if options := visibleOptions(&helpConfig{}, cmd); len(options) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		for _, opt := range options {
+			flags := sortByLength(opt.Names())
+			for i, f := range flags {
+				flags[i] = optionFlag(f)
+			}
+			fmt.Fprintf(&b, ".TP\n.B %s\n\\fI%v\\fR \\- %s\n",
+				manEscape(strings.Join(flags, ", ")), cmds.Details(opt).TypeName(), manEscape(opt.Description()))
+		}
+	}
+
+	if names := sortedSubcommands(cmd); len(names) > 0 {
+		b.WriteString(".SH SUBCOMMANDS\n")
+		for _, sub := range names {
+			subPath := append(append([]string{}, path...), sub)
+			fmt.Fprintf(&b, ".TP\n.BR %s (%d)\n%s\n",
+				manEscape(manPageName(rootName, subPath)), section,
+				manEscape(cmd.Subcommands[sub].Helptext.Tagline))
+		}
+	}
+
+	_, err = io.WriteString(out, b.String())
+	return err
+}
+
+func manPageName(rootName string, path []string) string {
+	return strings.Join(append([]string{rootName}, path...), "-")
+}
+
+// manEscape escapes text so roff doesn't interpret it as markup.
+func manEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		// lines starting with these would be read as requests
+		s = `\&` + s
+	}
+	return s
+}
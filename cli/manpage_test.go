@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestManPage(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"files": {
+				Helptext: cmds.HelpText{
+					Tagline:          "Manage files.",
+					ShortDescription: "Copy files from C:\\src to the repo.\n\n.hidden files are skipped.",
+				},
+				Arguments: []cmds.Argument{
+					cmds.StringArg("source", true, false, "Where to copy from."),
+					cmds.StringArg("extra", false, true, "More things."),
+				},
+				Options: []cmds.Option{
+					cmds.BoolOption("dry-run", "n", "Don't copy anything."),
+				},
+				Subcommands: map[string]*cmds.Command{
+					"rm": {Helptext: cmds.HelpText{Tagline: "Remove files."}},
+					"cp": {Helptext: cmds.HelpText{Tagline: "Copy files."}},
+				},
+			},
+		},
+	}
+
+	var buf strings.Builder
+	if err := ManPage("tool", root, []string{"files"}, 1, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	t.Logf("man page is:\n%s", out)
+
+	for _, want := range []string{
+		".TH TOOL\\-FILES 1\n",
+		".SH NAME\ntool\\-files \\- Manage files.\n",
+		".SH SYNOPSIS\n.B tool files\n<source> [<extra>]...\n",
+		"Copy files from C:\\esrc to the repo.\n.PP\n\\&.hidden files are skipped.\n",
+		".B \\-n, \\-\\-dry\\-run\n",
+		".BR tool\\-files\\-cp (1)\nCopy files.\n.TP\n.BR tool\\-files\\-rm (1)\nRemove files.\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected man page to contain %q", want)
+		}
+	}
+}
+
+func TestManPageHidden(t *testing.T) {
+	var buf strings.Builder
+	if err := ManPage("my-tool", hiddenRoot, nil, 1, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, name := range []string{"debug\\-internals", "secret"} {
+		if strings.Contains(out, name) {
+			t.Errorf("expected %q to be left out, got:\n%s", name, out)
+		}
+	}
+	if !strings.Contains(out, ".BR my\\-tool\\-status (1)\n") {
+		t.Errorf("expected the status command to be listed, got:\n%s", out)
+	}
+	if strings.Contains(out, ".SH OPTIONS") {
+		t.Errorf("expected no options section without visible options, got:\n%s", out)
+	}
+}
@@ -2,13 +2,14 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
-	"reflect"
 	"runtime"
 	"sort"
 	"strings"
@@ -19,6 +20,10 @@ import (
 )
 
 var log = logging.Logger("cmds/cli")
+
+// errUnknownOption is wrapped by the errors about options the parser doesn't
+// know.
+var errUnknownOption = errors.New("unknown option")
 var msgStdinInfo = "ipfs: Reading from %s; send Ctrl-d to stop."
 
 func init() {
@@ -32,24 +37,51 @@ func init() {
 //
 // This function never returns nil, even on error.
 func Parse(ctx context.Context, input []string, stdin *os.File, root *cmds.Command) (*cmds.Request, error) {
+	return parseRequest(ctx, input, stdin, os.Stderr, root)
+}
+
+// parseRequest is Parse, writing warnings about the use of deprecated
+// commands and options to stderr.
+func parseRequest(ctx context.Context, input []string, stdin *os.File, stderr io.Writer, root *cmds.Command) (*cmds.Request, error) {
 	req := &cmds.Request{Context: ctx}
 
 	if err := parse(req, input, root); err != nil {
 		return req, err
 	}
 
+	// warn before filling in the defaults, only options set by the user
+	// count as used
+	warnDeprecated(req, stderr)
+
+	// flags take precedence over environment variables, which take
+	// precedence over the config, which takes precedence over defaults
+	if err := fillFromEnv(req); err != nil {
+		return req, err
+	}
+	if err := fillFromConfig(req); err != nil {
+		return req, err
+	}
+	// the command's default encoding takes precedence over the global one
+	if _, ok := req.Options[cmds.EncLong]; !ok && req.Command.DefaultEncoding != "" {
+		req.SetOption(cmds.EncLong, string(req.Command.DefaultEncoding))
+	}
 	if err := req.FillDefaults(); err != nil {
 		return req, err
 	}
 
-	if err := parseArgs(req, root, stdin); err != nil {
+	p := prompter(req, stdin)
+	if err := checkRequired(req, p); err != nil {
+		return req, err
+	}
+
+	if err := parseArgs(req, root, stdin, p); err != nil {
 		return req, err
 	}
 
 	// if no encoding was specified by user, default to plaintext encoding
 	// (if command doesn't support plaintext, use JSON instead)
 	if enc := req.Options[cmds.EncLong]; enc == "" {
-		if req.Command.Encoders != nil && req.Command.Encoders[cmds.Text] != nil {
+		if req.Command.Encoders[cmds.Text] != nil || req.Command.TextTemplate != "" {
 			req.SetOption(cmds.EncLong, cmds.Text)
 		} else {
 			req.SetOption(cmds.EncLong, cmds.JSON)
@@ -59,6 +91,37 @@ func Parse(ctx context.Context, input []string, stdin *os.File, root *cmds.Comma
 	return req, nil
 }
 
+// warnDeprecated writes the deprecation messages of the commands and options
+// used by req to w.
+func warnDeprecated(req *cmds.Request, w io.Writer) {
+	cmdPath, err := req.Root.Resolve(req.Path)
+	if err != nil {
+		return
+	}
+	for i, cmd := range cmdPath[1:] {
+		if cmd.Deprecated != "" {
+			fmt.Fprintf(w, "WARNING: command '%s' is deprecated: %s\n",
+				strings.Join(req.Path[:i+1], " "), cmd.Deprecated)
+		}
+	}
+
+	optDefs, err := req.Root.GetOptions(req.Path)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, len(req.Options))
+	for name := range req.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if opt, ok := optDefs[name]; ok && cmds.Details(opt).Deprecated() != "" {
+			fmt.Fprintf(w, "WARNING: option '%s' is deprecated: %s\n",
+				optionFlag(name), cmds.Details(opt).Deprecated())
+		}
+	}
+}
+
 func isHidden(req *cmds.Request) bool {
 	h, ok := req.Options[cmds.Hidden].(bool)
 	return h && ok
@@ -84,9 +147,20 @@ func stdinName(req *cmds.Request) string {
 	return name
 }
 
+type abbreviationsKey struct{}
+
+// WithAbbreviations returns a copy of ctx that makes Parse and Run accept
+// unambiguous prefixes of long option names, such as --rec for --recursive.
+func WithAbbreviations(ctx context.Context) context.Context {
+	return context.WithValue(ctx, abbreviationsKey{}, true)
+}
+
 type parseState struct {
 	cmdline []string
 	i       int
+
+	// abbrev enables the expansion of long option prefixes
+	abbrev bool
 }
 
 func (st *parseState) done() bool {
@@ -97,13 +171,27 @@ func (st *parseState) peek() string {
 	return st.cmdline[st.i]
 }
 
-func setOpts(kv kv, kvType reflect.Kind, opts cmds.OptMap) error {
+func setOpts(kv kv, optDef cmds.Option, opts cmds.OptMap) error {
 
-	if kvType == cmds.Strings {
+	if optDef.Type() == cmds.Strings {
 		res, _ := opts[kv.Key].([]string)
 		opts[kv.Key] = append(res, kv.Value.([]string)...)
-	} else if _, exists := opts[kv.Key]; !exists {
+	} else if optDef.Type() == cmds.StringMap {
+		res, _ := opts[kv.Key].(map[string]string)
+		if res == nil {
+			res = make(map[string]string)
+		}
+		for k, v := range kv.Value.(map[string]string) {
+			res[k] = v
+		}
+		opts[kv.Key] = res
+	} else if cmds.Details(optDef).Count() {
+		res, _ := opts[kv.Key].(int)
+		opts[kv.Key] = res + kv.Value.(int)
+	} else if prev, exists := opts[kv.Key]; !exists {
 		opts[kv.Key] = kv.Value
+	} else if optDef.Type() == cmds.Bool && prev != kv.Value {
+		return fmt.Errorf("--%s and --no-%s can't be used together", kv.Key, kv.Key)
 	} else {
 		return fmt.Errorf("multiple values for option %q", kv.Key)
 	}
@@ -112,13 +200,17 @@ func setOpts(kv kv, kvType reflect.Kind, opts cmds.OptMap) error {
 
 func parse(req *cmds.Request, cmdline []string, root *cmds.Command) (err error) {
 	var (
-		path = make([]string, 0, len(cmdline))
-		args = make([]string, 0, len(cmdline))
-		opts = cmds.OptMap{}
-		cmd  = root
+		path   = make([]string, 0, len(cmdline))
+		args   = make([]string, 0, len(cmdline))
+		opts   = cmds.OptMap{}
+		extras []string
+		cmd    = root
 	)
 
 	st := &parseState{cmdline: cmdline}
+	if req.Context != nil {
+		st.abbrev, _ = req.Context.Value(abbreviationsKey{}).(bool)
+	}
 
 	// get root options
 	optDefs, err := root.GetOptions([]string{})
@@ -138,21 +230,32 @@ L:
 		case strings.HasPrefix(param, "--"):
 			// long option
 			k, v, err := st.parseLongOpt(optDefs)
+			if errors.Is(err, errUnknownOption) && cmd.AllowUnknownOptions {
+				extras = append(extras, param)
+				break
+			}
 			if err != nil {
 				return err
 			}
+			if isAlias(optDefs[k], k) {
+				k = optDefs[k].Name()
+			}
 
-			kvType, err := getOptType(k, optDefs)
+			optDef, err := getOptDef(k, optDefs)
 			if err != nil {
 				return err // shouldn't happen b/c k,v was parsed from optsDef
 			}
-			if err := setOpts(kv{Key: k, Value: v}, kvType, opts); err != nil {
+			if err := setOpts(kv{Key: k, Value: v}, optDef, opts); err != nil {
 				return err
 			}
 
 		case strings.HasPrefix(param, "-") && param != "-":
 			// short options
 			kvs, err := st.parseShortOpts(optDefs)
+			if errors.Is(err, errUnknownOption) && cmd.AllowUnknownOptions {
+				extras = append(extras, param)
+				break
+			}
 			if err != nil {
 				return err
 			}
@@ -160,21 +263,21 @@ L:
 			for _, kv := range kvs {
 				kv.Key = optDefs[kv.Key].Names()[0]
 
-				kvType, err := getOptType(kv.Key, optDefs)
+				optDef, err := getOptDef(kv.Key, optDefs)
 				if err != nil {
 					return err // shouldn't happen b/c kvs was parsed from optsDef
 				}
-				if err := setOpts(kv, kvType, opts); err != nil {
+				if err := setOpts(kv, optDef, opts); err != nil {
 					return err
 				}
 			}
 		default:
 			arg := param
 			// arg is a sub-command or a positional argument
-			sub := cmd.Subcommands[arg]
+			name, sub := cmd.Subcommand(arg)
 			if sub != nil {
 				cmd = sub
-				path = append(path, arg)
+				path = append(path, name)
 				optDefs, err = root.GetOptions(path)
 				if err != nil {
 					return err
@@ -187,7 +290,13 @@ L:
 					break L
 				}
 			} else {
-				args = append(args, arg)
+				expanded := []string{arg}
+				if cmd.ArgFiles {
+					if expanded, err = expandArgFile(arg); err != nil {
+						return err
+					}
+				}
+				args = append(args, expanded...)
 				if len(path) == 0 {
 					// found a typo or early argument
 					return printSuggestions(args, root)
@@ -203,11 +312,152 @@ L:
 	req.Path = path
 	req.Arguments = args
 	req.Options = opts
+	req.ExtraOptions = extras
+
+	return checkExclusive(root, path, opts)
+}
+
+// expandArgFile returns the arguments read from the file named by an argument
+// of the form @path, one per line, skipping empty lines. An argument starting
+// with @@ stands for itself without the first @, all others are returned as
+// is.
+func expandArgFile(arg string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(arg, "@@"):
+		return []string{arg[1:]}, nil
+	case !strings.HasPrefix(arg, "@") || arg == "@":
+		return []string{arg}, nil
+	}
+
+	fpath := arg[1:]
+	data, err := os.ReadFile(fpath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("argument file %s does not exist", fpath)
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot read argument file %s: %w", fpath, err)
+	}
+
+	var args []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			args = append(args, line)
+		}
+	}
+	return args, nil
+}
+
+// fillFromEnv sets the options that weren't passed on the command line from
+// the environment variables bound to them.
+func fillFromEnv(req *cmds.Request) error {
+	optDefs, err := req.Root.GetOptions(req.Path)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(optDefs))
+	for name, opt := range optDefs {
+		if cmds.Details(opt).EnvVar() != "" && name == opt.Name() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+Outer:
+	for _, name := range names {
+		opt := optDefs[name]
+		for _, n := range opt.Names() {
+			if _, ok := req.Options[n]; ok {
+				continue Outer
+			}
+		}
+
+		str, ok := os.LookupEnv(cmds.Details(opt).EnvVar())
+		if !ok || str == "" {
+			continue
+		}
+		v, err := opt.Parse(str)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for option %s from $%s: %w", str, optionFlag(name), cmds.Details(opt).EnvVar(), err)
+		}
+		req.Options[name] = v
+	}
+	return nil
+}
+
+// checkRequired returns an error if a required option of the command or its
+// parents has no value. Missing options are asked for if p isn't nil.
+func checkRequired(req *cmds.Request, p Prompter) error {
+	optDefs, err := req.Root.GetOptions(req.Path)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(optDefs))
+	for name, opt := range optDefs {
+		if cmds.Details(opt).Required() && name == opt.Name() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
 
+Outer:
+	for _, name := range names {
+		for _, n := range optDefs[name].Names() {
+			if _, ok := req.Options[n]; ok {
+				continue Outer
+			}
+		}
+		if p != nil {
+			v, ok, err := promptOption(p, optDefs[name])
+			if err != nil {
+				return err
+			}
+			if ok {
+				req.Options[name] = v
+				continue
+			}
+		}
+		return fmt.Errorf("missing required option %s", optionFlag(name))
+	}
 	return nil
 }
 
-func parseArgs(req *cmds.Request, root *cmds.Command, stdin *os.File) error {
+// checkExclusive returns an error if more than one option of a mutually
+// exclusive set of the commands on path was passed.
+func checkExclusive(root *cmds.Command, path []string, opts cmds.OptMap) error {
+	cmdPath, err := root.Resolve(path)
+	if err != nil {
+		return err
+	}
+	optDefs, err := root.GetOptions(path)
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range cmdPath {
+		for _, set := range cmd.MutuallyExclusive {
+			var passed []string
+			for _, name := range set {
+				opt, ok := optDefs[name]
+				if !ok {
+					continue
+				}
+				for _, n := range opt.Names() {
+					if _, ok := opts[n]; ok {
+						passed = append(passed, optionFlag(name))
+						break
+					}
+				}
+			}
+			if len(passed) > 1 {
+				return fmt.Errorf("options %s are mutually exclusive", strings.Join(passed, " and "))
+			}
+		}
+	}
+	return nil
+}
+
+func parseArgs(req *cmds.Request, root *cmds.Command, stdin *os.File, p Prompter) error {
 	argDefs := req.Command.Arguments
 
 	// count required argument definitions
@@ -220,6 +470,18 @@ func parseArgs(req *cmds.Request, root *cmds.Command, stdin *os.File) error {
 
 	inputs := req.Arguments
 
+	// stdin is only bound to an argument implicitly when it's piped in. A
+	// terminal has to be asked for explicitly with "-", so a missing argument
+	// fails rather than waiting for input.
+	explicitStdin := stdin
+	var ttyStdin bool
+	if stdin != nil {
+		if tty, err := isTty(stdin); err == nil && tty {
+			ttyStdin = true
+			stdin = nil
+		}
+	}
+
 	// count number of values provided by user.
 	// if there is at least one ArgDef, we can safely trigger the inputs loop
 	// below to parse stdin.
@@ -287,13 +549,13 @@ func parseArgs(req *cmds.Request, root *cmds.Command, stdin *os.File) error {
 				inputs = inputs[1:]
 				var file files.Node
 				if fpath == "-" {
-					r, err := maybeWrapStdin(stdin, msgStdinInfo)
+					r, err := maybeWrapStdin(explicitStdin, msgStdinInfo)
 					if err != nil {
 						return err
 					}
 
 					fpath = stdinName(req)
-					file, err = files.NewReaderPathFile(stdin.Name(), r, nil)
+					file, err = files.NewReaderPathFile(explicitStdin.Name(), r, nil)
 					if err != nil {
 						return err
 					}
@@ -371,6 +633,19 @@ func parseArgs(req *cmds.Request, root *cmds.Command, stdin *os.File) error {
 	// check to make sure we didn't miss any required arguments
 	if len(argDefs) > iArgDef {
 		for _, argDef := range argDefs[iArgDef:] {
+			if argDef.Required && argDef.Type == cmds.ArgString && p != nil {
+				v, err := promptArgument(p, argDef)
+				if err != nil {
+					return err
+				}
+				if v != "" {
+					stringArgs = append(stringArgs, v)
+					continue
+				}
+			}
+			if argDef.Required && argDef.SupportsStdin && ttyStdin {
+				return fmt.Errorf("argument %q is required; pass it or pipe it to stdin", argDef.Name)
+			}
 			if argDef.Required {
 				return fmt.Errorf("argument %q is required", argDef.Name)
 			}
@@ -427,7 +702,7 @@ func splitkv(opt string) (k, v string, ok bool) {
 func parseOpt(opt, value string, opts map[string]cmds.Option) (string, interface{}, error) {
 	optDef, ok := opts[opt]
 	if !ok {
-		return "", nil, fmt.Errorf("unknown option %q", opt)
+		return "", nil, fmt.Errorf("%w %q", errUnknownOption, opt)
 	}
 
 	v, err := optDef.Parse(value)
@@ -443,7 +718,13 @@ type kv struct {
 }
 
 func (st *parseState) parseShortOpts(optDefs map[string]cmds.Option) ([]kv, error) {
-	k, vStr, ok := splitkv(st.cmdline[st.i][1:])
+	arg := st.cmdline[st.i][1:]
+	k, vStr, ok := splitkv(arg)
+	if ok && len(k) > 1 {
+		// only a single flag is split at "=", the value of the last flag of
+		// a cluster may contain one (-sa=b), or be joined by one (-qs=a)
+		k, ok = arg, false
+	}
 	kvs := make([]kv, 0, len(k))
 
 	if ok {
@@ -462,8 +743,11 @@ func (st *parseState) parseShortOpts(optDefs map[string]cmds.Option) ([]kv, erro
 			od, ok := optDefs[flag]
 
 			switch {
+			case !ok && len(k) > 1:
+				return nil, fmt.Errorf("%w %q in %q", errUnknownOption, flag, "-"+k)
+
 			case !ok:
-				return nil, fmt.Errorf("unknown option %q", k)
+				return nil, fmt.Errorf("%w %q", errUnknownOption, k)
 
 			case od.Type() == cmds.Bool:
 				// single char flags for bools
@@ -473,9 +757,18 @@ func (st *parseState) parseShortOpts(optDefs map[string]cmds.Option) ([]kv, erro
 				})
 				j++
 
+			case cmds.Details(od).Count():
+				// every occurrence of a counting flag adds one
+				kvs = append(kvs, kv{
+					Key:   od.Name(),
+					Value: 1,
+				})
+				j++
+
 			case j < len(k)-1:
-				// single char flag for non-bools (use the rest of the flag as value)
-				rest := k[j+1:]
+				// single char flag for non-bools (use the rest of the flag as
+				// value, without the "=" it may be joined by)
+				rest := strings.TrimPrefix(k[j+1:], "=")
 
 				k, v, err := parseOpt(flag, rest, optDefs)
 				if err != nil {
@@ -507,13 +800,27 @@ func (st *parseState) parseShortOpts(optDefs map[string]cmds.Option) ([]kv, erro
 
 func (st *parseState) parseLongOpt(optDefs map[string]cmds.Option) (string, interface{}, error) {
 	k, v, ok := splitkv(st.peek()[2:])
+	if st.abbrev {
+		var err error
+		if k, err = expandPrefix(k, optDefs); err != nil {
+			return "", nil, err
+		}
+	}
 	if !ok {
 		optDef, ok := optDefs[k]
 		if !ok {
-			return "", nil, fmt.Errorf("unknown option %q", k)
+			// --no-<flag> sets a bool option to false
+			if name := strings.TrimPrefix(k, "no-"); name != k {
+				if optDef, ok := optDefs[name]; ok && optDef.Type() == cmds.Bool {
+					return optDef.Name(), false, nil
+				}
+			}
+			return "", nil, fmt.Errorf("%w %q", errUnknownOption, k)
 		}
 		if optDef.Type() == cmds.Bool {
 			return k, true, nil
+		} else if cmds.Details(optDef).Count() {
+			return optDef.Name(), 1, nil
 		} else if st.i < len(st.cmdline)-1 {
 			st.i++
 			v = st.peek()
@@ -526,6 +833,43 @@ func (st *parseState) parseLongOpt(optDefs map[string]cmds.Option) (string, inte
 	return k, optval, err
 }
 
+// expandPrefix returns the name of the option in optDefs that name is a
+// unique prefix of. Exact matches, including --no-<flag> for bool options,
+// take precedence, and names matching nothing are returned as they are.
+func expandPrefix(name string, optDefs map[string]cmds.Option) (string, error) {
+	if _, ok := optDefs[name]; ok || name == "" {
+		return name, nil
+	}
+	if n := strings.TrimPrefix(name, "no-"); n != name {
+		if optDef, ok := optDefs[n]; ok && optDef.Type() == cmds.Bool {
+			return name, nil
+		}
+	}
+
+	// aliases of the same option aren't ambiguous
+	matches := make(map[string]bool)
+	var candidates []string
+	for n, optDef := range optDefs {
+		// short names are never abbreviated
+		if len(n) < 2 || !strings.HasPrefix(n, name) {
+			continue
+		}
+		matches[optDef.Name()] = true
+		candidates = append(candidates, optionFlag(n))
+	}
+
+	switch len(matches) {
+	case 0:
+		return name, nil
+	case 1:
+		for n := range matches {
+			return n, nil
+		}
+	}
+	sort.Strings(candidates)
+	return "", fmt.Errorf("ambiguous option %q, could be %s", "--"+name, strings.Join(candidates, ", "))
+}
+
 func getArgDef(i int, argDefs []cmds.Argument) *cmds.Argument {
 	if i < len(argDefs) {
 		// get the argument definition (usually just argDefs[i])
@@ -545,7 +889,9 @@ const dirNotSupportedFmtStr = "invalid path '%s', argument '%s' does not support
 
 func appendFile(fpath string, argDef *cmds.Argument, recursive bool, filter *files.Filter) (files.Node, error) {
 	stat, err := os.Lstat(fpath)
-	if err != nil {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("argument %q: %s does not exist", argDef.Name, fpath)
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -570,6 +916,16 @@ func appendFile(fpath string, argDef *cmds.Argument, recursive bool, filter *fil
 	return files.NewSerialFileWithFilter(fpath, filter, stat)
 }
 
+// closeFileArgs closes the files opened for the file arguments of a request.
+func closeFileArgs(dir files.Directory) {
+	it := dir.Entries()
+	for it.Next() {
+		if err := it.Node().Close(); err != nil {
+			log.Debugf("error closing file argument %s: %s", it.Name(), err)
+		}
+	}
+}
+
 // Inform the user if a file is waiting on input
 func maybeWrapStdin(f *os.File, msg string) (io.ReadCloser, error) {
 	isTty, err := isTty(f)
@@ -620,9 +976,20 @@ func (r *messageReader) Close() error {
 	return r.r.Close()
 }
 
-func getOptType(k string, optDefs map[string]cmds.Option) (reflect.Kind, error) {
+// isAlias reports whether name is one of the aliases of opt, rather than one
+// of its names.
+func isAlias(opt cmds.Option, name string) bool {
+	for _, alias := range cmds.Details(opt).Aliases() {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+func getOptDef(k string, optDefs map[string]cmds.Option) (cmds.Option, error) {
 	if opt, ok := optDefs[k]; ok {
-		return opt.Type(), nil
+		return opt, nil
 	}
-	return reflect.Invalid, fmt.Errorf("unknown option %q", k)
+	return nil, fmt.Errorf("%w %q", errUnknownOption, k)
 }
@@ -7,8 +7,10 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ipfs/boxo/files"
 
@@ -177,6 +179,648 @@ func TestOptionParsing(t *testing.T) {
 	testFail("-zz--- --")
 }
 
+func TestShortOptionClusters(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("recursive", "r", "recurse into directories"),
+			cmds.BoolOption("force", "f", "don't ask"),
+			cmds.IntOption("count", "n", "how many"),
+		},
+	}
+
+	test := func(args string, expectedOpts kvs) {
+		testOptionHelper(t, cmd, args, expectedOpts, words{}, false)
+	}
+
+	test("-rf", kvs{"recursive": true, "force": true})
+	test("-n5", kvs{"count": 5})
+	test("-rfn5", kvs{"recursive": true, "force": true, "count": 5})
+	test("-rn 5", kvs{"recursive": true, "count": 5})
+
+	req := &cmds.Request{}
+	err := parse(req, []string{"-rx"}, cmd)
+	if err == nil || err.Error() != `unknown option "x" in "-rx"` {
+		t.Errorf("expected an unknown option error naming x, got %v", err)
+	}
+}
+
+func TestOptionValueAssignment(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.DurationOption("timeout", "t", "how long to wait"),
+			cmds.StringOption("name", "n", "the name"),
+			cmds.BoolOption("quiet", "q", "write less output"),
+		},
+	}
+
+	test := func(args string, expectedOpts kvs) {
+		testOptionHelper(t, cmd, args, expectedOpts, words{}, false)
+	}
+
+	test("--timeout=30s", kvs{"timeout": 30 * time.Second})
+	test("-t=30s", kvs{"timeout": 30 * time.Second})
+	test("--name=", kvs{"name": ""})
+	test("-n=", kvs{"name": ""})
+	test("--name=a=b", kvs{"name": "a=b"})
+	test("-n=a=b", kvs{"name": "a=b"})
+	test("-qna=b", kvs{"quiet": true, "name": "a=b"})
+	test("-qn=foo", kvs{"quiet": true, "name": "foo"})
+	test("-qn==foo", kvs{"quiet": true, "name": "=foo"})
+	test("-qt=30s", kvs{"quiet": true, "timeout": 30 * time.Second})
+	test("-qn=", kvs{"quiet": true, "name": ""})
+}
+
+func TestOptionAbbreviations(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("recursive", "r", "recurse into directories").WithAliases("recurse"),
+			cmds.StringOption("remote", "the remote to use"),
+			cmds.BoolOption("dry", "don't change anything"),
+			cmds.BoolOption("dry-run", "don't change anything"),
+		},
+	}
+	ctx := WithAbbreviations(context.Background())
+
+	for _, tc := range []struct {
+		cmdline []string
+		opts    kvs
+	}{
+		{cmdline: words{"--rec"}, opts: kvs{"recursive": true}},
+		{cmdline: words{"--rem=origin"}, opts: kvs{"remote": "origin"}},
+		{cmdline: words{"--remo", "origin"}, opts: kvs{"remote": "origin"}},
+		{cmdline: words{"--dry"}, opts: kvs{"dry": true}},
+		{cmdline: words{"--dry-"}, opts: kvs{"dry-run": true}},
+		{cmdline: words{"--no-recursive"}, opts: kvs{"recursive": false}},
+	} {
+		req := &cmds.Request{Context: ctx}
+		if err := parse(req, tc.cmdline, root); err != nil {
+			t.Errorf("%v: %s", tc.cmdline, err)
+			continue
+		}
+		if !reflect.DeepEqual(kvs(req.Options), tc.opts) {
+			t.Errorf("%v: expected options %v, got %v", tc.cmdline, tc.opts, req.Options)
+		}
+	}
+
+	req := &cmds.Request{Context: ctx}
+	err := parse(req, words{"--re"}, root)
+	expected := `ambiguous option "--re", could be --recurse, --recursive, --remote`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+
+	// prefixes are only expanded when asked for
+	req = &cmds.Request{Context: context.Background()}
+	err = parse(req, words{"--rec"}, root)
+	if err == nil || err.Error() != `unknown option "rec"` {
+		t.Errorf("expected an unknown option error, got %v", err)
+	}
+}
+
+func TestEndOfOptions(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"rm": {
+				Options: []cmds.Option{
+					cmds.BoolOption("force", "f", "don't ask"),
+				},
+				Arguments: []cmds.Argument{
+					cmds.StringArg("file", false, true, "files to remove"),
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		cmdline []string
+		args    []string
+		force   bool
+	}{
+		{cmdline: words{"rm", "-f", "--", "--weird-file", "-f"}, args: words{"--weird-file", "-f"}, force: true},
+		{cmdline: words{"rm", "--", "-", "rm"}, args: words{"-", "rm"}},
+		{cmdline: words{"rm", "-f", "--"}, args: words{}, force: true},
+	} {
+		req, err := Parse(context.Background(), tc.cmdline, nil, root)
+		if err != nil {
+			t.Errorf("%v: %s", tc.cmdline, err)
+			continue
+		}
+		if !reflect.DeepEqual(req.Arguments, tc.args) {
+			t.Errorf("%v: expected arguments %q, got %q", tc.cmdline, tc.args, req.Arguments)
+		}
+		if force, _ := req.Options["force"].(bool); force != tc.force {
+			t.Errorf("%v: expected force to be %v, got %v", tc.cmdline, tc.force, force)
+		}
+	}
+}
+
+func TestArgFiles(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"add": {
+				ArgFiles: true,
+				Arguments: []cmds.Argument{
+					cmds.StringArg("path", true, true, "paths to add"),
+				},
+			},
+			"echo": {
+				Arguments: []cmds.Argument{
+					cmds.StringArg("text", true, true, "text to print"),
+				},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	list := filepath.Join(dir, "files.txt")
+	if err := os.WriteFile(list, []byte("a.txt\r\n\nb c.txt\n@d.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		cmdline []string
+		args    []string
+	}{
+		{cmdline: words{"add", "@" + list}, args: words{"a.txt", "b c.txt", "@d.txt"}},
+		{cmdline: words{"add", "x.txt", "@" + list, "y.txt"}, args: words{"x.txt", "a.txt", "b c.txt", "@d.txt", "y.txt"}},
+		{cmdline: words{"add", "@@" + list}, args: words{"@" + list}},
+		{cmdline: words{"add", "@"}, args: words{"@"}},
+		{cmdline: words{"add", "--", "@" + list}, args: words{"@" + list}},
+		// commands without ArgFiles take @ literally
+		{cmdline: words{"echo", "@" + list, "@@x"}, args: words{"@" + list, "@@x"}},
+	} {
+		req, err := Parse(context.Background(), tc.cmdline, nil, root)
+		if err != nil {
+			t.Errorf("%v: %s", tc.cmdline, err)
+			continue
+		}
+		if !reflect.DeepEqual(req.Arguments, tc.args) {
+			t.Errorf("%v: expected arguments %q, got %q", tc.cmdline, tc.args, req.Arguments)
+		}
+	}
+
+	missing := filepath.Join(dir, "missing.txt")
+	_, err := Parse(context.Background(), words{"add", "@" + missing}, nil, root)
+	if err == nil || err.Error() != "argument file "+missing+" does not exist" {
+		t.Errorf("expected an error naming %s, got %v", missing, err)
+	}
+}
+
+func TestUnknownOptions(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("verbose", "v", "print more"),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"strict": {
+				Arguments: []cmds.Argument{
+					cmds.StringArg("arg", false, true, "arguments"),
+				},
+			},
+			"wrap": {
+				AllowUnknownOptions: true,
+				Options: []cmds.Option{
+					cmds.StringOption("name", "n", "a name"),
+				},
+				Arguments: []cmds.Argument{
+					cmds.StringArg("arg", false, true, "arguments"),
+				},
+			},
+		},
+	}
+
+	for _, cmdline := range []words{
+		{"strict", "--color"},
+		{"strict", "--color=auto", "a"},
+		{"strict", "-x"},
+		{"strict", "-vx"},
+	} {
+		_, err := Parse(context.Background(), cmdline, nil, root)
+		if err == nil || !strings.HasPrefix(err.Error(), "unknown option") {
+			t.Errorf("%v: expected an unknown option error, got %v", cmdline, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		cmdline words
+		opts    kvs
+		extras  words
+		args    words
+	}{
+		{
+			cmdline: words{"wrap", "--color=auto", "-x", "a"},
+			opts:    kvs{},
+			extras:  words{"--color=auto", "-x"},
+			args:    words{"a"},
+		},
+		{
+			cmdline: words{"wrap", "-v", "--name", "foo", "--depth=2", "-vx", "a", "b"},
+			opts:    kvs{"verbose": true, "name": "foo"},
+			extras:  words{"--depth=2", "-vx"},
+			args:    words{"a", "b"},
+		},
+		{
+			cmdline: words{"wrap", "a", "--", "--color"},
+			opts:    kvs{},
+			args:    words{"a", "--color"},
+		},
+	} {
+		req, err := Parse(context.Background(), tc.cmdline, nil, root)
+		if err != nil {
+			t.Errorf("%v: %s", tc.cmdline, err)
+			continue
+		}
+		for k, v := range tc.opts {
+			if req.Options[k] != v {
+				t.Errorf("%v: expected option %s to be %v, got %v", tc.cmdline, k, v, req.Options[k])
+			}
+		}
+		if !reflect.DeepEqual(req.ExtraOptions, []string(tc.extras)) {
+			t.Errorf("%v: expected extra options %q, got %q", tc.cmdline, tc.extras, req.ExtraOptions)
+		}
+		if !reflect.DeepEqual(req.Arguments, []string(tc.args)) {
+			t.Errorf("%v: expected arguments %q, got %q", tc.cmdline, tc.args, req.Arguments)
+		}
+	}
+
+	// unknown options before the command allowing them are still errors
+	if _, err := Parse(context.Background(), words{"--color", "wrap"}, nil, root); err == nil {
+		t.Error("expected an error for an unknown option of the root")
+	}
+}
+
+// plainOpt implements only cmds.Option, like options of other packages might.
+type plainOpt struct {
+	name string
+}
+
+func (o plainOpt) Name() string                        { return o.name }
+func (o plainOpt) Names() []string                     { return []string{o.name} }
+func (o plainOpt) Type() reflect.Kind                  { return cmds.String }
+func (o plainOpt) Description() string                 { return "A plain option." }
+func (o plainOpt) WithDefault(interface{}) cmds.Option { return o }
+func (o plainOpt) Default() interface{}                { return nil }
+func (o plainOpt) Parse(s string) (interface{}, error) { return s, nil }
+
+func TestPlainOption(t *testing.T) {
+	root := &cmds.Command{
+		Options:     []cmds.Option{plainOpt{"color"}},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	req, err := Parse(context.Background(), words{"--color", "red"}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Options["color"] != "red" {
+		t.Errorf("expected color red, got %v", req.Options["color"])
+	}
+
+	var help strings.Builder
+	if err := LongHelp("tool", root, nil, &help); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(help.String(), "--color  string - A plain option.") {
+		t.Errorf("expected the option in the help text, got:\n%s", help.String())
+	}
+}
+
+func TestRepeatedOptions(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringsOption("header", "H", "headers to send"),
+			cmds.ListOption("tags", "t", "tags to add"),
+		},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	for _, tc := range []struct {
+		cmdline words
+		headers []string
+	}{
+		{cmdline: words{}},
+		{cmdline: words{"--header", "A"}, headers: []string{"A"}},
+		{cmdline: words{"--header", "A", "-H", "B", "--header=C"}, headers: []string{"A", "B", "C"}},
+		{cmdline: words{"-H", "C", "--header", "A", "-H", "B"}, headers: []string{"C", "A", "B"}},
+	} {
+		req, err := Parse(context.Background(), tc.cmdline, nil, root)
+		if err != nil {
+			t.Errorf("%v: %s", tc.cmdline, err)
+			continue
+		}
+		headers, ok := req.Options.GetStringSlice("header")
+		if ok != (tc.headers != nil) || !reflect.DeepEqual(headers, tc.headers) {
+			t.Errorf("%v: expected headers %q, got %q %v", tc.cmdline, tc.headers, headers, ok)
+		}
+	}
+
+	// lists split at commas and accumulate over repeated flags too
+	req, err := Parse(context.Background(), words{"--tags", "a, b", "-t", `c\,d`}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ := req.Options.GetStringSlice("tags"); !reflect.DeepEqual(tags, []string{"a", "b", "c,d"}) {
+		t.Errorf("expected tags [a b c,d], got %q", tags)
+	}
+}
+
+func TestOptionAliasParsing(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("recursive", "r", "recurse").WithAliases("R", "recurse"),
+			cmds.StringOption("name", "a name").WithAliases("n"),
+		},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	testOptionHelper(t, cmd, "-R", kvs{"recursive": true}, words{}, false)
+	testOptionHelper(t, cmd, "--recurse", kvs{"recursive": true}, words{}, false)
+	testOptionHelper(t, cmd, "--recursive", kvs{"recursive": true}, words{}, false)
+	testOptionHelper(t, cmd, "-n foo", kvs{"name": "foo"}, words{}, false)
+	testOptionHelper(t, cmd, "--n=foo", kvs{"name": "foo"}, words{}, false)
+	testOptionHelper(t, cmd, "--name foo --n bar", kvs{}, words{}, true)
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("old", "o", "the old way").WithDeprecated("use --new instead"),
+			cmds.StringOption("mode", "a mode").WithDeprecated("modes are gone").WithDefault("x"),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"legacy": {Deprecated: "use 'modern'"},
+			"modern": {},
+		},
+	}
+
+	var stderr strings.Builder
+	if _, err := parseRequest(context.Background(), []string{"legacy", "-o"}, nil, &stderr, root); err != nil {
+		t.Fatal(err)
+	}
+	expected := "WARNING: command 'legacy' is deprecated: use 'modern'\n" +
+		"WARNING: option '--old' is deprecated: use --new instead\n"
+	if stderr.String() != expected {
+		t.Fatalf("expected warnings %q, got %q", expected, stderr.String())
+	}
+
+	// defaults of deprecated options don't count as a use
+	stderr.Reset()
+	if _, err := parseRequest(context.Background(), []string{"modern"}, nil, &stderr, root); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("expected no warnings, got %q", stderr.String())
+	}
+}
+
+func TestCommandAliasParsing(t *testing.T) {
+	remove := &cmds.Command{
+		Aliases:   []string{"rm"},
+		Arguments: []cmds.Argument{cmds.StringArg("path", true, true, "a path")},
+	}
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{"remove": remove},
+	}
+
+	req := &cmds.Request{}
+	if err := parse(req, []string{"rm", "foo"}, root); err != nil {
+		t.Fatal(err)
+	}
+	if req.Command != remove {
+		t.Fatal("expected the alias to resolve to the remove command")
+	}
+	if len(req.Path) != 1 || req.Path[0] != "remove" {
+		t.Fatalf("expected the path to use the real name, got %q", req.Path)
+	}
+}
+
+func TestNegatedBoolParsing(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("color", "c", "use colors"),
+			cmds.StringOption("name", "a name"),
+		},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	testOptionHelper(t, cmd, "--color", kvs{"color": true}, words{}, false)
+	testOptionHelper(t, cmd, "--no-color", kvs{"color": false}, words{}, false)
+	testOptionHelper(t, cmd, "--no-color --color", kvs{}, words{}, true)
+	testOptionHelper(t, cmd, "--color --no-color", kvs{}, words{}, true)
+	testOptionHelper(t, cmd, "--no-name foo", kvs{}, words{}, true)
+	testOptionHelper(t, cmd, "--no-colour", kvs{}, words{}, true)
+
+	for _, args := range []string{"--color --no-color", "--no-color -c"} {
+		err := parse(&cmds.Request{}, strings.Split(args, " "), cmd)
+		if err == nil || err.Error() != "--color and --no-color can't be used together" {
+			t.Errorf("%s: expected a conflict error, got %v", args, err)
+		}
+	}
+}
+
+func TestCountOptionParsing(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.CountOption("verbose", "v", "be more verbose"),
+			cmds.BoolOption("quiet", "q", "be quiet"),
+		},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	testOptionHelper(t, cmd, "-v", kvs{"verbose": 1}, words{}, false)
+	testOptionHelper(t, cmd, "-v -v", kvs{"verbose": 2}, words{}, false)
+	testOptionHelper(t, cmd, "-vvv", kvs{"verbose": 3}, words{}, false)
+	testOptionHelper(t, cmd, "-vv -v", kvs{"verbose": 3}, words{}, false)
+	testOptionHelper(t, cmd, "-vqv", kvs{"verbose": 2, "quiet": true}, words{}, false)
+	testOptionHelper(t, cmd, "--verbose -v", kvs{"verbose": 2}, words{}, false)
+	testOptionHelper(t, cmd, "-v=2 -v", kvs{"verbose": 3}, words{}, false)
+
+	req := &cmds.Request{}
+	if err := parse(req, []string{"-vvv"}, cmd); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := req.Options.GetInt("verbose"); !ok || v != 3 {
+		t.Fatalf("expected a count of 3, got %d", v)
+	}
+}
+
+func TestStringMapOptionParsing(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringMapOption("label", "l", "a label"),
+		},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	parseLabels := func(args ...string) (map[string]string, error) {
+		req := &cmds.Request{}
+		if err := parse(req, args, cmd); err != nil {
+			return nil, err
+		}
+		labels, _ := req.Options.GetStringMap("label")
+		return labels, nil
+	}
+
+	labels, err := parseLabels("--label", "k1=v1", "-l", "k2=v2=x", "--label=k3=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"k1": "v1", "k2": "v2=x", "k3": ""}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v, got %v", expected, labels)
+	}
+
+	labels, err = parseLabels("--label", "k=old", "--label", "k=new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels["k"] != "new" || len(labels) != 1 {
+		t.Fatalf("expected the later value to win, got %v", labels)
+	}
+
+	_, err = parseLabels("--label", "nokey")
+	if err == nil || !strings.Contains(err.Error(), `"label"`) {
+		t.Fatalf("expected an error naming the option, got %v", err)
+	}
+}
+
+func TestEnumOptionParsing(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("format", "f", "a format").WithEnum("json", "text", "xml"),
+		},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	testOptionHelper(t, cmd, "--format xml", kvs{"format": "xml"}, words{}, false)
+	testOptionHelper(t, cmd, "-f=text", kvs{"format": "text"}, words{}, false)
+	testOptionHelper(t, cmd, "--format yaml", kvs{}, words{}, true)
+}
+
+func TestValidatedOptionParsing(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.IntOption("port", "p", "a port").WithValidator(func(v interface{}) error {
+				if p := v.(int); p < 1 || p > 65535 {
+					return fmt.Errorf("out of range")
+				}
+				return nil
+			}),
+		},
+		Subcommands: map[string]*cmds.Command{},
+	}
+
+	testOptionHelper(t, cmd, "--port 4001", kvs{"port": 4001}, words{}, false)
+	testOptionHelper(t, cmd, "-p 0", kvs{}, words{}, true)
+	testOptionHelper(t, cmd, "--port=65536", kvs{}, words{}, true)
+}
+
+func TestMutuallyExclusiveOptionParsing(t *testing.T) {
+	cmd := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("json", "j", "json output"),
+			cmds.BoolOption("xml", "xml output"),
+			cmds.BoolOption("quiet", "q", "be quiet"),
+		},
+		MutuallyExclusive: [][]string{{"json", "xml"}},
+		Subcommands:       map[string]*cmds.Command{},
+	}
+
+	testOptionHelper(t, cmd, "--json -q", kvs{"json": true, "quiet": true}, words{}, false)
+	testOptionHelper(t, cmd, "--xml", kvs{"xml": true}, words{}, false)
+	testOptionHelper(t, cmd, "-q", kvs{"quiet": true}, words{}, false)
+
+	req := &cmds.Request{}
+	err := parse(req, []string{"-j", "--xml"}, cmd)
+	if err == nil || err.Error() != "options --json and --xml are mutually exclusive" {
+		t.Fatalf("expected a mutually exclusive error, got %v", err)
+	}
+}
+
+func TestRequiredOptionParsing(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("api-url", "u", "the api").WithRequired(true),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"run": {
+				Options: []cmds.Option{
+					cmds.StringOption("mode", "a mode").WithRequired(true).WithDefault("fast"),
+				},
+			},
+		},
+	}
+
+	_, err := Parse(context.Background(), []string{"run"}, nil, root)
+	if err == nil || err.Error() != "missing required option --api-url" {
+		t.Fatalf("expected a missing required option error, got %v", err)
+	}
+
+	req, err := Parse(context.Background(), []string{"run", "-u", "http://localhost"}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Options["api-url"] != "http://localhost" {
+		t.Fatalf("unexpected api-url %v", req.Options["api-url"])
+	}
+	// --mode is satisfied by its default
+	if req.Options["mode"] != "fast" {
+		t.Fatalf("expected the default mode, got %v", req.Options["mode"])
+	}
+}
+
+func TestEnvVarOptionParsing(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.IntOption("retries", "r", "number of retries").WithEnvVar("MYTOOL_RETRIES").WithDefault(1),
+			cmds.StringsOption("peer", "peers").WithEnvVar("MYTOOL_PEERS"),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"run": {},
+		},
+	}
+
+	parseEnv := func(args ...string) (cmds.OptMap, error) {
+		req, err := Parse(context.Background(), args, nil, root)
+		return req.Options, err
+	}
+
+	// default without flag or environment variable
+	opts, err := parseEnv("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts["retries"] != 1 {
+		t.Errorf("expected the default, got %v", opts["retries"])
+	}
+
+	t.Setenv("MYTOOL_RETRIES", "5")
+	t.Setenv("MYTOOL_PEERS", "a")
+	opts, err = parseEnv("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts["retries"] != 5 {
+		t.Errorf("expected the environment to override the default, got %v", opts["retries"])
+	}
+	if peers, _ := opts["peer"].([]string); len(peers) != 1 || peers[0] != "a" {
+		t.Errorf("expected peers from the environment, got %v", opts["peer"])
+	}
+
+	opts, err = parseEnv("run", "-r", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts["retries"] != 7 {
+		t.Errorf("expected the flag to override the environment, got %v", opts["retries"])
+	}
+
+	t.Setenv("MYTOOL_RETRIES", "many")
+	if _, err := parseEnv("run"); err == nil || !strings.Contains(err.Error(), "MYTOOL_RETRIES") {
+		t.Errorf("expected a conversion error naming the variable, got %v", err)
+	}
+}
+
 func TestDefaultOptionParsing(t *testing.T) {
 	testPanic := func(f func()) {
 		fnFinished := false
@@ -413,6 +1057,48 @@ func TestArgumentParsing(t *testing.T) {
 
 }
 
+func TestVariadicMinCount(t *testing.T) {
+	rootCmd := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"add": {
+				Arguments: []cmds.Argument{
+					cmds.StringArg("dest", true, false, "destination"),
+					cmds.StringArg("files", true, true, "files to add").WithMinCount(2),
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		args   words
+		expect words
+		err    string
+	}{
+		{args: words{"add", "d", "a", "b"}, expect: words{"d", "a", "b"}},
+		{args: words{"add", "d", "a", "b", "c"}, expect: words{"d", "a", "b", "c"}},
+		{args: words{"add", "d", "a"}, err: "expected at least 2 arguments for <files>, got 1"},
+		{args: words{"add", "d"}, err: `argument "files" is required`},
+	} {
+		req, err := Parse(context.Background(), tc.args, nil, rootCmd)
+		if err == nil {
+			err = req.Command.CheckArguments(req)
+		}
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%v: expected error %q, got %v", tc.args, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.args, err)
+			continue
+		}
+		if !sameWords(req.Arguments, tc.expect) {
+			t.Errorf("%v: expected arguments %v, got %v", tc.args, tc.expect, req.Arguments)
+		}
+	}
+}
+
 func errEq(err1, err2 error) bool {
 	if err1 == nil && err2 == nil {
 		return true
@@ -681,6 +1367,207 @@ func TestBodyArgs(t *testing.T) {
 	}
 }
 
+func TestStdinFileArg(t *testing.T) {
+	rootCmd := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat": {
+				Arguments: []cmds.Argument{
+					cmds.FileArg("data", true, false, "data to read").EnableStdin(),
+				},
+			},
+		},
+	}
+
+	t.Run("piped", func(t *testing.T) {
+		f, err := os.CreateTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		if _, err := io.WriteString(f, "piped data"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := Parse(context.Background(), words{"cat"}, f, rootCmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		it := req.Files.Entries()
+		if !it.Next() {
+			t.Fatalf("expected a file argument: %v", it.Err())
+		}
+		data, err := io.ReadAll(files.ToFile(it.Node()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "piped data" {
+			t.Errorf("expected piped data, got %q", data)
+		}
+	})
+
+	t.Run("terminal", func(t *testing.T) {
+		tty, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tty.Close()
+		if isTerm, _ := isTty(tty); !isTerm {
+			t.Skipf("%s is not a character device", os.DevNull)
+		}
+
+		_, err = Parse(context.Background(), words{"cat"}, tty, rootCmd)
+		expected := `argument "data" is required; pass it or pipe it to stdin`
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %q, got %v", expected, err)
+		}
+	})
+}
+
+func TestFileArg(t *testing.T) {
+	rootCmd := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat": {
+				Arguments: []cmds.Argument{
+					cmds.FileArg("data", true, false, "data to read"),
+				},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(fpath, []byte("file data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdin, err := os.CreateTemp(dir, "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	if _, err := io.WriteString(stdin, "stdin data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdin.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path string
+		data string
+	}{
+		{path: fpath, data: "file data"},
+		{path: "-", data: "stdin data"},
+	} {
+		req, err := Parse(context.Background(), words{"cat", tc.path}, stdin, rootCmd)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.path, err)
+		}
+		it := req.Files.Entries()
+		if !it.Next() {
+			t.Fatalf("%s: expected a file argument: %v", tc.path, it.Err())
+		}
+		f := files.ToFile(it.Node())
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.data {
+			t.Errorf("%s: expected %q, got %q", tc.path, tc.data, data)
+		}
+
+		closeFileArgs(req.Files)
+		if tc.path != "-" {
+			if _, err := f.Seek(0, io.SeekStart); err == nil {
+				t.Errorf("%s: expected the file to be closed", tc.path)
+			}
+		}
+	}
+
+	missing := filepath.Join(dir, "missing.txt")
+	_, err = Parse(context.Background(), words{"cat", missing}, nil, rootCmd)
+	expected := fmt.Sprintf("argument %q: %s does not exist", "data", missing)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestRecursiveFileArg(t *testing.T) {
+	rootCmd := &cmds.Command{
+		Options: []cmds.Option{cmds.OptionRecursivePath, cmds.OptionHidden},
+		Subcommands: map[string]*cmds.Command{
+			"add": {
+				Arguments: []cmds.Argument{
+					cmds.FileArg("path", true, true, "paths to add").EnableRecursive(),
+				},
+			},
+		},
+	}
+
+	dir := filepath.Join(t.TempDir(), "tree")
+	for name, data := range map[string]string{
+		"a.txt":         "a",
+		"sub/b.txt":     "b",
+		"sub/.hidden":   "h",
+		"sub/deep/c.md": "c",
+	} {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fpath, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a symlink back to the root of the tree must not be followed
+	if err := os.Symlink("..", filepath.Join(dir, "sub", "loop")); err != nil {
+		t.Skipf("cannot create symlinks: %s", err)
+	}
+
+	walk := func(args ...string) []string {
+		req, err := Parse(context.Background(), words(append([]string{"add"}, args...)), nil, rootCmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeFileArgs(req.Files)
+
+		var paths []string
+		err = files.Walk(req.Files, func(fpath string, nd files.Node) error {
+			switch nd.(type) {
+			case *files.Symlink:
+				paths = append(paths, fpath+"@")
+			case files.File:
+				paths = append(paths, fpath)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+
+	expected := []string{"tree/a.txt", "tree/sub/b.txt", "tree/sub/deep/c.md", "tree/sub/loop@"}
+	if paths := walk("-r", dir); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	expected = []string{"tree/a.txt", "tree/sub/.hidden", "tree/sub/b.txt", "tree/sub/deep/c.md", "tree/sub/loop@"}
+	if paths := walk("-r", "-H", dir); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	_, err := Parse(context.Background(), words{"add", dir}, nil, rootCmd)
+	expectedErr := fmt.Sprintf(notRecursiveFmtStr, dir, cmds.RecShort)
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+}
+
 func Test_isURL(t *testing.T) {
 	for _, u := range []string{
 		"http://www.example.com",
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	terminal "golang.org/x/term"
+)
+
+// Prompter asks the user for the values of required options and arguments
+// that weren't passed.
+type Prompter interface {
+	// Prompt asks for a value described by label. Secret values must not be
+	// echoed back.
+	Prompt(label string, secret bool) (string, error)
+}
+
+// NewTerminalPrompter returns a Prompter writing prompts to out and reading
+// the answers from the terminal in.
+func NewTerminalPrompter(in *os.File, out io.Writer) Prompter {
+	return &terminalPrompter{in: in, r: bufio.NewReader(in), out: out}
+}
+
+type terminalPrompter struct {
+	in  *os.File
+	r   *bufio.Reader
+	out io.Writer
+}
+
+func (p *terminalPrompter) Prompt(label string, secret bool) (string, error) {
+	fmt.Fprintf(p.out, "%s: ", label)
+	if secret {
+		value, err := terminal.ReadPassword(int(p.in.Fd()))
+		// the newline typed by the user isn't echoed either
+		fmt.Fprintln(p.out)
+		return string(value), err
+	}
+
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+type prompterKey struct{}
+
+// WithPrompter returns a copy of ctx that makes Parse and Run ask p for the
+// required options and string arguments that are missing instead of failing.
+// Prompting only happens when stdin is a terminal.
+func WithPrompter(ctx context.Context, p Prompter) context.Context {
+	return context.WithValue(ctx, prompterKey{}, p)
+}
+
+// prompter returns the Prompter in the request context if stdin is a
+// terminal, nil otherwise.
+func prompter(req *cmds.Request, stdin *os.File) Prompter {
+	if req.Context == nil || stdin == nil {
+		return nil
+	}
+	p, _ := req.Context.Value(prompterKey{}).(Prompter)
+	if p == nil {
+		return nil
+	}
+	if tty, err := isTty(stdin); err != nil || !tty {
+		return nil
+	}
+	return p
+}
+
+// promptOption asks p for the value of the option opt. An empty answer leaves
+// the option unset.
+func promptOption(p Prompter, opt cmds.Option) (interface{}, bool, error) {
+	label := optionFlag(opt.Name())
+	if desc := strings.TrimSuffix(cmds.Details(opt).RawDescription(), "."); desc != "" {
+		label = fmt.Sprintf("%s (%s)", desc, label)
+	}
+	answer, err := p.Prompt(label, cmds.Details(opt).Secret())
+	if err != nil || answer == "" {
+		return nil, false, err
+	}
+	v, err := opt.Parse(answer)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid value for option %s: %w", optionFlag(opt.Name()), err)
+	}
+	return v, true, nil
+}
+
+// promptArgument asks p for the value of the string argument argDef. An
+// empty answer leaves the argument unset.
+func promptArgument(p Prompter, argDef cmds.Argument) (string, error) {
+	label := fmt.Sprintf("<%s>", argDef.Name)
+	if argDef.Description != "" {
+		label = fmt.Sprintf("%s (%s)", argDef.Description, label)
+	}
+	return p.Prompt(label, false)
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+type prompt struct {
+	label  string
+	secret bool
+}
+
+// testPrompter answers prompts in order and records them.
+type testPrompter struct {
+	answers []string
+	prompts []prompt
+}
+
+func (p *testPrompter) Prompt(label string, secret bool) (string, error) {
+	p.prompts = append(p.prompts, prompt{label, secret})
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return answer, nil
+}
+
+func promptRoot() *cmds.Command {
+	return &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"login": {
+				Options: []cmds.Option{
+					cmds.StringOption("user", "u", "the user name").WithRequired(true),
+					cmds.StringOption("password", "the password").WithRequired(true).WithSecret(true),
+				},
+				Arguments: []cmds.Argument{
+					cmds.StringArg("server", true, false, "the server to log in to"),
+				},
+			},
+		},
+	}
+}
+
+func TestPromptTerminal(t *testing.T) {
+	tty, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tty.Close()
+	if isTerm, _ := isTty(tty); !isTerm {
+		t.Skipf("%s is not a character device", os.DevNull)
+	}
+
+	p := &testPrompter{answers: []string{"hunter2", "alice", "example.com"}}
+	req, err := Parse(WithPrompter(context.Background(), p), []string{"login"}, tty, promptRoot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []prompt{
+		{"the password (--password)", true},
+		{"the user name (--user)", false},
+		{"the server to log in to (<server>)", false},
+	}
+	if !reflect.DeepEqual(p.prompts, expected) {
+		t.Errorf("expected prompts %v, got %v", expected, p.prompts)
+	}
+	if req.Options["user"] != "alice" || req.Options["password"] != "hunter2" {
+		t.Errorf("unexpected options %v", req.Options)
+	}
+	if !reflect.DeepEqual(req.Arguments, []string{"example.com"}) {
+		t.Errorf("unexpected arguments %v", req.Arguments)
+	}
+
+	// passed values aren't asked for, and an empty answer is still missing
+	p = &testPrompter{answers: []string{""}}
+	_, err = Parse(WithPrompter(context.Background(), p), []string{"login", "-u", "alice"}, tty, promptRoot())
+	if err == nil || err.Error() != "missing required option --password" {
+		t.Errorf("expected a missing required option error, got %v", err)
+	}
+	if len(p.prompts) != 1 {
+		t.Errorf("expected a single prompt, got %v", p.prompts)
+	}
+}
+
+func TestPromptNotTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p := &testPrompter{}
+	_, err = Parse(WithPrompter(context.Background(), p), []string{"login"}, f, promptRoot())
+	if err == nil || err.Error() != "missing required option --password" {
+		t.Errorf("expected a missing required option error, got %v", err)
+	}
+	if len(p.prompts) != 0 {
+		t.Errorf("expected no prompts, got %v", p.prompts)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 )
@@ -17,11 +18,14 @@ var _ ResponseEmitter = &responseEmitter{}
 func NewResponseEmitter(stdout, stderr io.Writer, req *cmds.Request) (ResponseEmitter, error) {
 	encType, enc, err := cmds.GetEncoder(req, stdout, cmds.TextNewline)
 
+	timeout, _ := req.Options.GetDuration(cmds.TimeoutOpt)
+
 	return &responseEmitter{
 		stdout:  stdout,
 		stderr:  stderr,
 		encType: encType,
 		enc:     enc,
+		timeout: timeout,
 	}, err
 }
 
@@ -49,6 +53,7 @@ type responseEmitter struct {
 	encType cmds.EncodingType
 	exit    int
 	closed  bool
+	timeout time.Duration
 }
 
 func (re *responseEmitter) Type() cmds.PostRunType {
@@ -82,14 +87,16 @@ func (re *responseEmitter) CloseWithError(err error) error {
 	var msg string
 	if err != nil {
 		if re.exit == 0 {
-			// Default "error" exit code.
-			re.exit = 1
+			re.exit = ExitCode(err)
 		}
 		switch err {
 		case context.Canceled:
 			msg = "canceled"
 		case context.DeadlineExceeded:
 			msg = "timed out"
+			if re.timeout > 0 {
+				msg = fmt.Sprintf("timed out after %s", re.timeout)
+			}
 		default:
 			msg = err.Error()
 		}
@@ -102,6 +109,13 @@ func (re *responseEmitter) CloseWithError(err error) error {
 		re.stderr = nil
 	}()
 
+	// encoders that wrap the output in a document, like XML, finish it here
+	if c, ok := re.enc.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+
 	var errStderr, errStdout error
 	if f, ok := re.stderr.(*os.File); ok {
 		errStderr = f.Sync()
@@ -2,7 +2,12 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -74,6 +79,16 @@ func TestCloseWithError(t *testing.T) {
 				}
 			},
 		},
+		{
+			stdout:   bytes.NewBuffer(nil),
+			stderr:   bytes.NewBuffer(nil),
+			exStdout: "",
+			exStderr: "Error: no such key\n",
+			exExit:   4,
+			f: func(re ResponseEmitter, t *testing.T) {
+				re.CloseWithError(cmds.Errorf(cmds.ErrNotFound, "no such key"))
+			},
+		},
 	}
 
 	for i, tc := range tcs {
@@ -81,3 +96,131 @@ func TestCloseWithError(t *testing.T) {
 		tc.Run(t)
 	}
 }
+
+type encodedValue struct {
+	Name string
+	Size int
+}
+
+// encodingRoot emits the values given as arguments, one by one.
+var encodingRoot = &cmds.Command{
+	Options: []cmds.Option{cmds.OptionEncodingType},
+	Subcommands: map[string]*cmds.Command{
+		"emit": {
+			Arguments: []cmds.Argument{cmds.StringArg("names", true, true, "names to emit")},
+			Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+				for i, name := range req.Arguments {
+					if err := re.Emit(&encodedValue{Name: name, Size: i}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Type: encodedValue{},
+		},
+	},
+}
+
+func runEncoding(t *testing.T, args ...string) string {
+	t.Helper()
+
+	req, err := Parse(context.Background(), args, nil, encodingRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	re, err := NewResponseEmitter(&stdout, &stderr, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmds.NewExecutor(encodingRoot).Execute(req, re, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error output: %s", stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestJSONEncoding(t *testing.T) {
+	out := runEncoding(t, "emit", "--enc=json", "a")
+	var v encodedValue
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		t.Fatalf("expected a JSON document, got %q: %s", out, err)
+	}
+	if v != (encodedValue{Name: "a"}) {
+		t.Errorf("unexpected value %+v", v)
+	}
+
+	out = runEncoding(t, "emit", "--enc=json", "a", "b", "c")
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected one line per value, got %q", out)
+	}
+	for i, line := range lines {
+		var v encodedValue
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("expected line %d to be a JSON document, got %q: %s", i, line, err)
+		}
+		if v.Size != i {
+			t.Errorf("expected value %d, got %+v", i, v)
+		}
+	}
+}
+
+func TestXMLEncoding(t *testing.T) {
+	out := runEncoding(t, "emit", "--enc=xml", "a")
+	if expected := "<Values><Value><Name>a</Name><Size>0</Size></Value></Values>"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	out = runEncoding(t, "emit", "--enc=xml", "a", "b", "c")
+	var doc struct {
+		XMLName xml.Name       `xml:"Values"`
+		Values  []encodedValue `xml:"Value"`
+	}
+	if err := xml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("expected a single XML document, got %q: %s", out, err)
+	}
+	expected := []encodedValue{{Name: "a"}, {Name: "b", Size: 1}, {Name: "c", Size: 2}}
+	if !reflect.DeepEqual(doc.Values, expected) {
+		t.Errorf("expected %+v, got %+v", expected, doc.Values)
+	}
+}
+
+func TestDefaultEncoding(t *testing.T) {
+	defaultRoot := &cmds.Command{
+		Options: []cmds.Option{cmds.OptionEncodingType},
+		Subcommands: map[string]*cmds.Command{
+			"ls":  {},
+			"api": {DefaultEncoding: cmds.JSON},
+		},
+	}
+
+	for _, tc := range []struct {
+		args     words
+		expected cmds.EncodingType
+	}{
+		{args: words{"ls"}, expected: cmds.Text},
+		{args: words{"api"}, expected: cmds.JSON},
+		{args: words{"api", "--enc=xml"}, expected: cmds.XML},
+	} {
+		req, err := Parse(context.Background(), tc.args, nil, defaultRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enc := cmds.GetEncoding(req, ""); enc != tc.expected {
+			t.Errorf("%v: expected encoding %s, got %s", tc.args, tc.expected, enc)
+		}
+	}
+
+	req, err := Parse(context.Background(), words{"api", "--enc=yaml"}, nil, defaultRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := NewResponseEmitter(&buf, &buf, req); err == nil || err.Error() != "invalid encoding: yaml" {
+		t.Errorf("expected an invalid encoding error, got %v", err)
+	}
+}
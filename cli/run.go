@@ -2,11 +2,12 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
-	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 )
@@ -18,6 +19,56 @@ func (e ExitError) Error() string {
 	return fmt.Sprintf("exit code %d", int(e))
 }
 
+// ExitTimeout is returned by Run when the command didn't finish before the
+// deadline set with --timeout.
+const ExitTimeout ExitError = 124
+
+// exitCodes are the exit codes for the error types of cmds.Error. Errors
+// without one exit with 1.
+var exitCodes = map[cmds.ErrorType]int{
+	cmds.ErrNormal:         1,
+	cmds.ErrClient:         2,
+	cmds.ErrImplementation: 3,
+	cmds.ErrNotFound:       4,
+	cmds.ErrRateLimited:    5,
+	cmds.ErrForbidden:      6,
+}
+
+// ExitCode returns the process exit code for an error returned by Run or
+// emitted by a command: the code of an ExitError, ExitTimeout for an exceeded
+// deadline, the code of ErrClient for a cmds.ArgError, a code depending on the
+// type of a cmds.Error, or 1 for any other error.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr ExitError
+	if errors.As(err, &exitErr) {
+		return int(exitErr)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return int(ExitTimeout)
+	}
+	var argErr *cmds.ArgError
+	if errors.As(err, &argErr) {
+		return exitCodes[cmds.ErrClient]
+	}
+	var cmdErr *cmds.Error
+	if errors.As(err, &cmdErr) {
+		if code, ok := exitCodes[cmdErr.Code]; ok {
+			return code
+		}
+	}
+	var cmdErrVal cmds.Error
+	if errors.As(err, &cmdErrVal) {
+		if code, ok := exitCodes[cmdErrVal.Code]; ok {
+			return code
+		}
+	}
+	return 1
+}
+
 // Closer is a helper interface to check if the env supports closing
 type Closer interface {
 	Close()
@@ -28,25 +79,47 @@ func Run(ctx context.Context, root *cmds.Command,
 	buildEnv cmds.MakeEnvironment, makeExecutor cmds.MakeExecutor) error {
 
 	printErr := func(err error) {
+		// argument errors say where the error is themselves
+		var argErr *cmds.ArgError
+		if errors.As(err, &argErr) {
+			fmt.Fprintln(stderr, argErr)
+			return
+		}
 		fmt.Fprintf(stderr, "Error: %s\n", err)
 	}
 
-	req, errParse := Parse(ctx, cmdline[1:], stdin, root)
+	// The completion scripts call the hidden __complete subcommand, unless
+	// the tree has a command of that name itself.
+	if len(cmdline) > 1 && cmdline[1] == completeCmd && root.Subcommands[completeCmd] == nil {
+		return Complete(root, cmdline[2:], stdout)
+	}
+
+	req, errParse := parseRequest(ctx, cmdline[1:], stdin, stderr, root)
 
 	// Handle the timeout up front.
 	var cancel func()
-	if timeoutStr, ok := req.Options[cmds.TimeoutOpt]; ok {
-		timeout, err := time.ParseDuration(timeoutStr.(string))
-		if err != nil {
-			printErr(err)
-			return err
-		}
+	timeout, err := req.Options.GetDurationE(cmds.TimeoutOpt)
+	switch {
+	case err == nil:
 		req.Context, cancel = context.WithTimeout(req.Context, timeout)
-	} else {
+	case errors.Is(err, cmds.ErrOptionNotSet):
 		req.Context, cancel = context.WithCancel(req.Context)
+	default:
+		printErr(err)
+		return err
 	}
 	defer cancel()
 
+	// Cancel the request on the first interrupt and exit on the second.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, cancelSignals...)
+	sigDone := make(chan struct{})
+	defer func() {
+		signal.Stop(sigCh)
+		close(sigDone)
+	}()
+	go handleSignals(sigCh, sigDone, cancel, os.Exit, stderr)
+
 	// this is a message to tell the user how to get the help text
 	printMetaHelp := func(w io.Writer) {
 		cmdPath := strings.Join(req.Path, " ")
@@ -72,7 +145,7 @@ func Run(ctx context.Context, root *cmds.Command,
 
 	// BEFORE handling the parse error, if we have enough information
 	// AND the user requested help, print it out and exit
-	err := HandleHelp(cmdline[0], req, stdout)
+	err = HandleHelp(cmdline[0], req, stdout)
 	if err == nil {
 		return nil
 	} else if err != ErrNoHelpRequested {
@@ -94,6 +167,12 @@ func Run(ctx context.Context, root *cmds.Command,
 		return errParse
 	}
 
+	// the files opened for file arguments are only needed until the
+	// command returns
+	if req != nil && req.Files != nil {
+		defer closeFileArgs(req.Files)
+	}
+
 	// here we handle the cases where
 	// - commands with no Run func are invoked directly.
 	// - the main command is invoked.
@@ -134,7 +213,13 @@ func Run(ctx context.Context, root *cmds.Command,
 	}
 
 	// Execute the command.
+	// A timeout emitted by the command is reported by the response emitter,
+	// which also sets the exit status.
 	err = exctr.Execute(req, re, env)
+	if errors.Is(err, context.DeadlineExceeded) {
+		printErr(fmt.Errorf("timed out after %s", timeout))
+		return ExitTimeout
+	}
 	// If we get an error here, don't bother reading the status from the
 	// response emitter. It may not even be closed.
 	if err != nil {
@@ -143,7 +228,8 @@ func Run(ctx context.Context, root *cmds.Command,
 		if kiterr, ok := err.(*cmds.Error); ok {
 			err = *kiterr
 		}
-		if kiterr, ok := err.(cmds.Error); ok && kiterr.Code == cmds.ErrClient {
+		var argErr *cmds.ArgError
+		if kiterr, ok := err.(cmds.Error); ok && kiterr.Code == cmds.ErrClient || errors.As(err, &argErr) {
 			printMetaHelp(stderr)
 		}
 
@@ -2,7 +2,10 @@ package cli
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -58,3 +61,261 @@ func TestRunWaits(t *testing.T) {
 		t.Fatal("expected flag to be raised")
 	}
 }
+
+func TestRunCancel(t *testing.T) {
+	cancelRoot := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"wait": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, e cmds.Environment) error {
+					<-req.Context.Done()
+					return req.Context.Err()
+				},
+			},
+		},
+	}
+
+	devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(
+			ctx,
+			cancelRoot,
+			[]string{"test", "wait"},
+			devnull, devnull, devnull,
+			func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
+				return nil, nil
+			},
+			func(req *cmds.Request, env interface{}) (cmds.Executor, error) {
+				return cmds.NewExecutor(req.Root), nil
+			},
+		)
+	}()
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Run to return after the context was cancelled")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	timeoutRoot := &cmds.Command{
+		Options: []cmds.Option{cmds.OptionTimeout},
+		Subcommands: map[string]*cmds.Command{
+			"sleep": {
+				Arguments: []cmds.Argument{cmds.StringArg("duration", true, false, "how long to sleep")},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, e cmds.Environment) error {
+					d, err := time.ParseDuration(req.Arguments[0])
+					if err != nil {
+						return err
+					}
+					select {
+					case <-time.After(d):
+						return cmds.EmitOnce(re, "done")
+					case <-req.Context.Done():
+						return req.Context.Err()
+					}
+				},
+			},
+			"work": {
+				Arguments: []cmds.Argument{cmds.StringArg("duration", true, false, "how long to work")},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, e cmds.Environment) error {
+					d, err := time.ParseDuration(req.Arguments[0])
+					if err != nil {
+						return err
+					}
+					// finishes even when the deadline passes in the meantime
+					time.Sleep(d)
+					return cmds.EmitOnce(re, "done")
+				},
+			},
+		},
+	}
+
+	run := func(args ...string) (string, error) {
+		devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer devnull.Close()
+		stderr, err := os.CreateTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(stderr.Name())
+		defer stderr.Close()
+
+		err = Run(
+			context.Background(),
+			timeoutRoot,
+			append([]string{"test"}, args...),
+			devnull, devnull, stderr,
+			func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
+				return nil, nil
+			},
+			func(req *cmds.Request, env interface{}) (cmds.Executor, error) {
+				return cmds.NewExecutor(req.Root), nil
+			},
+		)
+		out, rerr := os.ReadFile(stderr.Name())
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		return string(out), err
+	}
+
+	if out, err := run("sleep", "--timeout=5s", "1ms"); err != nil {
+		t.Fatalf("expected command to finish in time, got %v: %s", err, out)
+	}
+
+	out, err := run("sleep", "--timeout=10ms", "5s")
+	if err != ExitTimeout {
+		t.Fatalf("expected %v, got %v", ExitTimeout, err)
+	}
+	if !strings.Contains(out, "timed out after 10ms") {
+		t.Errorf("expected a timed out message, got %q", out)
+	}
+
+	if out, err := run("work", "--timeout=1ms", "20ms"); err != nil {
+		t.Errorf("expected a command that succeeded to not time out, got %v: %s", err, out)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{nil, 0},
+		{errors.New("plain"), 1},
+		{cmds.Errorf(cmds.ErrNormal, "normal"), 1},
+		{cmds.Errorf(cmds.ErrClient, "usage"), 2},
+		{&cmds.Error{Message: "bug", Code: cmds.ErrImplementation}, 3},
+		{cmds.Errorf(cmds.ErrNotFound, "missing"), 4},
+		{&cmds.Error{Message: "denied", Code: cmds.ErrForbidden}, 6},
+		{fmt.Errorf("wrapped: %w", cmds.Errorf(cmds.ErrNotFound, "missing")), 4},
+		{&cmds.ArgError{Name: "count", Position: 1, Value: "x", Type: cmds.Int}, 2},
+		{ExitError(7), 7},
+		{ExitTimeout, 124},
+		{context.DeadlineExceeded, 124},
+	} {
+		if code := ExitCode(tc.err); code != tc.code {
+			t.Errorf("%v: expected exit code %d, got %d", tc.err, tc.code, code)
+		}
+	}
+}
+
+func TestRunAuthorize(t *testing.T) {
+	authRoot := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("user", "who is asking"),
+		},
+		Authorize: func(req *cmds.Request) error {
+			if user, _ := req.Options["user"].(string); user != "admin" {
+				return errors.New("admins only")
+			}
+			return nil
+		},
+		Subcommands: map[string]*cmds.Command{
+			"status": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, "ok")
+				},
+			},
+		},
+	}
+
+	devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+
+	for _, tc := range []struct {
+		user string
+		code int
+	}{
+		{"admin", 0},
+		{"guest", 6},
+	} {
+		err := Run(
+			context.Background(),
+			authRoot,
+			[]string{"test", "status", "--user", tc.user},
+			devnull, devnull, devnull,
+			func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
+				return nil, nil
+			},
+			func(req *cmds.Request, env interface{}) (cmds.Executor, error) {
+				return cmds.NewExecutor(req.Root), nil
+			},
+		)
+		if code := ExitCode(err); code != tc.code {
+			t.Errorf("as %s: expected exit code %d, got %d (%v)", tc.user, tc.code, code, err)
+		}
+	}
+}
+
+func TestRunArgError(t *testing.T) {
+	argRoot := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"head": {
+				Arguments: []cmds.Argument{
+					cmds.StringArg("file", true, false, "file to read"),
+					cmds.StringArg("count", true, false, "number of lines").WithValueType(cmds.Int),
+				},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, "ok")
+				},
+			},
+		},
+	}
+
+	devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	err = Run(
+		context.Background(),
+		argRoot,
+		[]string{"test", "head", "log.txt", "abc"},
+		devnull, devnull, stderr,
+		func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
+			return nil, nil
+		},
+		func(req *cmds.Request, env interface{}) (cmds.Executor, error) {
+			return cmds.NewExecutor(req.Root), nil
+		},
+	)
+	var argErr *cmds.ArgError
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected an ArgError, got %v", err)
+	}
+	if code := ExitCode(err); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+
+	out, err := os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `error in argument 2 (count): "abc" is not an integer` + "\n"
+	if !strings.HasPrefix(string(out), expected) {
+		t.Errorf("expected output to start with %q, got %q", expected, out)
+	}
+}
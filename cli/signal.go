@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// handleSignals calls cancel when the first signal is received on sigCh, so
+// commands can stop by watching their request context. A second signal calls
+// exit, for commands that don't stop in time. It returns once done is closed.
+func handleSignals(sigCh <-chan os.Signal, done <-chan struct{}, cancel func(), exit func(int), stderr io.Writer) {
+	select {
+	case sig := <-sigCh:
+		fmt.Fprintf(stderr, "Received %s, stopping... (send again to force)\n", sig)
+		cancel()
+	case <-done:
+		return
+	}
+
+	select {
+	case <-sigCh:
+		exit(1)
+	case <-done:
+	}
+}
@@ -0,0 +1,6 @@
+package cli
+
+import "os"
+
+// cancelSignals are the signals that cancel a running command.
+var cancelSignals = []os.Signal{os.Interrupt}
@@ -0,0 +1,11 @@
+//go:build !plan9
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+// cancelSignals are the signals that cancel a running command.
+var cancelSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
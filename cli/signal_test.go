@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHandleSignals(t *testing.T) {
+	sigCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	cancelled := make(chan struct{})
+	exited := make(chan int, 1)
+
+	go handleSignals(sigCh, done, func() { close(cancelled) }, func(code int) { exited <- code }, io.Discard)
+
+	sigCh <- os.Interrupt
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the first signal to cancel the request")
+	}
+	select {
+	case <-exited:
+		t.Fatal("expected the first signal to not exit")
+	default:
+	}
+
+	sigCh <- os.Interrupt
+	select {
+	case code := <-exited:
+		if code == 0 {
+			t.Error("expected a non-zero exit code")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the second signal to exit")
+	}
+	close(done)
+}
+
+func TestHandleSignalsDone(t *testing.T) {
+	sigCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	returned := make(chan struct{})
+
+	go func() {
+		handleSignals(sigCh, done, func() { t.Error("unexpected cancel") }, func(int) { t.Error("unexpected exit") }, io.Discard)
+		close(returned)
+	}()
+
+	close(done)
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected handleSignals to return once done")
+	}
+}
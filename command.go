@@ -11,7 +11,9 @@ package cmds
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/ipfs/boxo/files"
 
@@ -57,22 +59,76 @@ type Command struct {
 	// available for reading after the HTTP connection has been written to.
 	Run Function
 
+	// DryRun is called instead of Run when the request is a
+	// dry run. It should describe what Run would do without doing it.
+	// Commands without a DryRun function refuse dry runs.
+	DryRun Function
+
 	// PostRun is run after Run, and can transform results returned by run.
 	// When executing a command on a remote daemon, PostRun is always run in
 	// the local process.
 	PostRun PostRunMap
 
+	// Authorize is called before PreRun and Run, by the executor as well as
+	// by Call, and fails the request with the error it returns. Commands
+	// without an Authorize function inherit the one of their closest parent.
+	// Errors that aren't a cmds.Error are reported as ErrForbidden.
+	Authorize func(req *Request) error
+
+	// PreRunHook is called before Run, inside the middlewares of the
+	// executor. The hooks of all commands on the path are called, starting
+	// with the root command. An error aborts the command before Run is called.
+	PreRunHook func(req *Request) error
+
+	// PostRunHook is called after Run with the error Run
+	// returned, and returns the error the command should fail with. It is
+	// also called when a PreRunHook of a subcommand fails, so it can be used
+	// to release what PreRunHook set up. The hooks are called in the reverse
+	// order of the PreRunHooks.
+	PostRunHook func(req *Request, err error) error
+
 	// Encoders encode results from Run (and/or PostRun) in the desired
 	// encoding.
 	Encoders EncoderMap
 
+	// DefaultEncoding is the encoding used by the CLI when --encoding isn't
+	// passed. It must be in Encoders or in the global Encoders.
+	DefaultEncoding EncodingType
+
+	// TextTemplate is a text/template that the text encoding executes with
+	// every emitted value. It's ignored if Encoders has a text encoder.
+	TextTemplate string
+
 	// Helptext is the command's help text.
 	Helptext HelpText
 
+	// HelpTemplate is a text/template the long help of the command is
+	// written with instead of the default one. It's executed with the same
+	// data.
+	HelpTemplate string
+
+	// Examples are worked examples listed in the EXAMPLES section of the
+	// long help.
+	Examples []Example
+
+	// SeeAlso are the paths of related commands listed in the SEE ALSO
+	// section of the long help, e.g. "config show".
+	SeeAlso []string
+
 	// External denotes that a command is actually an external binary.
 	// fewer checks and validations will be performed on such commands.
 	External bool
 
+	// AllowUnknownOptions makes the command line parser collect the options
+	// it doesn't know in Request.ExtraOptions instead of failing, so Run can
+	// pass them on, for example to a sub-process.
+	AllowUnknownOptions bool
+
+	// ArgFiles makes the command line parser replace positional arguments of
+	// the form @path with the lines of that file. An argument starting with
+	// @@ is passed on without the first @.
+	ArgFiles bool
+
 	// Type describes the type of the output of the Command's Run Function.
 	// In precise terms, the value of Type is an instance of the return type of
 	// the Run Function.
@@ -97,9 +153,49 @@ type Command struct {
 	// NoLocal denotes that a command cannot be executed in a local environment
 	NoLocal bool
 
+	// Idempotent denotes that running the command more than once has the
+	// same effect as running it once, so clients may retry it after the
+	// request was sent.
+	Idempotent bool
+
+	// MaxBodySize limits the size of the request body, in bytes, when the
+	// command is run over HTTP. It overrides the limit of the server, and a
+	// negative value lifts that limit for the command.
+	MaxBodySize int64
+
+	// MinVersion is the oldest version of HTTP clients that may run the
+	// command and its subcommands, e.g. "0.5.0". Clients send their version
+	// in the http.ClientVersionHeader header. The CLI ignores it.
+	MinVersion string
+
+	// Paginated commands are paged according to the
+	// --limit and --offset options, which have to be among the options of
+	// the command or its parents (see OptionLimit and OptionOffset).
+	Paginated bool
+
 	// Status of the command showed in the help.
 	Status Status
 
+	// Hidden commands can be invoked but are not listed in the help of
+	// their parent command.
+	Hidden bool
+
+	// Deprecated is shown next to the command in the help of its parent
+	// and printed as a warning when the command is used.
+	Deprecated string
+
+	// Category groups the command with its siblings of the same category in
+	// the help of its parent.
+	Category string
+
+	// Aliases are alternative names the command can be invoked by. The names
+	// in the Subcommands map of the parent take precedence over aliases.
+	Aliases []string
+
+	// MutuallyExclusive lists sets of option names of which at most one may
+	// be passed at a time, e.g. [][]string{{"json", "xml"}}.
+	MutuallyExclusive [][]string
+
 	// Extra contains a set of other command-specific parameters
 	Extra *Extra
 }
@@ -186,7 +282,61 @@ func (c *Command) call(req *Request, re ResponseEmitter, env Environment) error
 		return err
 	}
 
-	return cmd.Run(req, re, env)
+	if err := c.authorize(req); err != nil {
+		return err
+	}
+
+	run, err := cmd.function(req)
+	if err != nil {
+		return err
+	}
+	return c.withHooks(req, cmd, run)(req, re, env)
+}
+
+// function returns the Function to call for req, which is Run or, if req is
+// a dry run, DryRun, paged if the command is Paginated. It sets req.DryRun
+// if the dry-run option is set.
+func (c *Command) function(req *Request) (Function, error) {
+	if dryRun, _ := req.Options.GetBool(DryRunOpt); dryRun {
+		req.DryRun = true
+	}
+	run := c.Run
+	if req.DryRun {
+		if c.DryRun == nil {
+			return nil, Errorf(ErrClient, "%s does not support --%s", strings.Join(req.Path, " "), DryRunOpt)
+		}
+		run = c.DryRun
+	}
+	if c.Paginated {
+		run = paginate(run)
+	}
+	return run, nil
+}
+
+// authorize calls the Authorize function of the command at the path of req
+// or, if it has none, the one of its closest parent.
+func (c *Command) authorize(req *Request) error {
+	cmds, err := c.Resolve(req.Path)
+	if err != nil {
+		return err
+	}
+
+	for i := len(cmds) - 1; i >= 0; i-- {
+		if cmds[i].Authorize == nil {
+			continue
+		}
+		err := cmds[i].Authorize(req)
+		if err == nil {
+			return nil
+		}
+		var cmdErr Error
+		var cmdErrPtr *Error
+		if errors.As(err, &cmdErr) || errors.As(err, &cmdErrPtr) {
+			return err
+		}
+		return &Error{Message: err.Error(), Code: ErrForbidden}
+	}
+	return nil
 }
 
 // Resolve returns the subcommands at the given path
@@ -197,7 +347,7 @@ func (c *Command) Resolve(pth []string) ([]*Command, error) {
 
 	cmd := c
 	for i, name := range pth {
-		cmd = cmd.Subcommands[name]
+		_, cmd = cmd.Subcommand(name)
 
 		if cmd == nil {
 			pathS := strings.Join(pth[:i], "/")
@@ -210,6 +360,31 @@ func (c *Command) Resolve(pth []string) ([]*Command, error) {
 	return cmds, nil
 }
 
+// Subcommand returns the subcommand of c with the given name or, if there is
+// none, the subcommand having name as an alias. The name the subcommand is
+// registered under is returned along with it. If no subcommand matches, nil is
+// returned.
+func (c *Command) Subcommand(name string) (string, *Command) {
+	if sub, ok := c.Subcommands[name]; ok {
+		return name, sub
+	}
+
+	names := make([]string, 0, len(c.Subcommands))
+	for subName := range c.Subcommands {
+		names = append(names, subName)
+	}
+	sort.Strings(names)
+
+	for _, subName := range names {
+		for _, alias := range c.Subcommands[subName].Aliases {
+			if alias == name {
+				return subName, c.Subcommands[subName]
+			}
+		}
+	}
+	return "", nil
+}
+
 // Get resolves and returns the Command addressed by path
 func (c *Command) Get(path []string) (*Command, error) {
 	cmds, err := c.Resolve(path)
@@ -219,6 +394,36 @@ func (c *Command) Get(path []string) (*Command, error) {
 	return cmds[len(cmds)-1], nil
 }
 
+// GetFold is like Get, but matches the names of subcommands case-insensitively
+// if there is no exact match. It fails if a name matches several subcommands
+// that only differ in case.
+func (c *Command) GetFold(path []string) (*Command, error) {
+	cmd := c
+	for i, name := range path {
+		if _, sub := cmd.Subcommand(name); sub != nil {
+			cmd = sub
+			continue
+		}
+
+		var matches []string
+		for subName := range cmd.Subcommands {
+			if strings.EqualFold(subName, name) {
+				matches = append(matches, subName)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("undefined command: %q", strings.Join(path[:i+1], "/"))
+		case 1:
+			cmd = cmd.Subcommands[matches[0]]
+		default:
+			sort.Strings(matches)
+			return nil, fmt.Errorf("ambiguous command %q, could be any of %s", name, strings.Join(matches, ", "))
+		}
+	}
+	return cmd, nil
+}
+
 // GetOptions returns the options in the given path of commands
 func (c *Command) GetOptions(path []string) (map[string]Option, error) {
 	options := make([]Option, 0, len(c.Options))
@@ -234,7 +439,7 @@ func (c *Command) GetOptions(path []string) (map[string]Option, error) {
 
 	optionsMap := make(map[string]Option)
 	for _, opt := range options {
-		for _, name := range opt.Names() {
+		for _, name := range allNames(opt) {
 			if _, found := optionsMap[name]; found {
 				return nil, fmt.Errorf("option name %q used multiple times", name)
 			}
@@ -246,6 +451,13 @@ func (c *Command) GetOptions(path []string) (map[string]Option, error) {
 	return optionsMap, nil
 }
 
+// allNames returns the names and the aliases of opt.
+func allNames(opt Option) []string {
+	names := make([]string, 0, len(opt.Names())+len(Details(opt).Aliases()))
+	names = append(names, opt.Names()...)
+	return append(names, Details(opt).Aliases()...)
+}
+
 // DebugValidate checks if the command tree is well-formed.
 //
 // This operation is slow and should be called from tests only.
@@ -256,17 +468,25 @@ func (c *Command) DebugValidate() map[string][]error {
 	liveOptions := make(map[string]struct{})
 	visit = func(path string, cm *Command) {
 		expectOptional := false
+		variadic := 0
 		for i, argDef := range cm.Arguments {
 			// No required arguments after optional arguments.
 			if argDef.Required {
 				if expectOptional {
 					errs[path] = append(errs[path], fmt.Errorf("required argument %s after optional arguments", argDef.Name))
-					return
 				}
 			} else {
 				expectOptional = true
 			}
 
+			// at most one variadic argument
+			if argDef.Variadic {
+				variadic++
+				if variadic == 2 {
+					errs[path] = append(errs[path], fmt.Errorf("more than one variadic argument"))
+				}
+			}
+
 			// variadic arguments and those supporting stdin must be last
 			if (argDef.Variadic || argDef.SupportsStdin) && i != len(cm.Arguments)-1 {
 				errs[path] = append(errs[path], fmt.Errorf("variadic and/or optional argument %s must be last", argDef.Name))
@@ -275,7 +495,7 @@ func (c *Command) DebugValidate() map[string][]error {
 
 		var goodOptions []string
 		for _, option := range cm.Options {
-			for _, name := range option.Names() {
+			for _, name := range allNames(option) {
 				if _, ok := liveOptions[name]; ok {
 					errs[path] = append(errs[path], fmt.Errorf("duplicate option name %s", name))
 				} else {
@@ -284,6 +504,39 @@ func (c *Command) DebugValidate() map[string][]error {
 				}
 			}
 		}
+		for _, set := range cm.MutuallyExclusive {
+			for _, name := range set {
+				if _, ok := liveOptions[name]; !ok {
+					errs[path] = append(errs[path], fmt.Errorf("unknown option %s in mutually exclusive set", name))
+				}
+			}
+		}
+
+		if enc := cm.DefaultEncoding; enc != "" {
+			if _, ok := cm.Encoders[enc]; !ok {
+				if _, ok := Encoders[enc]; !ok {
+					errs[path] = append(errs[path], fmt.Errorf("no encoder for default encoding %s", enc))
+				}
+			}
+		}
+		if cm.TextTemplate != "" {
+			if _, err := template.New(path).Parse(cm.TextTemplate); err != nil {
+				errs[path] = append(errs[path], fmt.Errorf("invalid text template: %w", err))
+			}
+		}
+
+		aliased := make(map[string]string)
+		for scName, sc := range cm.Subcommands {
+			for _, alias := range sc.Aliases {
+				if _, ok := cm.Subcommands[alias]; ok {
+					errs[path] = append(errs[path], fmt.Errorf("alias %s of subcommand %s collides with a subcommand name", alias, scName))
+				} else if other, ok := aliased[alias]; ok {
+					errs[path] = append(errs[path], fmt.Errorf("alias %s used by subcommands %s and %s", alias, other, scName))
+				} else {
+					aliased[alias] = scName
+				}
+			}
+		}
 		for scName, sc := range cm.Subcommands {
 			visit(fmt.Sprintf("%s/%s", path, scName), sc)
 		}
@@ -299,6 +552,30 @@ func (c *Command) DebugValidate() map[string][]error {
 	return errs
 }
 
+// Validate checks if the command tree is well-formed, like DebugValidate, and
+// returns all problems found joined into a single error.
+func (c *Command) Validate() error {
+	errs := c.DebugValidate()
+
+	paths := make([]string, 0, len(errs))
+	for path := range errs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var all []error
+	for _, path := range paths {
+		name := path
+		if name == "" {
+			name = "/"
+		}
+		for _, err := range errs[path] {
+			all = append(all, fmt.Errorf("command %s: %w", name, err))
+		}
+	}
+	return errors.Join(all...)
+}
+
 // CheckArguments checks that we have all the required string arguments, loading
 // any from stdin if necessary.
 func (c *Command) CheckArguments(req *Request) error {
@@ -361,6 +638,27 @@ func (c *Command) CheckArguments(req *Request) error {
 		return fmt.Errorf("argument %q is required", argDef.Name)
 	}
 
+	bound := req.bindArguments()
+	for i, argDef := range bound {
+		if _, err := convertArgument(argDef, i+1, req.Arguments[i]); err != nil {
+			return err
+		}
+	}
+
+	// a variadic argument may require more than the single value Required
+	// asks for. Values streamed from stdin can't be counted up front.
+	if lastArg.Variadic && lastArg.Type == ArgString && lastArg.MinCount > 0 && req.bodyArgs == nil {
+		got := 0
+		for _, argDef := range bound {
+			if argDef.Name == lastArg.Name {
+				got++
+			}
+		}
+		if got < lastArg.MinCount {
+			return fmt.Errorf("expected at least %d arguments for <%s>, got %d", lastArg.MinCount, lastArg.Name, got)
+		}
+	}
+
 	return nil
 }
 
@@ -374,6 +672,38 @@ func (c *Command) Walk(visitor CommandVisitor) {
 	}
 }
 
+// WalkFunc is called by Walk for every command with its path from the root.
+type WalkFunc func(path []string, cmd *Command) error
+
+// Walk traverses the command tree depth-first, calling fn for root and all of
+// its subcommands. Subcommands are visited sorted by name. If fn returns an
+// error, the walk stops and the error is returned.
+func Walk(root *Command, fn WalkFunc) error {
+	return walk(nil, root, fn)
+}
+
+func walk(path []string, cmd *Command, fn WalkFunc) error {
+	if err := fn(path, cmd); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(cmd.Subcommands))
+	for name := range cmd.Subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		subPath := make([]string, len(path)+1)
+		copy(subPath, path)
+		subPath[len(path)] = name
+		if err := walk(subPath, cmd.Subcommands[name], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Command) ProcessHelp() {
 	c.Walk(func(cm *Command) {
 		ht := &cm.Helptext
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 	"time"
 )
@@ -98,6 +99,60 @@ func TestRegistration(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	valid := &Command{
+		Options: []Option{BoolOption("verbose", "v", "be verbose")},
+		Arguments: []Argument{
+			StringArg("a", true, false, "a"),
+			StringArg("b", false, true, "b"),
+		},
+		Subcommands: map[string]*Command{
+			"sub": {Options: []Option{BoolOption("quiet", "q", "be quiet")}},
+		},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid tree, got: %s", err)
+	}
+
+	invalid := &Command{
+		Options: []Option{BoolOption("verbose", "v", "be verbose")},
+		Subcommands: map[string]*Command{
+			"dup": {
+				Options:           []Option{BoolOption("vvv", "v", "conflicts")},
+				MutuallyExclusive: [][]string{{"verbose", "nope"}},
+			},
+			"enc":  {DefaultEncoding: "yaml"},
+			"tmpl": {TextTemplate: "{{.Name"},
+			"args": {
+				Arguments: []Argument{
+					StringArg("opt", false, false, "optional"),
+					StringArg("req", true, false, "required"),
+					StringArg("many", true, true, "variadic"),
+					StringArg("more", false, true, "variadic"),
+				},
+			},
+		},
+	}
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"command /dup: duplicate option name v",
+		"command /dup: unknown option nope in mutually exclusive set",
+		"command /args: required argument req after optional arguments",
+		"command /args: required argument many after optional arguments",
+		"command /args: variadic and/or optional argument many must be last",
+		"command /args: more than one variadic argument",
+		"command /enc: no encoder for default encoding yaml",
+		"command /tmpl: invalid text template: template: /tmpl:1: unclosed action",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got:\n%s", want, err)
+		}
+	}
+}
+
 func TestResolving(t *testing.T) {
 	cmdC := &Command{}
 	cmdB := &Command{
@@ -127,6 +182,91 @@ func TestResolving(t *testing.T) {
 	}
 }
 
+func TestAliases(t *testing.T) {
+	remove := &Command{Aliases: []string{"rm", "del"}}
+	rm := &Command{}
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"remove": remove,
+			"list":   {Aliases: []string{"ls"}},
+		},
+	}
+
+	for _, name := range []string{"remove", "rm", "del"} {
+		cmd, err := root.Get([]string{name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd != remove {
+			t.Errorf("expected %q to resolve to the remove command", name)
+		}
+	}
+	if name, _ := root.Subcommand("rm"); name != "remove" {
+		t.Errorf("expected the real name of the command, got %q", name)
+	}
+	if _, err := root.Get([]string{"nope"}); err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+	if err := root.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// real names win over aliases
+	root.Subcommands["rm"] = rm
+	if cmd, _ := root.Get([]string{"rm"}); cmd != rm {
+		t.Error("expected the real name to take precedence over the alias")
+	}
+	root.Subcommands["other"] = &Command{Aliases: []string{"ls"}}
+	err := root.Validate()
+	if err == nil {
+		t.Fatal("expected alias collisions to be reported")
+	}
+	for _, want := range []string{
+		"alias rm of subcommand remove collides with a subcommand name",
+		"alias ls used by subcommands",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got:\n%s", want, err)
+		}
+	}
+}
+
+func TestGetFold(t *testing.T) {
+	add := &Command{}
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"add": add,
+			"pin": {
+				Subcommands: map[string]*Command{
+					"ls": {},
+					"LS": {},
+				},
+			},
+		},
+	}
+
+	if _, err := root.Get([]string{"ADD"}); err == nil {
+		t.Error("expected Get to stay case-sensitive")
+	}
+	cmd, err := root.GetFold([]string{"ADD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != add {
+		t.Error("expected ADD to resolve to the add command")
+	}
+
+	if cmd, err := root.GetFold([]string{"PIN", "ls"}); err != nil || cmd != root.Subcommands["pin"].Subcommands["ls"] {
+		t.Errorf("expected an exact match to win, got %v", err)
+	}
+	if _, err := root.GetFold([]string{"pin", "Ls"}); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected an ambiguity error, got %v", err)
+	}
+	if _, err := root.GetFold([]string{"nope"}); err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+}
+
 func TestWalking(t *testing.T) {
 	cmdA := &Command{
 		Subcommands: map[string]*Command{
@@ -143,6 +283,49 @@ func TestWalking(t *testing.T) {
 	}
 }
 
+func TestWalk(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"b": {
+				Subcommands: map[string]*Command{
+					"d": {},
+					"c": {},
+				},
+			},
+			"a": {},
+		},
+	}
+
+	var visited []string
+	err := Walk(root, func(path []string, cmd *Command) error {
+		visited = append(visited, strings.Join(path, " "))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"", "a", "b", "b c", "b d"}
+	if strings.Join(visited, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %q, got %q", expected, visited)
+	}
+
+	errStop := errors.New("stop")
+	visited = nil
+	err = Walk(root, func(path []string, cmd *Command) error {
+		visited = append(visited, strings.Join(path, " "))
+		if len(path) > 0 && path[0] == "b" {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("expected %v, got %v", errStop, err)
+	}
+	if len(visited) != 3 {
+		t.Fatalf("expected walk to stop after 3 commands, got %q", visited)
+	}
+}
+
 func TestHelpProcessing(t *testing.T) {
 	cmdB := &Command{
 		Helptext: HelpText{
@@ -1,11 +1,13 @@
 package cmds
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"reflect"
+	"text/template"
 )
 
 // Encoder encodes values onto e.g. an io.Writer. Examples are json.Encoder and xml.Encoder.
@@ -35,6 +37,8 @@ const (
 	Protobuf    = "protobuf"
 	Text        = "text"
 	TextNewline = "textnl"
+	Table       = "table"
+	CSV         = "csv"
 
 	// PostRunTypes
 	CLI = "cli"
@@ -54,7 +58,7 @@ type EncoderMap map[EncodingType]EncoderFunc
 
 var Encoders = EncoderMap{
 	XML: func(req *Request) func(io.Writer) Encoder {
-		return func(w io.Writer) Encoder { return xml.NewEncoder(w) }
+		return func(w io.Writer) Encoder { return &xmlEncoder{enc: xml.NewEncoder(w)} }
 	},
 	JSON: func(req *Request) func(io.Writer) Encoder {
 		return func(w io.Writer) Encoder { return json.NewEncoder(w) }
@@ -65,6 +69,12 @@ var Encoders = EncoderMap{
 	TextNewline: func(req *Request) func(io.Writer) Encoder {
 		return func(w io.Writer) Encoder { return TextEncoder{w: w, suffix: "\n"} }
 	},
+	Table: func(req *Request) func(io.Writer) Encoder {
+		return func(w io.Writer) Encoder { return &tableEncoder{w: w} }
+	},
+	CSV: func(req *Request) func(io.Writer) Encoder {
+		return func(w io.Writer) Encoder { return &csvEncoder{w: csv.NewWriter(w)} }
+	},
 }
 
 func MakeEncoder(f func(*Request, io.Writer, interface{}) error) func(*Request) func(io.Writer) Encoder {
@@ -153,10 +163,66 @@ type TextEncoder struct {
 }
 
 func (e TextEncoder) Encode(v interface{}) error {
+	if p, ok := v.(*Progress); ok {
+		return writeProgress(e.w, p)
+	}
+
 	_, err := fmt.Fprintf(e.w, "%s%s", v, e.suffix)
 	return err
 }
 
+// templateEncoder encodes values by executing the TextTemplate of a command.
+type templateEncoder struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (e *templateEncoder) Encode(v interface{}) error {
+	if p, ok := v.(*Progress); ok {
+		return writeProgress(e.w, p)
+	}
+	return e.tmpl.Execute(e.w, v)
+}
+
+// xmlEncoder encodes values as XML. Since an XML document has a single root
+// element, all values are written as <Value> elements inside a <Values> root,
+// which is opened by the first value and closed by Close. The items of a
+// slice are written as separate values.
+type xmlEncoder struct {
+	enc     *xml.Encoder
+	started bool
+}
+
+var (
+	xmlRoot  = xml.StartElement{Name: xml.Name{Local: "Values"}}
+	xmlValue = xml.StartElement{Name: xml.Name{Local: "Value"}}
+)
+
+func (e *xmlEncoder) Encode(v interface{}) error {
+	if !e.started {
+		if err := e.enc.EncodeToken(xmlRoot); err != nil {
+			return err
+		}
+		e.started = true
+	}
+
+	if err := e.enc.EncodeElement(v, xmlValue); err != nil {
+		return fmt.Errorf("cannot encode %T as XML: %w", v, err)
+	}
+	return e.enc.Flush()
+}
+
+// Close closes the root element if any value has been written.
+func (e *xmlEncoder) Close() error {
+	if !e.started {
+		return nil
+	}
+	if err := e.enc.EncodeToken(xmlRoot.End()); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
 // GetEncoder takes a request and returns returns the encoding type and the encoder.
 func GetEncoder(req *Request, w io.Writer, def EncodingType) (encType EncodingType, enc Encoder, err error) {
 	encType = GetEncoding(req, def)
@@ -167,6 +233,15 @@ func GetEncoder(req *Request, w io.Writer, def EncodingType) (encType EncodingTy
 	)
 	if req.Command != nil {
 		fn, ok = req.Command.Encoders[encType]
+
+		// the template is parsed here to fail before the first value
+		if !ok && encType == Text && req.Command.TextTemplate != "" {
+			tmpl, err := template.New("text").Parse(req.Command.TextTemplate)
+			if err != nil {
+				return encType, nil, fmt.Errorf("invalid text template: %w", err)
+			}
+			return encType, &templateEncoder{w: w, tmpl: tmpl}, nil
+		}
 	}
 	if !ok {
 		fn, ok = Encoders[encType]
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 )
 
@@ -105,3 +106,227 @@ func TestMakeTypedEncoderArrays(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestXMLEncoder(t *testing.T) {
+	type item struct {
+		Name string
+		Size int
+	}
+
+	encode := func(values ...interface{}) (string, error) {
+		var buf bytes.Buffer
+		_, enc, err := GetEncoder(&Request{Options: OptMap{EncLong: XML}}, &buf, JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range values {
+			if err := enc.Encode(v); err != nil {
+				return buf.String(), err
+			}
+		}
+		err = enc.(io.Closer).Close()
+		return buf.String(), err
+	}
+
+	out, err := encode(&item{Name: "a", Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<Values><Value><Name>a</Name><Size>1</Size></Value></Values>"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	expected := "<Values><Value><Name>a</Name><Size>1</Size></Value><Value><Name>b</Name><Size>2</Size></Value></Values>"
+	out, err = encode([]item{{Name: "a", Size: 1}, {Name: "b", Size: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	out, err = encode(item{Name: "a", Size: 1}, item{Name: "b", Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	if out, err := encode(); err != nil || out != "" {
+		t.Errorf("expected no output without values, got %q %v", out, err)
+	}
+
+	if _, err := encode(map[string]int{"a": 1}); err == nil || !strings.Contains(err.Error(), "cannot encode map[string]int as XML") {
+		t.Errorf("expected an error for a map, got %v", err)
+	}
+}
+
+func TestTableEncoder(t *testing.T) {
+	type entry struct {
+		Name  string
+		Size  int
+		Tags  []string
+		inner int
+	}
+
+	encode := func(values ...interface{}) (string, error) {
+		var buf bytes.Buffer
+		_, enc, err := GetEncoder(&Request{Options: OptMap{EncLong: Table}}, &buf, JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range values {
+			if err := enc.Encode(v); err != nil {
+				return buf.String(), err
+			}
+		}
+		err = enc.(io.Closer).Close()
+		return buf.String(), err
+	}
+
+	out, err := encode([]entry{
+		{Name: "a", Size: 1, Tags: []string{"x"}},
+		{Name: "längere", Size: 1234},
+	}, &entry{Name: "日本", Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"Name     Size  Tags\n" +
+		"a        1     [x]\n" +
+		"längere  1234  []\n" +
+		"日本     5     []\n"
+	if out != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+
+	out, err = encode([]map[string]interface{}{
+		{"peer": "QmFoo", "latency": "12ms"},
+		{"peer": "QmBarBaz", "latency": "3ms", "nested": map[string]int{"a": 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "" +
+		"latency  peer      nested\n" +
+		"12ms     QmFoo\n" +
+		"3ms      QmBarBaz  map[a:1]\n"
+	if out != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+
+	if _, err := encode("plain"); err == nil || err.Error() != "cannot encode string as a table row" {
+		t.Errorf("expected an error for a string, got %v", err)
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+	type entry struct {
+		Name string
+		Note string
+	}
+
+	encode := func(values ...interface{}) (string, error) {
+		var buf bytes.Buffer
+		_, enc, err := GetEncoder(&Request{Options: OptMap{EncLong: CSV}}, &buf, JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range values {
+			if err := enc.Encode(v); err != nil {
+				return buf.String(), err
+			}
+		}
+		return buf.String(), nil
+	}
+
+	out, err := encode(
+		[]entry{{Name: "a,b", Note: `say "hi"`}},
+		entry{Name: "c", Note: "two\nlines"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Name,Note\n" +
+		"\"a,b\",\"say \"\"hi\"\"\"\n" +
+		"c,\"two\nlines\"\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	out, err = encode(map[string]int{"a": 1, "b": 2}, map[string]int{"b": 3, "a": 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a,b\n1,2\n4,3\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	out, err = encode(entry{Name: "a"}, map[string]string{"Name": "b"})
+	expectedErr := "cannot encode map[string]string as CSV: columns Name don't match the header Name,Note"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+	if expected := "Name,Note\na,\n"; out != expected {
+		t.Errorf("expected the rows before the mismatch %q, got %q", expected, out)
+	}
+}
+
+func TestTextTemplate(t *testing.T) {
+	type entry struct {
+		Name string
+		Size int
+	}
+
+	encode := func(cmd *Command, values ...interface{}) (string, error) {
+		var buf bytes.Buffer
+		_, enc, err := GetEncoder(&Request{Command: cmd, Options: OptMap{EncLong: Text}}, &buf, JSON)
+		if err != nil {
+			return "", err
+		}
+		for _, v := range values {
+			if err := enc.Encode(v); err != nil {
+				return buf.String(), err
+			}
+		}
+		return buf.String(), nil
+	}
+
+	cmd := &Command{TextTemplate: "{{.Name}} ({{.Size}} bytes)\n"}
+	out, err := encode(cmd, entry{Name: "a", Size: 1}, &entry{Name: "b", Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a (1 bytes)\nb (2 bytes)\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// without a template values are written as they are
+	out, err = encode(&Command{}, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "ab"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// a text encoder of the command takes precedence
+	cmd = &Command{
+		TextTemplate: "{{.Name}}",
+		Encoders: EncoderMap{
+			Text: MakeEncoder(func(req *Request, w io.Writer, v interface{}) error {
+				_, err := io.WriteString(w, "custom")
+				return err
+			}),
+		},
+	}
+	if out, err := encode(cmd, entry{Name: "a"}); err != nil || out != "custom" {
+		t.Errorf("expected the custom encoder to be used, got %q %v", out, err)
+	}
+
+	_, err = encode(&Command{TextTemplate: "{{.Name"})
+	if err == nil || err.Error() != "invalid text template: template: text:1: unclosed action" {
+		t.Errorf("expected a template error, got %v", err)
+	}
+}
@@ -22,6 +22,8 @@ const (
 	// ErrForbidden is returned when the client doesn't have permission to
 	// perform the requested operation.
 	ErrForbidden
+	// ErrNotFound is returned when the requested object doesn't exist.
+	ErrNotFound
 )
 
 func (e ErrorType) Error() string {
@@ -40,6 +42,8 @@ func (e ErrorType) String() string {
 		return "rate limited"
 	case ErrForbidden:
 		return "request forbidden"
+	case ErrNotFound:
+		return "not found"
 	default:
 		return "unknown error code"
 	}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	nethttp "net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ipfs/go-ipfs-cmds/examples/adder"
 
@@ -14,8 +20,23 @@ func main() {
 	h := http.NewHandler(env{}, adder.RootCmd, http.NewServerConfig())
 
 	// create http rpc server
-	err := nethttp.ListenAndServe(":6798", h)
-	if err != nil {
-		panic(err)
+	srv := &nethttp.Server{Addr: ":6798", Handler: h}
+
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	// let the running commands finish, and cancel those that don't in time
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
 	}
 }
@@ -1,8 +1,12 @@
 package cmds
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 )
 
 type Executor interface {
@@ -23,14 +27,39 @@ type MakeEnvironment func(context.Context, *Request) (Environment, error)
 // The user can define a function like this to pass it to cli.Run.
 type MakeExecutor func(*Request, interface{}) (Executor, error)
 
-func NewExecutor(root *Command) Executor {
+// Middleware wraps the Run function of a command, e.g. to log or recover
+// from panics.
+type Middleware func(next Function) Function
+
+// NewExecutor returns an Executor that calls the Run function of commands in
+// the current process. The middlewares are applied around Run in the order
+// they are given, so the first one is the outermost.
+func NewExecutor(root *Command, middlewares ...Middleware) Executor {
+	return &executor{
+		root:        root,
+		middlewares: middlewares,
+	}
+}
+
+// HelpFunc writes the help text of the command of req to w.
+type HelpFunc func(req *Request, w io.Writer) error
+
+// NewExecutorWithHelp is like NewExecutor, but the returned Executor emits the
+// help text written by help instead of running the command when the --help or
+// -h option is set. The arguments aren't checked then, so help can be asked
+// for without giving the required ones.
+func NewExecutorWithHelp(root *Command, help HelpFunc, middlewares ...Middleware) Executor {
 	return &executor{
-		root: root,
+		root:        root,
+		help:        help,
+		middlewares: middlewares,
 	}
 }
 
 type executor struct {
-	root *Command
+	root        *Command
+	help        HelpFunc
+	middlewares []Middleware
 }
 
 func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) error {
@@ -48,6 +77,14 @@ func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) er
 
 	cmd := req.Command
 
+	if x.help != nil && helpRequested(req) {
+		var buf bytes.Buffer
+		if err := x.help(req, &buf); err != nil {
+			return err
+		}
+		return EmitOnce(re, &buf)
+	}
+
 	if cmd.Run == nil {
 		return ErrNotCallable
 	}
@@ -57,6 +94,15 @@ func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) er
 		return err
 	}
 
+	if err := x.root.authorize(req); err != nil {
+		return err
+	}
+
+	run, err := cmd.function(req)
+	if err != nil {
+		return err
+	}
+
 	if cmd.PreRun != nil {
 		err = cmd.PreRun(req, env)
 		if err != nil {
@@ -98,7 +144,12 @@ func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) er
 	}
 
 	postRunCh := maybeStartPostRun(cmd.PostRun)
-	runCloseErr := re.CloseWithError(cmd.Run(req, re, env))
+	run = x.root.withHooks(req, cmd, run)
+	for i := len(x.middlewares) - 1; i >= 0; i-- {
+		run = x.middlewares[i](run)
+	}
+	runErr := run(req, re, env)
+	runCloseErr := re.CloseWithError(runErr)
 	postCloseErr := <-postRunCh
 	switch runCloseErr {
 	case ErrClosingClosedEmitter, nil:
@@ -112,3 +163,168 @@ func (x *executor) Execute(req *Request, re ResponseEmitter, env Environment) er
 	}
 	return nil
 }
+
+// helpRequested reports whether the --help or -h option of req is set.
+func helpRequested(req *Request) bool {
+	long, _ := req.Options.GetBool(OptLongHelp)
+	short, _ := req.Options.GetBool(OptShortHelp)
+	return long || short
+}
+
+// withHooks wraps run in the PreRunHook and PostRunHook of the commands on
+// the path of req, or only those of cmd if the path can't be resolved from c.
+func (c *Command) withHooks(req *Request, cmd *Command, run Function) Function {
+	hooked, err := c.Resolve(req.Path)
+	if err != nil {
+		hooked = []*Command{cmd}
+	}
+	return func(req *Request, re ResponseEmitter, env Environment) error {
+		return runWithHooks(req, hooked, func() error {
+			return run(req, re, env)
+		})
+	}
+}
+
+// runWithHooks calls run wrapped in the PreRunHook and PostRunHook of each of
+// the given commands, with the first command's hooks outermost.
+func runWithHooks(req *Request, cmds []*Command, run func() error) error {
+	if len(cmds) == 0 {
+		return run()
+	}
+
+	cmd := cmds[0]
+	if cmd.PreRunHook != nil {
+		if err := cmd.PreRunHook(req); err != nil {
+			return err
+		}
+	}
+
+	err := runWithHooks(req, cmds[1:], run)
+	if cmd.PostRunHook != nil {
+		err = cmd.PostRunHook(req, err)
+	}
+	return err
+}
+
+// Logger receives the path, duration and error of every Run, see LogRuns.
+type Logger interface {
+	Log(path []string, dur time.Duration, err error)
+}
+
+// LogRuns returns a Middleware that reports every Run to l once it returns. A
+// panic in Run is reported as an error and then passed on, so a Recover
+// middleware given before LogRuns still handles it. A nil Logger logs
+// nothing.
+func LogRuns(l Logger) Middleware {
+	return func(next Function) Function {
+		if l == nil {
+			return next
+		}
+		return func(req *Request, re ResponseEmitter, env Environment) (err error) {
+			start := time.Now()
+			defer func() {
+				if r := recover(); r != nil {
+					l.Log(req.Path, time.Since(start), fmt.Errorf("panic: %v", r))
+					panic(r)
+				}
+				l.Log(req.Path, time.Since(start), err)
+			}()
+			return next(req, re, env)
+		}
+	}
+}
+
+// Metrics records the runs of commands, keyed by command path, see
+// MeasureRuns. The methods map onto an invocation counter, an error counter
+// and a duration histogram.
+type Metrics interface {
+	// Invoked counts a run of the command.
+	Invoked(path []string)
+	// Failed counts a run of the command that returned an error.
+	Failed(path []string)
+	// Observe records how long a run of the command took.
+	Observe(path []string, dur time.Duration)
+}
+
+// MeasureRuns returns a Middleware that reports every Run to m. A panic in
+// Run counts as an error and is passed on. A nil Metrics measures nothing.
+func MeasureRuns(m Metrics) Middleware {
+	return func(next Function) Function {
+		if m == nil {
+			return next
+		}
+		return func(req *Request, re ResponseEmitter, env Environment) (err error) {
+			m.Invoked(req.Path)
+			start := time.Now()
+			defer func() {
+				m.Observe(req.Path, time.Since(start))
+				if r := recover(); r != nil {
+					m.Failed(req.Path)
+					panic(r)
+				}
+				if err != nil {
+					m.Failed(req.Path)
+				}
+			}()
+			return next(req, re, env)
+		}
+	}
+}
+
+// Tracer starts the spans of command runs, see TraceRuns.
+type Tracer interface {
+	// Start starts a span called name as a child of the span in ctx, if any,
+	// and returns a copy of ctx holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span with the error of the run, if any.
+	End(err error)
+}
+
+// TraceRuns returns a Middleware that wraps every Run in a span named by the
+// command path, e.g. "/files/ls". Run is called with a copy of the request
+// whose context holds the span, so the spans of commands executed by Run with
+// that context nest under it. A panic ends the span with an error and is
+// passed on. A nil Tracer traces nothing.
+func TraceRuns(t Tracer) Middleware {
+	return func(next Function) Function {
+		if t == nil {
+			return next
+		}
+		return func(req *Request, re ResponseEmitter, env Environment) (err error) {
+			ctx := req.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			spanCtx, span := t.Start(ctx, "/"+strings.Join(req.Path, "/"))
+
+			// Run gets a copy of the request, the response may read the
+			// context of req concurrently
+			spanReq := *req
+			spanReq.Context = spanCtx
+			defer func() {
+				if r := recover(); r != nil {
+					span.End(fmt.Errorf("panic: %v", r))
+					panic(r)
+				}
+				span.End(err)
+			}()
+			return next(&spanReq, re, env)
+		}
+	}
+}
+
+// Recover is a Middleware that turns a panic in Run into an error.
+func Recover(next Function) Function {
+	return func(req *Request, re ResponseEmitter, env Environment) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic in command %q: %v", strings.Join(req.Path, " "), r)
+			}
+		}()
+		return next(req, re, env)
+	}
+}
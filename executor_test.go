@@ -5,7 +5,10 @@ import (
 	"context"
 	"errors"
 	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 var errGeneric = errors.New("an error occurred")
@@ -177,3 +180,685 @@ func TestExecutorPostRun(t *testing.T) {
 type cliMockEmitter struct{ ResponseEmitter }
 
 func (cliMockEmitter) Type() PostRunType { return CLI }
+
+func TestExecutorHooks(t *testing.T) {
+	var calls []string
+	hook := func(name string, preErr error) (func(*Request) error, func(*Request, error) error) {
+		return func(*Request) error {
+				calls = append(calls, "pre "+name)
+				return preErr
+			}, func(_ *Request, err error) error {
+				calls = append(calls, "post "+name)
+				return err
+			}
+	}
+
+	var runErr error
+	testCmd := &Command{
+		Run: func(*Request, ResponseEmitter, Environment) error {
+			calls = append(calls, "run")
+			return runErr
+		},
+	}
+	testRoot := &Command{
+		Subcommands: map[string]*Command{
+			"test": testCmd,
+		},
+	}
+	testRoot.PreRunHook, testRoot.PostRunHook = hook("root", nil)
+
+	req, err := NewRequest(context.Background(), []string{"test"}, nil, nil, nil, testRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execute := func() error {
+		calls = nil
+		emitter, resp := NewChanResponsePair(req)
+		if err := NewExecutor(testRoot).Execute(req, emitter, nil); err != nil {
+			t.Fatal(err)
+		}
+		_, err := resp.Next()
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	expectCalls := func(expected ...string) {
+		t.Helper()
+		if strings.Join(calls, ", ") != strings.Join(expected, ", ") {
+			t.Errorf("expected calls %q, got %q", expected, calls)
+		}
+	}
+
+	testCmd.PreRunHook, testCmd.PostRunHook = hook("test", nil)
+	if err := execute(); err != nil {
+		t.Fatal(err)
+	}
+	expectCalls("pre root", "pre test", "run", "post test", "post root")
+
+	runErr = errGeneric
+	if err := execute(); err != errGeneric {
+		t.Fatalf("expected %q, got %v", errGeneric, err)
+	}
+	expectCalls("pre root", "pre test", "run", "post test", "post root")
+
+	preErr := errors.New("setup failed")
+	testCmd.PreRunHook, testCmd.PostRunHook = hook("test", preErr)
+	if err := execute(); err != preErr {
+		t.Fatalf("expected %q, got %v", preErr, err)
+	}
+	expectCalls("pre root", "pre test", "post root")
+
+	testCmd.PostRunHook = func(*Request, error) error { return nil }
+	testCmd.PreRunHook = nil
+	if err := execute(); err != nil {
+		t.Fatalf("expected PostRunHook to clear the error, got %v", err)
+	}
+
+	// the hooks run inside the middlewares
+	runErr = nil
+	testCmd.PreRunHook, testCmd.PostRunHook = hook("test", nil)
+	calls = nil
+	middleware := func(next Function) Function {
+		return func(req *Request, re ResponseEmitter, env Environment) error {
+			calls = append(calls, "enter")
+			defer func() { calls = append(calls, "leave") }()
+			return next(req, re, env)
+		}
+	}
+	emitter, _ := NewChanResponsePair(req)
+	if err := NewExecutor(testRoot, middleware).Execute(req, emitter, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectCalls("enter", "pre root", "pre test", "run", "post test", "post root", "leave")
+
+	// and when the command is called directly, as the HTTP handler does
+	calls = nil
+	emitter, _ = NewChanResponsePair(req)
+	testRoot.Call(req, emitter, nil)
+	expectCalls("pre root", "pre test", "run", "post test", "post root")
+}
+
+func TestExecutorAuthorize(t *testing.T) {
+	var ran []string
+	run := func(req *Request, re ResponseEmitter, env Environment) error {
+		ran = append(ran, strings.Join(req.Path, " "))
+		return nil
+	}
+	testRoot := &Command{
+		Options: []Option{
+			StringOption("user", "who is asking"),
+		},
+		Authorize: func(req *Request) error {
+			if user, _ := req.Options["user"].(string); user != "admin" {
+				return errors.New("admin only")
+			}
+			return nil
+		},
+		Subcommands: map[string]*Command{
+			"status": {Run: run},
+			"public": {
+				Authorize: func(req *Request) error { return nil },
+				Run:       run,
+			},
+			"secret": {
+				Authorize: func(req *Request) error {
+					return Errorf(ErrNotFound, "no such command")
+				},
+				Run: run,
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		path []string
+		user string
+		err  *Error
+	}{
+		{[]string{"status"}, "admin", nil},
+		{[]string{"status"}, "guest", &Error{Message: "admin only", Code: ErrForbidden}},
+		{[]string{"public"}, "guest", nil},
+		{[]string{"secret"}, "admin", &Error{Message: "no such command", Code: ErrNotFound}},
+	} {
+		ran = nil
+		req, err := NewRequest(context.Background(), tc.path, OptMap{"user": tc.user}, nil, nil, testRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		re, res := NewChanResponsePair(req)
+		err = NewExecutor(testRoot).Execute(req, re, nil)
+		if tc.err == nil {
+			if err != nil {
+				t.Errorf("%v as %s: %s", tc.path, tc.user, err)
+			} else if _, err := res.Next(); err != io.EOF {
+				t.Errorf("%v as %s: expected EOF, got %v", tc.path, tc.user, err)
+			}
+			if len(ran) != 1 {
+				t.Errorf("%v as %s: expected the command to run", tc.path, tc.user)
+			}
+			continue
+		}
+
+		var cmdErr Error
+		var cmdErrPtr *Error
+		switch {
+		case errors.As(err, &cmdErrPtr):
+			cmdErr = *cmdErrPtr
+		case !errors.As(err, &cmdErr):
+			t.Errorf("%v as %s: expected %v, got %v", tc.path, tc.user, tc.err, err)
+			continue
+		}
+		if cmdErr != *tc.err {
+			t.Errorf("%v as %s: expected %#v, got %#v", tc.path, tc.user, *tc.err, cmdErr)
+		}
+		if len(ran) != 0 {
+			t.Errorf("%v as %s: expected the command not to run", tc.path, tc.user)
+		}
+	}
+}
+
+func TestExecutorMiddleware(t *testing.T) {
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(next Function) Function {
+			return func(req *Request, re ResponseEmitter, env Environment) error {
+				calls = append(calls, "enter "+name)
+				defer func() { calls = append(calls, "leave "+name) }()
+				return next(req, re, env)
+			}
+		}
+	}
+
+	testRoot := &Command{
+		Subcommands: map[string]*Command{
+			"test": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					calls = append(calls, "run")
+					return nil
+				},
+			},
+			"panic": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					panic("boom")
+				},
+			},
+		},
+	}
+
+	execute := func(x Executor, path string) error {
+		req, err := NewRequest(context.Background(), []string{path}, nil, nil, nil, testRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emitter, resp := NewChanResponsePair(req)
+		if err := x.Execute(req, emitter, nil); err != nil {
+			t.Fatal(err)
+		}
+		_, err = resp.Next()
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	t.Run("order", func(t *testing.T) {
+		calls = nil
+		x := NewExecutor(testRoot, middleware("a"), middleware("b"))
+		if err := execute(x, "test"); err != nil {
+			t.Fatal(err)
+		}
+		expected := "enter a, enter b, run, leave b, leave a"
+		if got := strings.Join(calls, ", "); got != expected {
+			t.Errorf("expected calls %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		err := execute(NewExecutor(testRoot, Recover), "panic")
+		expected := `panic in command "panic": boom`
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %q, got %v", expected, err)
+		}
+	})
+}
+
+func TestExecutorDryRun(t *testing.T) {
+	var ran, previewed bool
+	testRoot := &Command{
+		Options: []Option{OptionDryRun},
+		Subcommands: map[string]*Command{
+			"rm": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					ran = true
+					return nil
+				},
+				DryRun: func(req *Request, re ResponseEmitter, env Environment) error {
+					previewed = req.DryRun
+					return re.Emit("would remove")
+				},
+			},
+			"gc": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					ran = true
+					return nil
+				},
+			},
+		},
+	}
+
+	x := NewExecutor(testRoot)
+	execute := func(path string, opts OptMap) (interface{}, error) {
+		req, err := NewRequest(context.Background(), []string{path}, opts, nil, nil, testRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emitter, resp := NewChanResponsePair(req)
+
+		// Execute runs synchronously, so the response has to be read
+		// concurrently for Emit to not block
+		type result struct {
+			v   interface{}
+			err error
+		}
+		results := make(chan result, 1)
+		go func() {
+			v, err := resp.Next()
+			results <- result{v, err}
+		}()
+
+		if err := x.Execute(req, emitter, nil); err != nil {
+			emitter.Close()
+			return nil, err
+		}
+		res := <-results
+		if res.err == io.EOF {
+			res.err = nil
+		}
+		return res.v, res.err
+	}
+
+	v, err := execute("rm", OptMap{DryRunOpt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran || !previewed || v != "would remove" {
+		t.Errorf("expected only DryRun to be called, got ran=%v previewed=%v value=%v", ran, previewed, v)
+	}
+
+	ran = false
+	_, err = execute("gc", OptMap{DryRunOpt: true})
+	expected := "gc does not support --dry-run"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+	if e, ok := err.(Error); !ok || e.Code != ErrClient {
+		t.Errorf("expected a client error, got %#v", err)
+	}
+	if ran {
+		t.Error("expected Run to not be called")
+	}
+
+	if _, err := execute("gc", nil); err != nil || !ran {
+		t.Errorf("expected Run to be called without dry-run, got %v", err)
+	}
+}
+
+type logEntry struct {
+	path []string
+	dur  time.Duration
+	err  error
+}
+
+type testLogger []logEntry
+
+func (l *testLogger) Log(path []string, dur time.Duration, err error) {
+	*l = append(*l, logEntry{path, dur, err})
+}
+
+func TestExecutorLogRuns(t *testing.T) {
+	testRoot := &Command{
+		Subcommands: map[string]*Command{
+			"sleep": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					time.Sleep(time.Millisecond)
+					return nil
+				},
+			},
+			"fail": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					return errors.New("failed")
+				},
+			},
+			"panic": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					panic("boom")
+				},
+			},
+		},
+	}
+
+	var log testLogger
+	x := NewExecutor(testRoot, Recover, LogRuns(&log))
+	for _, path := range []string{"sleep", "fail", "panic"} {
+		req, err := NewRequest(context.Background(), []string{path}, nil, nil, nil, testRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emitter, resp := NewChanResponsePair(req)
+		go func() {
+			for {
+				if _, err := resp.Next(); err != nil {
+					return
+				}
+			}
+		}()
+		if err := x.Execute(req, emitter, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(log) != 3 {
+		t.Fatalf("expected 3 log entries, got %v", log)
+	}
+	for i, expected := range []struct {
+		path string
+		err  string
+	}{
+		{path: "sleep"},
+		{path: "fail", err: "failed"},
+		{path: "panic", err: "panic: boom"},
+	} {
+		entry := log[i]
+		if !reflect.DeepEqual(entry.path, []string{expected.path}) {
+			t.Errorf("entry %d: expected path %q, got %v", i, expected.path, entry.path)
+		}
+		if entry.dur <= 0 {
+			t.Errorf("entry %d: expected a positive duration, got %s", i, entry.dur)
+		}
+		if entry.err == nil && expected.err != "" || entry.err != nil && entry.err.Error() != expected.err {
+			t.Errorf("entry %d: expected error %q, got %v", i, expected.err, entry.err)
+		}
+	}
+	if log[0].dur < time.Millisecond {
+		t.Errorf("expected the duration to cover Run, got %s", log[0].dur)
+	}
+
+	if run := LogRuns(nil)(testRoot.Subcommands["fail"].Run); run == nil {
+		t.Error("expected a nil Logger to leave Run in place")
+	}
+}
+
+type testSpan struct {
+	name   string
+	parent *testSpan
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+type testSpanKey struct{}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (tr *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{name: name, parent: parent}
+	tr.spans = append(tr.spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func TestExecutorTraceRuns(t *testing.T) {
+	var tracer testTracer
+	var x Executor
+
+	execute := func(ctx context.Context, root *Command, path ...string) error {
+		req, err := NewRequest(ctx, path, nil, nil, nil, root)
+		if err != nil {
+			return err
+		}
+		emitter, resp := NewChanResponsePair(req)
+		go func() {
+			for {
+				if _, err := resp.Next(); err != nil {
+					return
+				}
+			}
+		}()
+		return x.Execute(req, emitter, nil)
+	}
+
+	var testRoot *Command
+	testRoot = &Command{
+		Subcommands: map[string]*Command{
+			"files": {
+				Subcommands: map[string]*Command{
+					"ls": {
+						Run: func(*Request, ResponseEmitter, Environment) error {
+							return nil
+						},
+					},
+				},
+			},
+			"fail": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					return errors.New("failed")
+				},
+			},
+			"sync": {
+				Run: func(req *Request, re ResponseEmitter, env Environment) error {
+					// runs a subcommand with the context of the request
+					return execute(req.Context, testRoot, "files", "ls")
+				},
+			},
+		},
+	}
+	x = NewExecutor(testRoot, TraceRuns(&tracer))
+
+	for _, path := range [][]string{{"sync"}, {"fail"}} {
+		if err := execute(context.Background(), testRoot, path...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(tracer.spans))
+	}
+	sync, ls, fail := tracer.spans[0], tracer.spans[1], tracer.spans[2]
+	for _, expected := range []struct {
+		span   *testSpan
+		name   string
+		parent *testSpan
+		err    string
+	}{
+		{span: sync, name: "/sync"},
+		{span: ls, name: "/files/ls", parent: sync},
+		{span: fail, name: "/fail", err: "failed"},
+	} {
+		span := expected.span
+		if span.name != expected.name {
+			t.Errorf("expected span %q, got %q", expected.name, span.name)
+		}
+		if span.parent != expected.parent {
+			t.Errorf("span %s: expected parent %v, got %v", span.name, expected.parent, span.parent)
+		}
+		if !span.ended {
+			t.Errorf("span %s: expected the span to be ended", span.name)
+		}
+		if span.err == nil && expected.err != "" || span.err != nil && span.err.Error() != expected.err {
+			t.Errorf("span %s: expected error %q, got %v", span.name, expected.err, span.err)
+		}
+	}
+
+	if run := TraceRuns(nil)(testRoot.Subcommands["fail"].Run); run == nil {
+		t.Error("expected a nil Tracer to leave Run in place")
+	}
+}
+
+type testMetrics struct {
+	invoked, failed map[string]int
+	durations       map[string][]time.Duration
+}
+
+func (m *testMetrics) Invoked(path []string) {
+	m.invoked[strings.Join(path, "/")]++
+}
+
+func (m *testMetrics) Failed(path []string) {
+	m.failed[strings.Join(path, "/")]++
+}
+
+func (m *testMetrics) Observe(path []string, dur time.Duration) {
+	key := strings.Join(path, "/")
+	m.durations[key] = append(m.durations[key], dur)
+}
+
+func TestExecutorMeasureRuns(t *testing.T) {
+	testRoot := &Command{
+		Subcommands: map[string]*Command{
+			"sleep": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					time.Sleep(time.Millisecond)
+					return nil
+				},
+			},
+			"fail": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					return errors.New("failed")
+				},
+			},
+			"panic": {
+				Run: func(*Request, ResponseEmitter, Environment) error {
+					panic("boom")
+				},
+			},
+		},
+	}
+
+	m := &testMetrics{
+		invoked:   make(map[string]int),
+		failed:    make(map[string]int),
+		durations: make(map[string][]time.Duration),
+	}
+	x := NewExecutor(testRoot, Recover, MeasureRuns(m))
+	for _, path := range []string{"sleep", "sleep", "fail", "panic"} {
+		req, err := NewRequest(context.Background(), []string{path}, nil, nil, nil, testRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emitter, resp := NewChanResponsePair(req)
+		go func() {
+			for {
+				if _, err := resp.Next(); err != nil {
+					return
+				}
+			}
+		}()
+		if err := x.Execute(req, emitter, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if expected := map[string]int{"sleep": 2, "fail": 1, "panic": 1}; !reflect.DeepEqual(m.invoked, expected) {
+		t.Errorf("expected invocations %v, got %v", expected, m.invoked)
+	}
+	if expected := map[string]int{"fail": 1, "panic": 1}; !reflect.DeepEqual(m.failed, expected) {
+		t.Errorf("expected errors %v, got %v", expected, m.failed)
+	}
+	for path, n := range m.invoked {
+		if len(m.durations[path]) != n {
+			t.Errorf("%s: expected %d durations, got %v", path, n, m.durations[path])
+		}
+	}
+	for _, dur := range m.durations["sleep"] {
+		if dur < time.Millisecond {
+			t.Errorf("expected the duration to cover Run, got %s", dur)
+		}
+	}
+
+	if run := MeasureRuns(nil)(testRoot.Subcommands["fail"].Run); run == nil {
+		t.Error("expected nil Metrics to leave Run in place")
+	}
+}
+
+func TestExecutorHelp(t *testing.T) {
+	var ran bool
+	helpRoot := &Command{
+		Options: []Option{
+			BoolOption(OptLongHelp, OptShortHelp, "Show the help."),
+		},
+		Subcommands: map[string]*Command{
+			"add": {
+				Arguments: []Argument{
+					StringArg("path", true, false, "the path"),
+				},
+				Run: func(req *Request, re ResponseEmitter, env Environment) error {
+					ran = true
+					return nil
+				},
+			},
+		},
+	}
+	help := func(req *Request, w io.Writer) error {
+		_, err := io.WriteString(w, "help for "+strings.Join(req.Path, " "))
+		return err
+	}
+
+	req, err := NewRequest(context.Background(), []string{"add"}, OptMap{OptLongHelp: true}, nil, nil, helpRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, res := NewChanResponsePair(req)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewExecutorWithHelp(helpRoot, help).Execute(req, re, nil)
+	}()
+
+	v, err := ExpectOne(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := v.(io.Reader)
+	if !ok {
+		t.Fatalf("expected an io.Reader, got %T", v)
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "help for add" {
+		t.Errorf("expected the help text, got %q", text)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if ran {
+		t.Error("expected the command not to run")
+	}
+
+	// without help the missing argument is an error
+	req, err = NewRequest(context.Background(), []string{"add"}, OptMap{}, nil, nil, helpRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, _ = NewChanResponsePair(req)
+	if err := NewExecutorWithHelp(helpRoot, help).Execute(req, re, nil); err == nil {
+		t.Error("expected an error for the missing argument")
+	}
+
+	// executors without a HelpFunc leave the help option alone
+	req, err = NewRequest(context.Background(), []string{"add"}, OptMap{OptLongHelp: true}, nil, nil, helpRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, _ = NewChanResponsePair(req)
+	if err := NewExecutor(helpRoot).Execute(req, re, nil); err == nil {
+		t.Error("expected an error for the missing argument")
+	}
+}
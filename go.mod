@@ -5,8 +5,10 @@ go 1.22.0
 toolchain go1.22.8
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/ipfs/boxo v0.24.2
 	github.com/ipfs/go-log v1.0.5
+	github.com/mattn/go-runewidth v0.0.16
 	github.com/rs/cors v1.11.1
 	github.com/texttheater/golang-levenshtein v1.0.1
 	golang.org/x/term v0.25.0
@@ -18,6 +20,7 @@ require (
 	github.com/ipfs/go-log/v2 v2.5.1 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/opentracing/opentracing-go v1.2.0 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
@@ -41,3 +41,36 @@ type writecloser struct {
 	io.Writer
 	io.Closer
 }
+
+// recordingEmitter records the values emitted to it and fails with err, if
+// set.
+type recordingEmitter struct {
+	values []interface{}
+	closed bool
+	err    error
+}
+
+func (re *recordingEmitter) Close() error {
+	return re.CloseWithError(nil)
+}
+
+func (re *recordingEmitter) CloseWithError(error) error {
+	if re.closed {
+		return ErrClosingClosedEmitter
+	}
+	re.closed = true
+	return re.err
+}
+
+func (re *recordingEmitter) SetLength(uint64) {}
+
+func (re *recordingEmitter) Emit(value interface{}) error {
+	if re.closed {
+		return ErrClosedEmitter
+	}
+	if re.err != nil {
+		return re.err
+	}
+	re.values = append(re.values, value)
+	return nil
+}
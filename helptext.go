@@ -1,5 +1,7 @@
 package cmds
 
+import "sort"
+
 // HelpText is a set of strings used to generate command help text. The help
 // text follows formats similar to man pages, but not exactly the same.
 type HelpText struct {
@@ -16,3 +18,109 @@ type HelpText struct {
 	Subcommands     string // overrides SUBCOMMANDS section
 	Synopsis        string // overrides SYNOPSIS field
 }
+
+// Example is a worked example of how to invoke a command.
+type Example struct {
+	Command     string // the command line, e.g. "ipfs add -r ./dir"
+	Description string // what running the command does
+}
+
+// HelpSchemaVersion is the version of the CommandHelp JSON schema. It is
+// incremented whenever a field is removed or changes meaning.
+const HelpSchemaVersion = 1
+
+// CommandHelp is the structured help of a single command, as written by
+// cli.HelpJSON and served by the HTTP handler.
+type CommandHelp struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Path          []string         `json:"path"`
+	Tagline       string           `json:"tagline"`
+	Description   string           `json:"description"`
+	Arguments     []ArgumentHelp   `json:"arguments"`
+	Options       []OptionHelp     `json:"options"`
+	Subcommands   []SubcommandHelp `json:"subcommands"`
+}
+
+// ArgumentHelp describes a positional argument of a command.
+type ArgumentHelp struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	Variadic    bool   `json:"variadic"`
+	Description string `json:"description"`
+}
+
+// OptionHelp describes an option of a command.
+type OptionHelp struct {
+	Names       []string `json:"names"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+}
+
+// SubcommandHelp is the short listing of a subcommand.
+type SubcommandHelp struct {
+	Name    string `json:"name"`
+	Tagline string `json:"tagline"`
+}
+
+// NewCommandHelp returns the structured help for the command at path. Hidden
+// options and subcommands are left out.
+func NewCommandHelp(rootName string, root *Command, path []string) (*CommandHelp, error) {
+	cmd, err := root.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	description := cmd.Helptext.ShortDescription
+	if len(cmd.Helptext.LongDescription) > 0 {
+		description = cmd.Helptext.LongDescription
+	}
+
+	help := &CommandHelp{
+		SchemaVersion: HelpSchemaVersion,
+		Path:          append([]string{rootName}, path...),
+		Tagline:       cmd.Helptext.Tagline,
+		Description:   description,
+		Arguments:     make([]ArgumentHelp, 0, len(cmd.Arguments)),
+		Options:       make([]OptionHelp, 0, len(cmd.Options)),
+		Subcommands:   make([]SubcommandHelp, 0, len(cmd.Subcommands)),
+	}
+
+	for _, arg := range cmd.Arguments {
+		help.Arguments = append(help.Arguments, ArgumentHelp{
+			Name:        arg.Name,
+			Required:    arg.Required,
+			Variadic:    arg.Variadic,
+			Description: arg.Description,
+		})
+	}
+	for _, opt := range cmd.Options {
+		if Details(opt).Hidden() {
+			continue
+		}
+		help.Options = append(help.Options, OptionHelp{
+			Names:       opt.Names(),
+			Type:        Details(opt).TypeName(),
+			Description: opt.Description(),
+		})
+	}
+	for _, name := range sortedSubcommands(cmd) {
+		if cmd.Subcommands[name].Hidden {
+			continue
+		}
+		help.Subcommands = append(help.Subcommands, SubcommandHelp{
+			Name:    name,
+			Tagline: cmd.Subcommands[name].Helptext.Tagline,
+		})
+	}
+
+	return help, nil
+}
+
+func sortedSubcommands(cmd *Command) []string {
+	names := make([]string, 0, len(cmd.Subcommands))
+	for name := range cmd.Subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// invocation is a command invocation sent as JSON, as an element of the array
+// posted to the batch endpoint or in a WebSocket message.
+type invocation struct {
+	Path      []string               `json:"path,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	Arguments []string               `json:"arguments,omitempty"`
+}
+
+// batchResult is an element of the JSON array the batch endpoint responds
+// with. Error is set if the command failed, Values holds what it emitted
+// before.
+type batchResult struct {
+	Values []interface{} `json:"values,omitempty"`
+	Error  *cmds.Error   `json:"error,omitempty"`
+}
+
+// serveBatch runs the commands of a batch request and writes their results.
+// Requests that can't be run get an error result rather than failing the
+// batch.
+func (h *handler) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if !h.allowRequest(w, r) {
+		return
+	}
+
+	var batch []invocation
+	dec := json.NewDecoder(r.Body)
+	// keep numbers as written so they are parsed like query values
+	dec.UseNumber()
+	if err := dec.Decode(&batch); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, bodyTooLarge(tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid batch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]batchResult, len(batch))
+	reqs := make([]*cmds.Request, 0, len(batch))
+	idx := make([]int, 0, len(batch))
+	for i, inv := range batch {
+		req, err := newInvocationRequest(r.Context(), h.root, inv)
+		if err != nil {
+			results[i].Error = cmdsError(err)
+			continue
+		}
+		reqs = append(reqs, req)
+		idx = append(idx, i)
+	}
+
+	x := invocationExecutor{h: h, r: r}
+	for i, res := range cmds.RunBatchWith(r.Context(), reqs, x, h.env, h.cfg.BatchConcurrency) {
+		results[idx[i]].Values = res.Values
+		if res.Err != nil {
+			results[idx[i]].Error = cmdsError(res.Err)
+		}
+	}
+
+	for k, v := range h.cfg.Headers {
+		if !skipAPIHeader(k) {
+			w.Header()[k] = v
+		}
+	}
+	w.Header().Set(contentTypeHeader, applicationJSON)
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		log.Error("error sending batch results: ", err)
+	}
+}
+
+// invocationExecutor runs the invocations sent within r like requests sent as
+// a URL, with the environment of the handler.
+type invocationExecutor struct {
+	h *handler
+	r *http.Request
+}
+
+func (x invocationExecutor) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+	return x.h.runInvocation(x.r, req, re)
+}
+
+// newInvocationRequest creates the request for inv, with the same checks as
+// for a request sent as a URL.
+func newInvocationRequest(ctx context.Context, root *cmds.Command, inv invocation) (*cmds.Request, error) {
+	cmdPath, err := root.Resolve(inv.Path)
+	if err != nil {
+		return nil, cmds.Errorf(cmds.ErrNotFound, "unknown command %q", inv.Path)
+	}
+	for _, c := range cmdPath {
+		if c.NoRemote {
+			return nil, cmds.Errorf(cmds.ErrNotFound, "unknown command %q", inv.Path)
+		}
+	}
+
+	opts := make(cmds.OptMap, len(inv.Options))
+	for k, v := range inv.Options {
+		switch v := v.(type) {
+		case json.Number:
+			opts[k] = v.String()
+		case []interface{}:
+			values := make([]string, len(v))
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, cmds.Errorf(cmds.ErrClient, "option %q should be an array of strings", k)
+				}
+				values[i] = s
+			}
+			opts[k] = values
+		default:
+			opts[k] = v
+		}
+	}
+
+	req, err := cmds.NewRequest(ctx, inv.Path, opts, inv.Arguments, nil, root)
+	if err != nil {
+		return nil, cmds.Errorf(cmds.ErrClient, "%s", err)
+	}
+	if err := req.Command.CheckArguments(req); err != nil {
+		return nil, cmds.Errorf(cmds.ErrClient, "%s", err)
+	}
+	if err := req.FillDefaults(); err != nil {
+		return nil, cmds.Errorf(cmds.ErrClient, "%s", err)
+	}
+	return req, nil
+}
+
+// cmdsError returns err as a *cmds.Error, wrapping errors of other types.
+func cmdsError(err error) *cmds.Error {
+	var e cmds.Error
+	if errors.As(err, &e) {
+		return &e
+	}
+	var pe *cmds.Error
+	if errors.As(err, &pe) {
+		return pe
+	}
+	return &cmds.Error{Message: err.Error(), Code: cmds.ErrNormal}
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestBatchEndpoint(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"version": {
+				Options: []cmds.Option{
+					cmds.IntOption("repeat", "how often to emit the version").WithDefault(1),
+				},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					n, _ := req.Options["repeat"].(int)
+					for i := 0; i < n; i++ {
+						if err := re.Emit(env.(testEnv).version); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+			"fail": {
+				Arguments: []cmds.Argument{
+					cmds.StringArg("reason", true, false, "why to fail"),
+				},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return errors.New(req.Arguments[0])
+				},
+			},
+			"local": {
+				NoRemote: true,
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return nil
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.APIPath = "/api/v0"
+	cfg.BatchPath = "/batch"
+	cfg.BatchConcurrency = 2
+	srv := httptest.NewServer(NewHandler(testEnv{version: "0.1.2", t: t}, root, cfg))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/api/v0/batch", applicationJSON, strings.NewReader(`[
+		{"path": ["version"], "options": {"repeat": 2}},
+		{"path": ["fail"], "arguments": ["out of disk"]},
+		{"path": ["local"]},
+		{"path": ["fail"]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assertStatus(t, res.StatusCode, http.StatusOK)
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"values":["0.1.2","0.1.2"]},` +
+		`{"error":{"Message":"out of disk","Code":0,"Type":"error"}},` +
+		`{"error":{"Message":"unknown command [\"local\"]","Code":5,"Type":"error"}},` +
+		`{"error":{"Message":"argument \"reason\" is required","Code":1,"Type":"error"}}]` + "\n"
+	if string(body) != expected {
+		t.Fatalf("expected %s, got %s", expected, body)
+	}
+}
+
+func TestBatchEndpointInvalid(t *testing.T) {
+	cfg := originCfg(defaultOrigins)
+	cfg.BatchPath = "/batch"
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, &cmds.Command{}, cfg))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/batch", applicationJSON, strings.NewReader(`{"path": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assertStatus(t, res.StatusCode, http.StatusBadRequest)
+}
+
+func TestBatchEndpointChecks(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{cmds.OptionTimeout},
+		Subcommands: map[string]*cmds.Command{
+			"pin": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, "pinned")
+				},
+			},
+			"wait": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					<-req.Context.Done()
+					return req.Context.Err()
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.BatchPath = "/batch"
+	cfg.RateLimitPaths = map[string]RateLimit{"/pin": {Rate: 0.25, Burst: 1}}
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, cfg))
+	defer srv.Close()
+
+	// the items of a batch are rate limited and time out like single requests
+	res, err := http.Post(srv.URL+"/batch", applicationJSON, strings.NewReader(`[
+		{"path": ["pin"]},
+		{"path": ["pin"]},
+		{"path": ["wait"], "options": {"timeout": "10ms"}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assertStatus(t, res.StatusCode, http.StatusOK)
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"values":["pinned"]},` +
+		`{"error":{"Message":"too many requests to /pin, retry in 4s","Code":3,"Type":"error"}},` +
+		`{"error":{"Message":"context deadline exceeded","Code":0,"Type":"error"}}]` + "\n"
+	if string(body) != expected {
+		t.Fatalf("expected %s, got %s", expected, body)
+	}
+}
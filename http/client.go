@@ -2,12 +2,14 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 
@@ -28,8 +30,11 @@ type client struct {
 	ua            string
 	apiPrefix     string
 	headers       map[string]string
+	headerFunc    func(*cmds.Request, http.Header)
 	fallback      cmds.Executor
 	rawAbsPath    bool
+	attempts      int
+	retryDelay    time.Duration
 }
 
 // ClientOpt is an option that can be passed to the HTTP client constructor.
@@ -52,6 +57,29 @@ func ClientWithHeader(key, value string) ClientOpt {
 	}
 }
 
+// ClientWithBearerToken authenticates all requests of the client with the
+// given bearer token.
+func ClientWithBearerToken(token string) ClientOpt {
+	return ClientWithHeader("Authorization", "Bearer "+token)
+}
+
+// ClientWithVersion sends version in the ClientVersionHeader header of all
+// requests of the client, for the server to check against the MinVersion of
+// the commands.
+func ClientWithVersion(version string) ClientOpt {
+	return ClientWithHeader(ClientVersionHeader, version)
+}
+
+// ClientWithHeaderFunc sets a function that is called with the headers of
+// every request sent by the client, after those added with ClientWithHeader.
+// It can set headers depending on the request, e.g. for tracing, and
+// override the headers set for all requests.
+func ClientWithHeaderFunc(fn func(req *cmds.Request, h http.Header)) ClientOpt {
+	return func(c *client) {
+		c.headerFunc = fn
+	}
+}
+
 // ClientWithHTTPClient specifies a custom http.Client. Defaults to
 // http.DefaultClient.
 func ClientWithHTTPClient(hc *http.Client) ClientOpt {
@@ -83,6 +111,20 @@ func ClientWithRawAbsPath(rawAbsPath bool) ClientOpt {
 	}
 }
 
+// ClientWithRetries makes the client try requests without a body up to
+// attempts times, waiting baseDelay before the first retry and twice as long
+// before every further one. Requests are retried when the connection to the
+// server can't be established. Commands marked as Idempotent are also retried
+// on other connection errors and on 502, 503 and 504 responses, since the
+// server may have run them already. Other errors, including 500, which is how
+// the server reports failed commands, are returned right away.
+func ClientWithRetries(attempts int, baseDelay time.Duration) ClientOpt {
+	return func(c *client) {
+		c.attempts = attempts
+		c.retryDelay = baseDelay
+	}
+}
+
 // NewClient constructs a new HTTP-backed command executor.
 func NewClient(address string, opts ...ClientOpt) cmds.Executor {
 	if !strings.HasPrefix(address, "http://") {
@@ -195,6 +237,9 @@ func (c *client) toHTTPRequest(req *cmds.Request) (*http.Request, error) {
 	for key, val := range c.headers {
 		httpReq.Header.Set(key, val)
 	}
+	if c.headerFunc != nil {
+		c.headerFunc(req, httpReq.Header)
+	}
 
 	httpReq = httpReq.WithContext(req.Context)
 	httpReq.Close = true
@@ -217,14 +262,7 @@ func (c *client) send(req *cmds.Request) (cmds.Response, error) {
 	// stream channel output
 	req.SetOption(cmds.ChanOpt, true)
 
-	// build http request
-	httpReq, err := c.toHTTPRequest(req)
-	if err != nil {
-		return nil, err
-	}
-
-	// send http request
-	httpRes, err := c.httpClient.Do(httpReq)
+	httpRes, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -246,6 +284,58 @@ func (c *client) send(req *cmds.Request) (cmds.Response, error) {
 	return res, nil
 }
 
+// do sends req, retrying as configured with ClientWithRetries.
+func (c *client) do(req *cmds.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		// build http request
+		httpReq, err := c.toHTTPRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		// send http request
+		httpRes, err := c.httpClient.Do(httpReq)
+
+		// a request body can only be sent once
+		if attempt >= c.attempts || httpReq.Body != nil || !shouldRetry(req.Command, httpRes, err) {
+			return httpRes, err
+		}
+		if httpRes != nil {
+			httpRes.Body.Close()
+		}
+
+		select {
+		case <-time.After(c.retryDelay << (attempt - 1)):
+		case <-req.Context.Done():
+			return nil, req.Context.Err()
+		}
+	}
+}
+
+func shouldRetry(cmd *cmds.Command, res *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		// nothing has been sent if we couldn't connect
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		var urlErr *url.Error
+		return cmd.Idempotent && errors.As(err, &urlErr)
+	}
+
+	if !cmd.Idempotent {
+		return false
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
 func getQuery(req *cmds.Request) (string, error) {
 	query := url.Values{}
 
@@ -259,7 +349,7 @@ func getQuery(req *cmds.Request) (string, error) {
 			for _, o := range val {
 				query.Add(k, o)
 			}
-		case bool, int, int64, uint, uint64, float64, string:
+		case bool, int, int64, uint, uint64, float64, string, time.Duration:
 			str := fmt.Sprintf("%v", v)
 			query.Set(k, str)
 		default:
@@ -1,10 +1,16 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 )
@@ -135,3 +141,154 @@ func TestClientHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestClientHeaderFunc(t *testing.T) {
+	var headers http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	c := NewClient(s.URL,
+		ClientWithHeader("Authorization", "Bearer default"),
+		ClientWithHeader("X-Tenant", "a"),
+		ClientWithHeaderFunc(func(req *cmds.Request, h http.Header) {
+			h.Set("X-Trace-Id", strings.Join(req.Path, "."))
+			if req.Path[0] == "admin" {
+				h.Set("Authorization", "Bearer admin")
+			}
+		}),
+	).(*client)
+	c.httpClient = s.Client()
+
+	for _, tc := range []struct {
+		path  []string
+		auth  string
+		trace string
+	}{
+		{path: []string{"version"}, auth: "Bearer default", trace: "version"},
+		{path: []string{"admin", "gc"}, auth: "Bearer admin", trace: "admin.gc"},
+	} {
+		headers = nil
+		c.send(&cmds.Request{Path: tc.path, Command: &cmds.Command{}, Root: &cmds.Command{}})
+		if headers == nil {
+			t.Fatal("handler has not been called")
+		}
+		if auth := headers.Get("Authorization"); auth != tc.auth {
+			t.Errorf("%v: expected authorization %q, got %q", tc.path, tc.auth, auth)
+		}
+		if trace := headers.Get("X-Trace-Id"); trace != tc.trace {
+			t.Errorf("%v: expected trace id %q, got %q", tc.path, tc.trace, trace)
+		}
+		if tenant := headers.Get("X-Tenant"); tenant != "a" {
+			t.Errorf("%v: expected default header to be kept, got %q", tc.path, tenant)
+		}
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	status := func(codes ...int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			n := int(calls.Add(1))
+			code := codes[len(codes)-1]
+			if n <= len(codes) {
+				code = codes[n-1]
+			}
+			w.WriteHeader(code)
+		}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		handler    http.HandlerFunc
+		idempotent bool
+		calls      int
+		status     int
+	}{
+		{name: "flaky", handler: status(503, 502, 200), idempotent: true, calls: 3, status: 200},
+		{name: "client error", handler: status(400), idempotent: true, calls: 1, status: 400},
+		{name: "command error", handler: status(500), idempotent: true, calls: 1, status: 500},
+		{name: "exhausted", handler: status(503), idempotent: true, calls: 4, status: 503},
+		{name: "mutating", handler: status(503, 200), calls: 1, status: 503},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls.Store(0)
+			s := httptest.NewServer(tc.handler)
+			defer s.Close()
+
+			c := NewClient(s.URL, ClientWithRetries(4, time.Millisecond)).(*client)
+			c.httpClient = s.Client()
+			r := &cmds.Request{Context: context.Background(), Path: []string{"version"}, Command: &cmds.Command{Idempotent: tc.idempotent}, Root: &cmds.Command{}}
+			res, err := c.do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, res.StatusCode)
+			}
+			if n := int(calls.Load()); n != tc.calls {
+				t.Errorf("expected %d calls, got %d", tc.calls, n)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		calls.Store(0)
+		s := httptest.NewServer(status(503))
+		defer s.Close()
+
+		c := NewClient(s.URL, ClientWithRetries(4, time.Hour)).(*client)
+		c.httpClient = s.Client()
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &cmds.Request{Context: ctx, Path: []string{"version"}, Command: &cmds.Command{Idempotent: true}, Root: &cmds.Command{}}
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := c.do(r)
+			errCh <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-errCh:
+			if err != context.Canceled {
+				t.Errorf("expected %v, got %v", context.Canceled, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected cancelling the context to stop retrying")
+		}
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expected 1 call, got %d", n)
+		}
+	})
+}
+
+func TestShouldRetry(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	resetErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	unavailable := &http.Response{StatusCode: http.StatusServiceUnavailable}
+
+	for _, tc := range []struct {
+		name       string
+		idempotent bool
+		res        *http.Response
+		err        error
+		retry      bool
+	}{
+		{name: "dial", err: dialErr, retry: true},
+		{name: "reset", err: resetErr, retry: false},
+		{name: "reset idempotent", idempotent: true, err: resetErr, retry: true},
+		{name: "unavailable", res: unavailable, retry: false},
+		{name: "unavailable idempotent", idempotent: true, res: unavailable, retry: true},
+		{name: "canceled", idempotent: true, err: &url.Error{Op: "Post", Err: context.Canceled}, retry: false},
+	} {
+		if retry := shouldRetry(&cmds.Command{Idempotent: tc.idempotent}, tc.res, tc.err); retry != tc.retry {
+			t.Errorf("%s: expected retry %v, got %v", tc.name, tc.retry, retry)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// serveCommands writes the structured help of every command in the tree that
+// isn't hidden, as a JSON array of cmds.CommandHelp. The paths are relative to
+// the API root.
+func (h *handler) serveCommands(w http.ResponseWriter, r *http.Request) {
+	if !h.allowRequest(w, r) {
+		return
+	}
+
+	tree, err := commandTree(h.root)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, applicationJSON)
+	if err := json.NewEncoder(w).Encode(tree); err != nil {
+		log.Error("error sending command tree: ", err)
+	}
+}
+
+func commandTree(root *cmds.Command) ([]cmds.CommandHelp, error) {
+	var tree []cmds.CommandHelp
+
+	var visit func(path []string) error
+	visit = func(path []string) error {
+		help, err := cmds.NewCommandHelp("", root, path)
+		if err != nil {
+			return err
+		}
+		help.Path = help.Path[1:]
+		tree = append(tree, *help)
+
+		for _, sub := range help.Subcommands {
+			if err := visit(append(path[:len(path):len(path)], sub.Name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return tree, visit([]string{})
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestCommandsEndpoint(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.OptionEncodingType,
+			cmds.BoolOption("debug-internals").WithHidden(true),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"version": {
+				Helptext: cmds.HelpText{Tagline: "Show the version."},
+				Subcommands: map[string]*cmds.Command{
+					"deps": {Helptext: cmds.HelpText{Tagline: "Show the dependencies."}},
+				},
+			},
+			"secret": {Hidden: true},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.APIPath = "/api/v0"
+	cfg.CommandsPath = "/commands"
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, cfg))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/v0/commands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assertStatus(t, res.StatusCode, http.StatusOK)
+
+	var tree []cmds.CommandHelp
+	if err := json.NewDecoder(res.Body).Decode(&tree); err != nil {
+		t.Fatal(err)
+	}
+
+	byPath := make(map[string]cmds.CommandHelp)
+	for _, help := range tree {
+		byPath[strings.Join(help.Path, " ")] = help
+	}
+	if len(byPath) != 3 {
+		t.Fatalf("expected 3 commands, got %v", tree)
+	}
+
+	version, ok := byPath["version"]
+	if !ok {
+		t.Fatalf("expected the version command in %v", tree)
+	}
+	if version.Tagline != "Show the version." {
+		t.Errorf("unexpected tagline %q", version.Tagline)
+	}
+	if _, ok := byPath["version deps"]; !ok {
+		t.Errorf("expected the version deps command in %v", tree)
+	}
+	if _, ok := byPath["secret"]; ok {
+		t.Error("expected the hidden command to be left out")
+	}
+	for _, opt := range byPath[""].Options {
+		if opt.Names[0] == "debug-internals" {
+			t.Error("expected the hidden option to be left out")
+		}
+	}
+	for _, sub := range byPath[""].Subcommands {
+		if sub.Name == "secret" {
+			t.Error("expected the hidden subcommand to be left out")
+		}
+	}
+}
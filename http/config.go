@@ -1,10 +1,12 @@
 package http
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	cors "github.com/rs/cors"
 )
@@ -31,6 +33,62 @@ type ServerConfig struct {
 	// websites to include resources from the API but not _read_ them.
 	AllowGet bool
 
+	// CheckToken, if set, is called with the bearer token of every request.
+	// Requests without a token, or with one CheckToken returns false for,
+	// are rejected with 401 before any command is run.
+	CheckToken func(token string) bool
+
+	// CommandsPath, if set, is the path below APIPath at which the
+	// structured help of the whole command tree is served to GET requests,
+	// e.g. "/commands". Hidden commands and options are left out.
+	CommandsPath string
+
+	// BatchPath, if set, is the path below APIPath at which POST requests
+	// run a batch of commands, e.g. "/batch". The body is a JSON array of
+	// {"path", "options", "arguments"} objects, and the response a JSON array
+	// of their results in the same order.
+	BatchPath string
+
+	// BatchConcurrency is the number of commands of a batch run at a time.
+	// Commands are run one after the other when it's less than 1.
+	BatchConcurrency int
+
+	// SSEKeepAlive is the interval of the comments sent to keep the
+	// connection alive while a command streams its output as Server-Sent
+	// Events, which clients ask for with "Accept: text/event-stream". It
+	// defaults to 15 seconds.
+	SSEKeepAlive time.Duration
+
+	// WebSocketPath, if set, is the path below APIPath at which clients
+	// connect with a WebSocket to run commands and stream their output,
+	// e.g. "/ws". Clients send {"type": "run", "path", "options",
+	// "arguments"} messages, one command at a time, and {"type": "cancel"}
+	// to cancel the running command. The server answers with a
+	// {"type": "value", "value"} message per value, followed by
+	// {"type": "end"} or {"type": "error", "error"}.
+	WebSocketPath string
+
+	// RateLimit, if set, limits the rate of the requests of every client.
+	// Requests over the limit are rejected with 429 and a Retry-After
+	// header.
+	RateLimit *RateLimit
+
+	// RateLimitPaths sets the limits of the commands at and below paths
+	// relative to APIPath, e.g. "/add", overriding RateLimit. Every path
+	// has buckets of its own.
+	RateLimitPaths map[string]RateLimit
+
+	// RateLimitHeader, if set, is the header identifying clients for rate
+	// limiting, e.g. "X-Forwarded-For" behind a proxy. Clients are
+	// identified by their IP address otherwise.
+	RateLimitHeader string
+
+	// MaxBodySize, if set, limits the size of request bodies in bytes.
+	// Larger requests are rejected with 413. Commands can override it with
+	// cmds.Command.MaxBodySize. It also limits the size of the messages sent
+	// over a WebSocket.
+	MaxBodySize int64
+
 	// corsOpts is a set of options for CORS headers.
 	corsOpts *cors.Options
 
@@ -85,6 +143,12 @@ func (cfg *ServerConfig) SetAllowCredentials(flag bool) {
 	cfg.corsOpts.AllowCredentials = flag
 }
 
+func (cfg *ServerConfig) AllowedHeaders() []string {
+	cfg.corsOptsRWMutex.RLock()
+	defer cfg.corsOptsRWMutex.RUnlock()
+	return cfg.corsOpts.AllowedHeaders
+}
+
 func (cfg *ServerConfig) AddAllowedHeaders(headers ...string) {
 	cfg.corsOptsRWMutex.Lock()
 	defer cfg.corsOptsRWMutex.Unlock()
@@ -116,6 +180,31 @@ func allowOrigin(r *http.Request, cfg *ServerConfig) bool {
 	return false
 }
 
+// allowToken checks the bearer token of the request with cfg.CheckToken.
+func allowToken(r *http.Request, cfg *ServerConfig) bool {
+	if cfg.CheckToken == nil {
+		return true
+	}
+
+	token, ok := bearerToken(r)
+	return ok && cfg.CheckToken(token)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, ok && token != ""
+}
+
+type bearerTokenKey struct{}
+
+// BearerToken returns the bearer token of the HTTP request a command was sent
+// with, from the context of the cmds.Request, e.g. to check it in
+// cmds.Command.Authorize.
+func BearerToken(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(bearerTokenKey{}).(string)
+	return token, ok
+}
+
 // allowReferer this is here to prevent some CSRF attacks that
 // the API would be vulnerable to. We check that the Referer
 // is allowed by CORS Origin (origins and referrers here will
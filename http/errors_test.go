@@ -40,6 +40,12 @@ func TestErrors(t *testing.T) {
 			bodyStr: `{"Message":"an error occurred","Code":0,"Type":"error"}` + "\n",
 		},
 
+		{
+			path:    []string{"notfound"},
+			status:  "404 Not Found",
+			bodyStr: `{"Message":"no such key","Code":5,"Type":"error"}` + "\n",
+		},
+
 		{
 			path:       []string{"lateerror"},
 			status:     "200 OK",
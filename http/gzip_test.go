@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestGzip(t *testing.T) {
+	release := make(chan struct{})
+	gzipRoot := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"echo": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, "hello")
+				},
+			},
+			"stream": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					if err := re.Emit("first"); err != nil {
+						return err
+					}
+					<-release
+					return re.Emit("second")
+				},
+			},
+		},
+	}
+	srv := httptest.NewServer(NewHandler(nil, gzipRoot, NewServerConfig()))
+	defer srv.Close()
+
+	// setting Accept-Encoding ourselves stops the transport from
+	// transparently decompressing the body
+	post := func(path, acceptEncoding string) *http.Response {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		res := post("/echo", "gzip")
+		defer res.Body.Close()
+		if enc := res.Header.Get("Content-Encoding"); enc != "gzip" {
+			t.Fatalf("expected gzip content encoding, got %q", enc)
+		}
+		gz, err := gzip.NewReader(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "\"hello\"\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		res := post("/echo", "identity")
+		defer res.Body.Close()
+		if enc := res.Header.Get("Content-Encoding"); enc != "" {
+			t.Fatalf("expected no content encoding, got %q", enc)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "\"hello\"\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		res := post("/stream", "gzip")
+		defer res.Body.Close()
+		gz, err := gzip.NewReader(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bufio.NewReader(gz)
+		for i, expected := range []string{"first", "second"} {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v string
+			if err := json.Unmarshal([]byte(line), &v); err != nil || v != expected {
+				t.Fatalf("expected %q, got %q (%v)", expected, line, err)
+			}
+			// the first value must arrive while the command is blocked
+			if i == 0 {
+				close(release)
+			}
+		}
+	})
+}
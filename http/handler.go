@@ -3,12 +3,13 @@ package http
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"runtime/debug"
 	"strings"
 	"sync"
-	"time"
 
+	"github.com/gorilla/websocket"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	logging "github.com/ipfs/go-log"
 	cors "github.com/rs/cors"
@@ -53,9 +54,10 @@ func skipAPIHeader(h string) bool {
 
 // the internal handler for the API
 type handler struct {
-	root *cmds.Command
-	cfg  *ServerConfig
-	env  cmds.Environment
+	root    *cmds.Command
+	cfg     *ServerConfig
+	env     cmds.Environment
+	limiter *rateLimiter
 }
 
 // NewHandler creates the http.Handler for the given commands.
@@ -69,15 +71,17 @@ func NewHandler(env cmds.Environment, root *cmds.Command, cfg *ServerConfig) htt
 	var h http.Handler
 
 	h = &handler{
-		env:  env,
-		root: root,
-		cfg:  cfg,
+		env:     env,
+		root:    root,
+		cfg:     cfg,
+		limiter: newRateLimiter(cfg),
 	}
 
 	if cfg.APIPath != "" {
 		h = newPrefixHandler(cfg.APIPath, h) // wrap with path prefix checker and trimmer
 	}
-	h = c.Handler(h) // wrap with CORS handler
+	h = gzipHandler(h) // compress responses for clients accepting gzip
+	h = c.Handler(h)   // wrap with CORS handler
 
 	return h
 }
@@ -87,7 +91,8 @@ type requestLogger interface {
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	log.Debug("incoming API request: ", r.URL)
+	// the query holds the option values, which may be secret
+	log.Debug("incoming API request: ", r.URL.Path)
 
 	var re ResponseEmitter
 
@@ -107,6 +112,43 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	// the commands can find the token in the context of their request
+	if token, ok := bearerToken(r); ok {
+		r = r.WithContext(context.WithValue(r.Context(), bearerTokenKey{}, token))
+	}
+
+	if h.limiter != nil && r.Method != http.MethodOptions {
+		if ok, wait := h.limiter.allow(r); !ok {
+			w.Header().Set("Retry-After", retryAfter(wait))
+			http.Error(w, "429 - Too Many Requests", http.StatusTooManyRequests)
+			log.Warnf("API rate limited request to %s", r.URL.Path)
+			return
+		}
+	}
+
+	if limit := h.maxBodySize(r.URL.Path); limit > 0 && r.Body != http.NoBody {
+		if r.ContentLength > limit {
+			http.Error(w, bodyTooLarge(limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	if h.cfg.CommandsPath != "" && r.URL.Path == h.cfg.CommandsPath && r.Method == http.MethodGet {
+		h.serveCommands(w, r)
+		return
+	}
+
+	if h.cfg.BatchPath != "" && r.URL.Path == h.cfg.BatchPath && r.Method == http.MethodPost {
+		h.serveBatch(w, r)
+		return
+	}
+
+	if h.cfg.WebSocketPath != "" && r.URL.Path == h.cfg.WebSocketPath && websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r)
+		return
+	}
+
 	// First of all, check if we are allowed to handle the request method
 	// or we are configured not to.
 	//
@@ -133,9 +175,7 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !allowOrigin(r, h.cfg) || !allowReferer(r, h.cfg) || !allowUserAgent(r, h.cfg) {
-		http.Error(w, "403 - Forbidden", http.StatusForbidden)
-		log.Warnf("API blocked request to %s. (possible CSRF)", r.URL)
+	if !h.allowRequest(w, r) {
 		return
 	}
 
@@ -160,14 +200,23 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	req, err := parseRequest(r, h.root)
 	if err != nil {
 		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
 		if err == ErrNotFound {
 			status = http.StatusNotFound
+		} else if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+			err = errors.New(bodyTooLarge(tooLarge.Limit))
 		}
 
 		http.Error(w, err.Error(), status)
 		return
 	}
 
+	if err := checkMinVersion(req, r.Header.Get(ClientVersionHeader)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// set user's headers first.
 	for k, v := range h.cfg.Headers {
 		if !skipAPIHeader(k) {
@@ -176,24 +225,43 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Handle the timeout up front.
-	var cancel func()
-	if timeoutStr, ok := req.Options[cmds.TimeoutOpt]; ok {
-		timeout, err := time.ParseDuration(timeoutStr.(string))
-		if err != nil {
-			return
-		}
-		req.Context, cancel = context.WithTimeout(req.Context, timeout)
-	} else {
-		req.Context, cancel = context.WithCancel(req.Context)
+	cancel, err := setTimeout(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 	defer cancel()
 
-	re, err = NewResponseEmitter(w, r.Method, req, withRequestBodyEOFChan(bodyEOFChan))
+	if acceptsEventStream(r) && r.Method != http.MethodHead {
+		re, err = newSSEEmitter(w, req, h.cfg.SSEKeepAlive)
+	} else {
+		re, err = NewResponseEmitter(w, r.Method, req, withRequestBodyEOFChan(bodyEOFChan))
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	h.call(req, re)
+}
+
+// setTimeout replaces the context of req with one that's canceled after the
+// value of the timeout option, if set. The returned function releases it.
+func setTimeout(req *cmds.Request) (context.CancelFunc, error) {
+	var cancel context.CancelFunc
+	if timeout, err := req.Options.GetDurationE(cmds.TimeoutOpt); err == nil {
+		req.Context, cancel = context.WithTimeout(req.Context, timeout)
+	} else if !errors.Is(err, cmds.ErrOptionNotSet) {
+		return nil, cmds.Errorf(cmds.ErrClient, "%s", err)
+	} else {
+		req.Context, cancel = context.WithCancel(req.Context)
+	}
+	return cancel, nil
+}
+
+// call calls the command of req, logging it if the environment is a
+// requestLogger.
+func (h *handler) call(req *cmds.Request, re cmds.ResponseEmitter) {
 	if reqLogger, ok := h.env.(requestLogger); ok {
 		done := reqLogger.LogRequest(req)
 		defer done()
@@ -202,6 +270,79 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.root.Call(req, re, h.env)
 }
 
+// checkInvocation applies the checks of requests sent as a URL to a command
+// invocation sent within r, as part of a batch or over a WebSocket: the rate
+// limit of its command and its minimum client version.
+func (h *handler) checkInvocation(r *http.Request, req *cmds.Request) error {
+	if h.limiter != nil {
+		path := "/" + strings.Join(req.Path, "/")
+		if ok, wait := h.limiter.allowPath(r, path); !ok {
+			log.Warnf("API rate limited request to %s", path)
+			return cmds.Errorf(cmds.ErrRateLimited, "too many requests to %s, retry in %ss", path, retryAfter(wait))
+		}
+	}
+	return checkMinVersion(req, r.Header.Get(ClientVersionHeader))
+}
+
+// runInvocation checks and runs an invocation sent within r like a request
+// sent as a URL, see checkInvocation.
+func (h *handler) runInvocation(r *http.Request, req *cmds.Request, re cmds.ResponseEmitter) error {
+	if err := h.checkInvocation(r, req); err != nil {
+		return err
+	}
+
+	// the command gets a copy of the request, the response may read the
+	// context of req after the timeout was released
+	callReq := *req
+	cancel, err := setTimeout(&callReq)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	h.call(&callReq, re)
+	return nil
+}
+
+// allowRequest checks the origin, referer, user agent and token of r. If r
+// isn't allowed, it responds with an error and returns false.
+func (h *handler) allowRequest(w http.ResponseWriter, r *http.Request) bool {
+	if !allowOrigin(r, h.cfg) || !allowReferer(r, h.cfg) || !allowUserAgent(r, h.cfg) {
+		http.Error(w, "403 - Forbidden", http.StatusForbidden)
+		log.Warnf("API blocked request to %s. (possible CSRF)", r.URL)
+		return false
+	}
+
+	if !allowToken(r, h.cfg) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "401 - Unauthorized", http.StatusUnauthorized)
+		log.Warnf("API rejected unauthorized request to %s", r.URL.Path)
+		return false
+	}
+	return true
+}
+
+// maxBodySize returns the limit on the body size of requests to the command
+// at path, or a value less than 1 if there's none.
+func (h *handler) maxBodySize(path string) int64 {
+	cmd := h.root
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		_, sub := cmd.Subcommand(name)
+		if sub == nil {
+			break
+		}
+		cmd = sub
+	}
+	if cmd.MaxBodySize != 0 {
+		return cmd.MaxBodySize
+	}
+	return h.cfg.MaxBodySize
+}
+
+func bodyTooLarge(limit int64) string {
+	return fmt.Sprintf("request body too large, the limit is %d bytes", limit)
+}
+
 func setAllowHeader(w http.ResponseWriter, allowGet bool) {
 	allowedMethods := []string{http.MethodOptions, http.MethodPost}
 	if allowGet {
@@ -69,6 +69,11 @@ var (
 					return errors.New("an error occurred")
 				},
 			},
+			"notfound": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.Errorf(cmds.ErrNotFound, "no such key")
+				},
+			},
 			"lateerror": {
 				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 					re.Emit("some value")
@@ -1,16 +1,21 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 
@@ -238,3 +243,272 @@ type readCloser struct {
 	io.Reader
 	io.Closer
 }
+
+func TestBearerToken(t *testing.T) {
+	const token = "s3cr3t-t0k3n"
+
+	env, unused := getTestServer(t, nil, false)
+	unused.Close()
+
+	cfg := originCfg(defaultOrigins)
+	cfg.CheckToken = func(tok string) bool { return tok == token }
+	srv := httptest.NewServer(NewHandler(env, cmdRoot, cfg))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name string
+		opts []ClientOpt
+		ok   bool
+	}{
+		{name: "accepted", opts: []ClientOpt{ClientWithBearerToken(token)}, ok: true},
+		{name: "rejected", opts: []ClientOpt{ClientWithBearerToken("wrong-" + token)}},
+		{name: "missing"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := cmds.NewRequest(context.Background(), []string{"version"}, nil, nil, nil, cmdRoot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := NewClient(srv.URL, tc.opts...)
+
+			httpReq, err := c.(*client).toHTTPRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpRes, err := http.DefaultClient.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(httpRes.Body)
+			httpRes.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.ok {
+				assertStatus(t, httpRes.StatusCode, http.StatusOK)
+				return
+			}
+			assertStatus(t, httpRes.StatusCode, http.StatusUnauthorized)
+			if strings.Contains(string(body), token) {
+				t.Errorf("expected the token to not be part of the response, got %q", body)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	root := &cmds.Command{
+		Authorize: func(req *cmds.Request) error {
+			if token, _ := BearerToken(req.Context); token != "admin-token" {
+				return errors.New("admins only")
+			}
+			return nil
+		},
+		Subcommands: map[string]*cmds.Command{
+			"version": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, "0.1.2")
+				},
+			},
+		},
+	}
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		token  string
+		status int
+		body   string
+	}{
+		{"admin-token", http.StatusOK, "\"0.1.2\"\n"},
+		{"guest-token", http.StatusForbidden, `{"Message":"admins only","Code":4,"Type":"error"}` + "\n"},
+		{"", http.StatusForbidden, `{"Message":"admins only","Code":4,"Type":"error"}` + "\n"},
+	} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/version", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.status || string(body) != tc.body {
+			t.Errorf("token %q: expected %d %q, got %d %q", tc.token, tc.status, tc.body, res.StatusCode, body)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var ran bool
+	root := &cmds.Command{
+		Options: []cmds.Option{cmds.OptionDryRun},
+		Subcommands: map[string]*cmds.Command{
+			"rm": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					ran = true
+					return cmds.EmitOnce(re, "removed")
+				},
+				DryRun: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, "would remove")
+				},
+			},
+			"gc": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					ran = true
+					return cmds.EmitOnce(re, "collected")
+				},
+			},
+		},
+	}
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/rm?dry-run=true", http.StatusOK, "\"would remove\"\n"},
+		{"/gc?dry-run=true", http.StatusBadRequest, `{"Message":"gc does not support --dry-run","Code":1,"Type":"error"}` + "\n"},
+	} {
+		res, err := http.Post(srv.URL+tc.path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.status || string(body) != tc.body {
+			t.Errorf("%s: expected %d %q, got %d %q", tc.path, tc.status, tc.body, res.StatusCode, body)
+		}
+	}
+	if ran {
+		t.Error("expected Run not to be called for dry runs")
+	}
+}
+
+func TestPaginated(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{cmds.OptionLimit, cmds.OptionOffset},
+		Subcommands: map[string]*cmds.Command{
+			"list": {
+				Paginated: true,
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, []int{0, 1, 2, 3, 4, 5})
+				},
+			},
+		},
+	}
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		query string
+		body  string
+	}{
+		{"", "[0,1,2,3,4,5]\n"},
+		{"?offset=4", "[4,5]\n"},
+		{"?offset=1&limit=2", "[1,2]\n"},
+	} {
+		res, err := http.Post(srv.URL+"/list"+tc.query, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK || string(body) != tc.body {
+			t.Errorf("%q: expected %q, got %d %q", tc.query, tc.body, res.StatusCode, body)
+		}
+	}
+}
+
+func TestStreamingResponse(t *testing.T) {
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+	streamRoot := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"stream": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					if err := re.Emit("first"); err != nil {
+						return err
+					}
+					select {
+					case <-release:
+					case <-req.Context.Done():
+						close(cancelled)
+						return req.Context.Err()
+					}
+					return re.Emit("second")
+				},
+			},
+		},
+	}
+	srv := httptest.NewServer(NewHandler(nil, streamRoot, NewServerConfig()))
+	defer srv.Close()
+
+	stream := func(ctx context.Context) (*bufio.Reader, func()) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/stream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Header.Get(channelHeader) != "1" {
+			t.Errorf("expected a chunked response, got headers %v", res.Header)
+		}
+		return bufio.NewReader(res.Body), func() { res.Body.Close() }
+	}
+	readValue := func(r *bufio.Reader) string {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v string
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("expected a JSON value per line, got %q: %s", line, err)
+		}
+		return v
+	}
+
+	// the first value arrives while the command is still blocked
+	body, done := stream(context.Background())
+	if v := readValue(body); v != "first" {
+		t.Fatalf("expected first, got %q", v)
+	}
+	close(release)
+	if v := readValue(body); v != "second" {
+		t.Fatalf("expected second, got %q", v)
+	}
+	done()
+
+	// disconnecting cancels the request context of the command
+	release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	body, done = stream(ctx)
+	defer done()
+	if v := readValue(body); v != "first" {
+		t.Fatalf("expected first, got %q", v)
+	}
+	cancel()
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the request context to be cancelled after the client disconnected")
+	}
+}
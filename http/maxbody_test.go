@@ -0,0 +1,173 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ipfs/boxo/files"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	upload := func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		it := req.Files.Entries()
+		var n int64
+		for it.Next() {
+			f := files.ToFile(it.Node())
+			c, err := io.Copy(io.Discard, f)
+			if err != nil {
+				return err
+			}
+			n += c
+		}
+		if it.Err() != nil {
+			return it.Err()
+		}
+		return cmds.EmitOnce(re, n)
+	}
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"add": {
+				Arguments: []cmds.Argument{
+					cmds.FileArg("data", true, false, "the data to add"),
+				},
+				Run: upload,
+			},
+			"import": {
+				Arguments: []cmds.Argument{
+					cmds.FileArg("data", true, false, "the data to import"),
+				},
+				MaxBodySize: 1 << 20,
+				Run:         upload,
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.MaxBodySize = 1024
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, cfg))
+	defer srv.Close()
+
+	post := func(path string, size int, chunked bool) (int, string) {
+		mfr := files.NewMultiFileReader(files.NewMapDirectory(map[string]files.Node{
+			"data": files.NewBytesFile(bytes.Repeat([]byte("x"), size)),
+		}), true, false)
+		var body io.Reader = mfr
+		if !chunked {
+			data, err := io.ReadAll(mfr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = bytes.NewReader(data)
+		}
+		res, err := http.Post(srv.URL+path, "multipart/form-data; boundary="+mfr.Boundary(), body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		out, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, strings.TrimSpace(string(out))
+	}
+
+	const tooLarge = "request body too large, the limit is 1024 bytes"
+	for _, tc := range []struct {
+		path    string
+		size    int
+		chunked bool
+		status  int
+		body    string
+	}{
+		{"/add", 100, false, http.StatusOK, "100"},
+		{"/add", 100, true, http.StatusOK, "100"},
+		{"/add", 2048, false, http.StatusRequestEntityTooLarge, tooLarge},
+		{"/add", 2048, true, http.StatusRequestEntityTooLarge, `{"Message":"` + tooLarge + `","Code":1,"Type":"error"}`},
+		{"/import", 2048, false, http.StatusOK, "2048"},
+	} {
+		status, body := post(tc.path, tc.size, tc.chunked)
+		if status != tc.status || body != tc.body {
+			t.Errorf("%s with %d bytes (chunked: %v): expected %d %q, got %d %q", tc.path, tc.size, tc.chunked, tc.status, tc.body, status, body)
+		}
+	}
+}
+
+func TestMaxBodySizeBatchAndWebSocket(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"echo": {
+				Arguments: []cmds.Argument{
+					cmds.StringArg("text", true, false, "the text to echo"),
+				},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, req.Arguments[0])
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.MaxBodySize = 1024
+	cfg.BatchPath = "/batch"
+	cfg.WebSocketPath = "/ws"
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, cfg))
+	defer srv.Close()
+
+	echo := func(size int) string {
+		return `"path": ["echo"], "arguments": ["` + strings.Repeat("x", size) + `"]`
+	}
+
+	// the batch endpoint rejects large bodies, whether their length is known
+	// up front or not
+	for _, chunked := range []bool{false, true} {
+		var body io.Reader = strings.NewReader("[{" + echo(2048) + "}]")
+		if chunked {
+			body = io.MultiReader(body)
+		}
+		res, err := http.Post(srv.URL+"/batch", applicationJSON, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusRequestEntityTooLarge || strings.TrimSpace(string(out)) != "request body too large, the limit is 1024 bytes" {
+			t.Errorf("batch (chunked: %v): expected 413, got %d %q", chunked, res.StatusCode, out)
+		}
+	}
+
+	// over a WebSocket, the limit applies to every message
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "run", `+echo(100)+"}")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || !strings.HasPrefix(string(data), `{"type":"value"`) {
+		t.Fatalf("expected a value message, got %s (%v)", data, err)
+	}
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "run", `+echo(2048)+"}")); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("expected the connection to be closed for a too large message, got %v", err)
+	}
+}
@@ -217,8 +217,8 @@ func parseResponse(httpRes *http.Response, req *cmds.Request) (cmds.Response, er
 		e := &cmds.Error{}
 
 		switch {
-		case httpRes.StatusCode == http.StatusNotFound:
-			// handle 404s
+		case httpRes.StatusCode == http.StatusNotFound && res.dec == nil:
+			// handle 404s of unknown commands, commands send encoded errors
 			e.Message = "Command not found."
 			e.Code = cmds.ErrClient
 		case contentType == plainText:
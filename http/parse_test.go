@@ -207,6 +207,22 @@ func TestParseResponse(t *testing.T) {
 			body: mkbuf("test error"),
 			err:  fmt.Errorf("unknown error content type: %s", "evil/bad"),
 		},
+		{
+			status: 404,
+			header: http.Header{
+				contentTypeHeader: []string{"application/json"},
+			},
+			body: mkbuf(`{"Message":"no such key","Code":5,"Type":"error"}`),
+			err:  cmds.Errorf(cmds.ErrNotFound, "no such key"),
+		},
+		{
+			status: 404,
+			header: http.Header{
+				contentTypeHeader: []string{"text/plain; charset=utf-8"},
+			},
+			body: mkbuf("404 page not found\n"),
+			err:  cmds.Errorf(cmds.ErrClient, "Command not found."),
+		},
 	}
 
 	for _, tc := range tcs {
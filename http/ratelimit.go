@@ -0,0 +1,147 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket limit on the requests of a client. Clients can
+// send Burst requests at once, and Rate more per second after that.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// rateLimiter keeps a token bucket per client and command path.
+type rateLimiter struct {
+	cfg *ServerConfig
+	now func() time.Time
+
+	l         sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(cfg *ServerConfig) *rateLimiter {
+	if cfg.RateLimit == nil && len(cfg.RateLimitPaths) == 0 {
+		return nil
+	}
+	return &rateLimiter{
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// limitFor returns the limit for requests to path, the limit of the longest
+// path in cfg.RateLimitPaths that path is or is below, or cfg.RateLimit. The
+// bucket of a request is the one of that configured path.
+func (rl *rateLimiter) limitFor(path string) (string, *RateLimit) {
+	prefix := path
+	for {
+		if limit, ok := rl.cfg.RateLimitPaths[prefix]; ok {
+			return prefix, &limit
+		}
+		i := strings.LastIndex(prefix, "/")
+		if i <= 0 {
+			return "", rl.cfg.RateLimit
+		}
+		prefix = prefix[:i]
+	}
+}
+
+// allow takes a token from the bucket of the client of r, and returns how
+// long the client has to wait for the next one if there's none left.
+func (rl *rateLimiter) allow(r *http.Request) (bool, time.Duration) {
+	return rl.allowPath(r, r.URL.Path)
+}
+
+// allowPath is like allow, but for a request to the command at path sent
+// within r, like the commands of a batch.
+func (rl *rateLimiter) allowPath(r *http.Request, path string) (bool, time.Duration) {
+	path, limit := rl.limitFor(path)
+	if limit == nil {
+		return true, 0
+	}
+	key := rl.client(r) + " " + path
+
+	rl.l.Lock()
+	defer rl.l.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{limit: *limit, tokens: float64(limit.Burst), last: now}
+		rl.buckets[key] = b
+	}
+	b.refill(now)
+
+	if b.tokens < 1 {
+		if b.limit.Rate <= 0 {
+			return false, time.Duration(math.MaxInt64)
+		}
+		wait := time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// client returns the key identifying the client of r, the value of
+// cfg.RateLimitHeader if set, or the remote IP.
+func (rl *rateLimiter) client(r *http.Request) string {
+	if h := rl.cfg.RateLimitHeader; h != "" {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// sweep drops the buckets that have filled up again, at most once a minute,
+// so clients that went away don't take up memory.
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < time.Minute {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		if b.refill(now); b.tokens >= float64(b.limit.Burst) {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if max := float64(b.limit.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+}
+
+// retryAfter formats wait as the seconds of a Retry-After header, rounded up.
+func retryAfter(wait time.Duration) string {
+	secs := int64(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var rateLimitRoot = &cmds.Command{
+	Subcommands: map[string]*cmds.Command{
+		"version": {
+			Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+				return cmds.EmitOnce(re, "0.1.2")
+			},
+		},
+		"pin": {
+			Subcommands: map[string]*cmds.Command{
+				"add": {
+					Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+						return cmds.EmitOnce(re, "pinned")
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := originCfg(defaultOrigins)
+	cfg.APIPath = "/api/v0"
+	cfg.RateLimit = &RateLimit{Rate: 1, Burst: 2}
+	cfg.RateLimitPaths = map[string]RateLimit{"/pin": {Rate: 0.25, Burst: 1}}
+	cfg.RateLimitHeader = "X-Client"
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, rateLimitRoot, cfg))
+	defer srv.Close()
+
+	post := func(client, path string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/v0"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Client", client)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	for i, tc := range []struct {
+		client, path string
+		status       int
+		retryAfter   string
+	}{
+		{"alice", "/version", http.StatusOK, ""},
+		{"alice", "/version", http.StatusOK, ""},
+		{"alice", "/version", http.StatusTooManyRequests, "1"},
+		// the more expensive command has a limit of its own
+		{"alice", "/pin/add", http.StatusOK, ""},
+		{"alice", "/pin/add", http.StatusTooManyRequests, "4"},
+		// other clients have buckets of their own
+		{"bob", "/version", http.StatusOK, ""},
+		{"bob", "/pin/add", http.StatusOK, ""},
+	} {
+		res := post(tc.client, tc.path)
+		if res.StatusCode != tc.status {
+			t.Errorf("request %d: expected status %d, got %d", i, tc.status, res.StatusCode)
+		}
+		if ra := res.Header.Get("Retry-After"); ra != tc.retryAfter {
+			t.Errorf("request %d: expected Retry-After %q, got %q", i, tc.retryAfter, ra)
+		}
+	}
+}
+
+func TestRateLimitSlowClient(t *testing.T) {
+	cfg := originCfg(defaultOrigins)
+	cfg.RateLimit = &RateLimit{Rate: 20, Burst: 1}
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, rateLimitRoot, cfg))
+	defer srv.Close()
+
+	for i := 0; i < 5; i++ {
+		res, err := http.Post(srv.URL+"/version", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		assertStatus(t, res.StatusCode, http.StatusOK)
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestRateLimitBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	rl := newRateLimiter(&ServerConfig{RateLimit: &RateLimit{Rate: 2, Burst: 1}})
+	rl.now = func() time.Time { return now }
+
+	r := httptest.NewRequest(http.MethodPost, "/version", nil)
+	if ok, _ := rl.allow(r); !ok {
+		t.Fatal("expected the first request to be allowed")
+	}
+	if ok, wait := rl.allow(r); ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, got %v, %v", ok, wait)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if ok, wait := rl.allow(r); ok || wait != 250*time.Millisecond {
+		t.Fatalf("expected to wait 250ms, got %v, %v", ok, wait)
+	}
+
+	// full buckets are dropped
+	now = now.Add(time.Hour)
+	other := httptest.NewRequest(http.MethodPost, "/version", nil)
+	other.RemoteAddr = "192.0.2.2:1234"
+	rl.allow(other)
+	if _, ok := rl.buckets["192.0.2.1 "]; ok || len(rl.buckets) != 1 {
+		t.Fatalf("expected the idle bucket to be dropped, got %v", rl.buckets)
+	}
+}
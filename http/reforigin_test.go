@@ -3,6 +3,7 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -392,3 +393,48 @@ func TestEncoding(t *testing.T) {
 		tc.test(t)
 	}
 }
+
+func TestPreflight(t *testing.T) {
+	cfg := originCfg([]string{"http://localhost"})
+	cfg.AddAllowedHeaders("X-Trace-Id")
+	if hdrs := cfg.AllowedHeaders(); len(hdrs) != 1 || hdrs[0] != "X-Trace-Id" {
+		t.Fatalf("unexpected allowed headers %v", hdrs)
+	}
+
+	env, _ := getTestServer(t, nil, true)
+	server := httptest.NewServer(NewHandler(env, cmdRoot, cfg))
+	defer server.Close()
+
+	preflight := func(origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, server.URL+"/version", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "x-trace-id")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	res := preflight("http://localhost")
+	assertStatus(t, res.StatusCode, http.StatusNoContent)
+	assertHeaders(t, res.Header, map[string]string{
+		ACAOrigin:                      "http://localhost",
+		ACAMethods:                     "POST",
+		"Access-Control-Allow-Headers": "x-trace-id",
+	})
+
+	// preflights of other origins get no CORS headers, so browsers block
+	// the actual request
+	res = preflight("http://evil.example")
+	assertHeaders(t, res.Header, map[string]string{
+		ACAOrigin:                      "",
+		ACAMethods:                     "",
+		"Access-Control-Allow-Headers": "",
+	})
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -82,6 +83,7 @@ type responseEmitter struct {
 
 	streaming bool
 	closed    bool
+	errStatus int // overrides the status sent for errors
 	once      sync.Once
 	method    string
 }
@@ -169,6 +171,13 @@ func (re *responseEmitter) closeWithError(err error) error {
 		return cmds.ErrClosingClosedEmitter
 	}
 
+	// bodies over the limit of the command are rejected
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = &cmds.Error{Message: bodyTooLarge(tooLarge.Limit), Code: cmds.ErrClient}
+		re.errStatus = http.StatusRequestEntityTooLarge
+	}
+
 	switch err {
 	case nil:
 		// no error
@@ -198,6 +207,13 @@ func (re *responseEmitter) closeWithError(err error) error {
 		setErrTrailer = false
 	})
 
+	// encoders that wrap the output in a document, like XML, finish it here
+	if c, ok := re.enc.(io.Closer); ok {
+		if cerr := c.Close(); cerr != nil {
+			log.Error("error closing encoder: ", cerr)
+		}
+	}
+
 	if setErrTrailer && err != nil {
 		re.w.Header().Set(StreamErrHeader, err.Error())
 	}
@@ -238,8 +254,16 @@ func (re *responseEmitter) sendErr(err *cmds.Error) {
 
 	// Set the status from the error code.
 	status := http.StatusInternalServerError
-	if err.Code == cmds.ErrClient {
+	switch err.Code {
+	case cmds.ErrClient:
 		status = http.StatusBadRequest
+	case cmds.ErrForbidden:
+		status = http.StatusForbidden
+	case cmds.ErrNotFound:
+		status = http.StatusNotFound
+	}
+	if re.errStatus != 0 {
+		status = re.errStatus
 	}
 	re.w.WriteHeader(status)
 
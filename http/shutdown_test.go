@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// TestShutdown checks that shutting down the http.Server serving a handler
+// lets running commands finish, and that closing it once the shutdown timed
+// out cancels them.
+func TestShutdown(t *testing.T) {
+	var (
+		started  = make(chan struct{}, 1)
+		finished = make(chan struct{})
+		canceled = make(chan struct{})
+	)
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"slow": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					started <- struct{}{}
+					time.Sleep(200 * time.Millisecond)
+					close(finished)
+					return cmds.EmitOnce(re, "done")
+				},
+			},
+			"stuck": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					started <- struct{}{}
+					<-req.Context.Done()
+					close(canceled)
+					return req.Context.Err()
+				},
+			},
+		},
+	}
+
+	serve := func() *httptest.Server {
+		return httptest.NewServer(NewHandler(testEnv{t: t}, root, originCfg(defaultOrigins)))
+	}
+
+	srv := serve()
+	defer srv.Close()
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Post(srv.URL+"/slow", "", nil)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		out, _ := io.ReadAll(res.Body)
+		body <- string(out)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("expected the command to finish before the shutdown")
+	}
+	if out := <-body; out != "\"done\"\n" {
+		t.Fatalf("expected the command output, got %q", out)
+	}
+
+	stuck := serve()
+	defer stuck.Close()
+	go func() {
+		res, err := http.Post(stuck.URL+"/stuck", "", nil)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-started
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := stuck.Config.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shutdown to time out, got %v", err)
+	}
+	stuck.Config.Close()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected closing the server to cancel the command")
+	}
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const (
+	eventStream = "text/event-stream"
+
+	// defaultSSEKeepAlive is the interval of the keep-alive comments sent
+	// when ServerConfig.SSEKeepAlive isn't set.
+	defaultSSEKeepAlive = 15 * time.Second
+)
+
+// acceptsEventStream reports whether the client asked for Server-Sent Events.
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(accept); err == nil && mt == eventStream {
+			return true
+		}
+	}
+	return false
+}
+
+// sseEmitter sends the values of a command as Server-Sent Events. Every value
+// is sent as a data event, encoded with the encoding of the request, and an
+// error the command fails with as an error event holding the error as JSON.
+// Comments are sent in between to keep the connection alive.
+type sseEmitter struct {
+	w   http.ResponseWriter
+	req *cmds.Request
+
+	l       sync.Mutex
+	buf     bytes.Buffer
+	enc     cmds.Encoder
+	started bool
+	closed  bool
+	done    chan struct{}
+}
+
+func newSSEEmitter(w http.ResponseWriter, req *cmds.Request, keepAlive time.Duration) (*sseEmitter, error) {
+	re := &sseEmitter{
+		w:    w,
+		req:  req,
+		done: make(chan struct{}),
+	}
+	_, enc, err := cmds.GetEncoder(req, &re.buf, cmds.JSON)
+	if err != nil {
+		return nil, err
+	}
+	re.enc = enc
+
+	if keepAlive <= 0 {
+		keepAlive = defaultSSEKeepAlive
+	}
+	go re.keepAlive(keepAlive)
+	return re, nil
+}
+
+func (re *sseEmitter) keepAlive(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			re.l.Lock()
+			if !re.closed {
+				re.start()
+				io.WriteString(re.w, ": keep-alive\n\n")
+				re.flush()
+			}
+			re.l.Unlock()
+		case <-re.done:
+			return
+		case <-re.req.Context.Done():
+			return
+		}
+	}
+}
+
+func (re *sseEmitter) Emit(value interface{}) error {
+	// if we got a channel, instead emit values received on there.
+	if ch, ok := value.(chan interface{}); ok {
+		value = (<-chan interface{})(ch)
+	}
+	if ch, isChan := value.(<-chan interface{}); isChan {
+		return cmds.EmitChan(re, ch)
+	}
+
+	re.l.Lock()
+	defer re.l.Unlock()
+
+	if re.closed {
+		return cmds.ErrClosedEmitter
+	}
+
+	// ignore those
+	if value == nil {
+		return nil
+	}
+
+	var isSingle bool
+	if single, ok := value.(cmds.Single); ok {
+		value = single.Value
+		isSingle = true
+	}
+
+	var err error
+	switch v := value.(type) {
+	case error:
+		return re.closeWithError(v)
+	case io.Reader:
+		// every line read is sent as an event of its own
+		br := bufio.NewReader(v)
+		for {
+			line, rerr := br.ReadString('\n')
+			if line != "" {
+				if err = re.send("", line); err != nil {
+					break
+				}
+			}
+			if rerr != nil {
+				if rerr != io.EOF {
+					err = rerr
+				}
+				break
+			}
+		}
+	default:
+		if err = re.enc.Encode(value); err == nil {
+			err = re.send("", re.buf.String())
+		}
+		re.buf.Reset()
+	}
+
+	if isSingle && err == nil {
+		err = re.closeWithError(nil)
+	}
+	return err
+}
+
+// send writes an event with data, split into data lines, and flushes it.
+func (re *sseEmitter) send(event, data string) error {
+	re.start()
+
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+		b.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(re.w, b.String())
+	re.flush()
+	return err
+}
+
+// start writes the headers of the response, once.
+func (re *sseEmitter) start() {
+	if re.started {
+		return
+	}
+	re.started = true
+
+	h := re.w.Header()
+	h.Set(contentTypeHeader, eventStream)
+	h.Set("Cache-Control", "no-cache")
+	re.w.WriteHeader(http.StatusOK)
+}
+
+func (re *sseEmitter) flush() {
+	if f, ok := re.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (re *sseEmitter) SetLength(l uint64) {}
+
+func (re *sseEmitter) Close() error {
+	return re.CloseWithError(nil)
+}
+
+func (re *sseEmitter) CloseWithError(err error) error {
+	re.l.Lock()
+	defer re.l.Unlock()
+
+	return re.closeWithError(err)
+}
+
+func (re *sseEmitter) closeWithError(err error) error {
+	if re.closed {
+		return cmds.ErrClosingClosedEmitter
+	}
+
+	// encoders that hold the values until they are closed send them now
+	if c, ok := re.enc.(io.Closer); ok {
+		if cerr := c.Close(); cerr != nil {
+			log.Error("error closing encoder: ", cerr)
+		} else if re.buf.Len() > 0 {
+			re.send("", re.buf.String())
+			re.buf.Reset()
+		}
+	}
+
+	if err != nil && err != io.EOF {
+		data, merr := json.Marshal(cmdsError(err))
+		if merr != nil {
+			return merr
+		}
+		re.send("error", string(data))
+	}
+	re.start()
+
+	re.closed = true
+	close(re.done)
+	return nil
+}
+
+// Flush the http connection
+func (re *sseEmitter) Flush() {
+	re.l.Lock()
+	defer re.l.Unlock()
+
+	re.start()
+	re.flush()
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestServerSentEvents(t *testing.T) {
+	canceled := make(chan struct{})
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"count": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					for i := 0; i < 3; i++ {
+						// leave time for keep-alive comments
+						time.Sleep(20 * time.Millisecond)
+						if err := re.Emit(map[string]int{"n": i}); err != nil {
+							return err
+						}
+					}
+					return errors.New("done counting")
+				},
+			},
+			"forever": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					for i := 0; ; i++ {
+						if err := re.Emit(i); err != nil {
+							return err
+						}
+						select {
+						case <-req.Context.Done():
+							close(canceled)
+							return req.Context.Err()
+						case <-time.After(10 * time.Millisecond):
+						}
+					}
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.AllowGet = true
+	cfg.SSEKeepAlive = 5 * time.Millisecond
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, cfg))
+	defer srv.Close()
+
+	get := func(ctx context.Context, path string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertStatus(t, res.StatusCode, http.StatusOK)
+		if ct := res.Header.Get(contentTypeHeader); ct != eventStream {
+			t.Fatalf("expected content type %s, got %s", eventStream, ct)
+		}
+		return res
+	}
+
+	res := get(context.Background(), "/count")
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events, comments []string
+	for _, frame := range strings.SplitAfter(string(body), "\n\n") {
+		switch {
+		case frame == "":
+		case strings.HasPrefix(frame, ":"):
+			comments = append(comments, frame)
+		default:
+			events = append(events, frame)
+		}
+	}
+	expected := []string{
+		"data: {\"n\":0}\n\n",
+		"data: {\"n\":1}\n\n",
+		"data: {\"n\":2}\n\n",
+		"event: error\ndata: {\"Message\":\"done counting\",\"Code\":0,\"Type\":\"error\"}\n\n",
+	}
+	if strings.Join(events, "") != strings.Join(expected, "") {
+		t.Errorf("expected events %q, got %q", expected, events)
+	}
+	if len(comments) == 0 || comments[0] != ": keep-alive\n\n" {
+		t.Errorf("expected keep-alive comments, got %q", comments)
+	}
+
+	// disconnecting cancels the command
+	ctx, cancel := context.WithCancel(context.Background())
+	res = get(ctx, "/forever")
+	r := bufio.NewReader(res.Body)
+	for i := 0; i < 2; {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			i++
+		}
+	}
+	cancel()
+	res.Body.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the command to be canceled when the client disconnected")
+	}
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// ClientVersionHeader is the header clients send their version in, see
+// ClientWithVersion and cmds.Command.MinVersion.
+const ClientVersionHeader = "X-Client-Version"
+
+// checkMinVersion returns an error if version, the version of the client, is
+// older than the MinVersion of a command on the path of req. Clients that
+// don't send their version aren't checked.
+func checkMinVersion(req *cmds.Request, version string) error {
+	if version == "" {
+		return nil
+	}
+
+	cmdPath, err := req.Root.Resolve(req.Path)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range cmdPath {
+		if cmd.MinVersion == "" {
+			continue
+		}
+		older, err := olderVersion(version, cmd.MinVersion)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "%s", err)
+		}
+		if older {
+			return cmds.Errorf(cmds.ErrClient, "command %q requires client version %s or later, got %s",
+				strings.Join(req.Path, " "), cmd.MinVersion, version)
+		}
+	}
+	return nil
+}
+
+// olderVersion reports whether version a is older than version b. Versions
+// are dot separated numbers, optionally prefixed with "v". Pre-release and
+// build suffixes, as in "1.2.0-rc1", are ignored.
+func olderVersion(a, b string) (bool, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return false, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var na, nb int
+		if i < len(va) {
+			na = va[i]
+		}
+		if i < len(vb) {
+			nb = vb[i]
+		}
+		if na != nb {
+			return na < nb, nil
+		}
+	}
+	return false, nil
+}
+
+func parseVersion(s string) ([]int, error) {
+	v := strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestMinVersion(t *testing.T) {
+	ok := func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return cmds.EmitOnce(re, "ok")
+	}
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"version": {Run: ok},
+			"pin": {
+				MinVersion: "0.5.0",
+				Subcommands: map[string]*cmds.Command{
+					"add": {Run: ok},
+				},
+			},
+		},
+	}
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, originCfg(defaultOrigins)))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		path, version string
+		status        int
+		body          string
+	}{
+		{"/pin/add", "", http.StatusOK, "\"ok\"\n"},
+		{"/pin/add", "0.5.0", http.StatusOK, "\"ok\"\n"},
+		{"/pin/add", "v0.10", http.StatusOK, "\"ok\"\n"},
+		{"/pin/add", "0.5.1-rc1", http.StatusOK, "\"ok\"\n"},
+		{"/pin/add", "0.4.9", http.StatusBadRequest, "command \"pin add\" requires client version 0.5.0 or later, got 0.4.9\n"},
+		{"/pin/add", "latest", http.StatusBadRequest, "invalid version \"latest\"\n"},
+		{"/version", "0.1.0", http.StatusOK, "\"ok\"\n"},
+	} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.version != "" {
+			req.Header.Set(ClientVersionHeader, tc.version)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.status || string(body) != tc.body {
+			t.Errorf("%s as %q: expected %d %q, got %d %q", tc.path, tc.version, tc.status, tc.body, res.StatusCode, body)
+		}
+	}
+
+	// the client sends its version
+	req, err := cmds.NewRequest(context.Background(), []string{"pin", "add"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq, err := NewClient(srv.URL, ClientWithVersion("0.4.9")).(*client).toHTTPRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := httpReq.Header.Get(ClientVersionHeader); v != "0.4.9" {
+		t.Errorf("expected the client to send its version, got %q", v)
+	}
+
+	// commands run locally aren't checked
+	re, res := cmds.NewChanResponsePair(req)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cmds.NewExecutor(root).Execute(req, re, nil)
+	}()
+	if v, err := res.Next(); err != nil || v != "ok" {
+		t.Fatalf("expected ok, got %v, %v", v, err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
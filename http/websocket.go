@@ -0,0 +1,265 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// The types of the messages exchanged over a WebSocket. Clients send run
+// messages, holding an invocation, and cancel messages. For every command run
+// the server sends a value message per value emitted, followed by an end
+// message, or an error message if the command failed.
+const (
+	wsRun    = "run"
+	wsCancel = "cancel"
+	wsValue  = "value"
+	wsEnd    = "end"
+	wsError  = "error"
+)
+
+// wsMessage is a JSON message sent over a WebSocket.
+type wsMessage struct {
+	Type string `json:"type"`
+
+	// set for run messages
+	invocation
+
+	// set for value and error messages
+	Value json.RawMessage `json:"value,omitempty"`
+	Error *cmds.Error     `json:"error,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	// the origin is checked like for all other requests before upgrading
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// serveWebSocket runs the commands the client sends over a WebSocket, one at
+// a time, and streams their output back. A command is canceled when the client
+// sends a cancel message or closes the connection.
+func (h *handler) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !h.allowRequest(w, r) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has responded already
+		log.Debug("websocket upgrade failed: ", err)
+		return
+	}
+	ws := &wsConn{conn: conn}
+	defer conn.Close()
+
+	// the limit on request bodies applies to every message
+	if h.cfg.MaxBodySize > 0 {
+		conn.SetReadLimit(h.cfg.MaxBodySize)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	msgs := make(chan wsMessage)
+	go func() {
+		defer close(msgs)
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				var syntaxErr *json.SyntaxError
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+					ws.sendError(cmds.Errorf(cmds.ErrClient, "invalid message: %s", err))
+					continue
+				}
+				// closing the connection cancels the running command
+				cancel()
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		cancelRun func()
+		done      chan struct{}
+	)
+	defer func() {
+		if done != nil {
+			<-done
+		}
+	}()
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			switch msg.Type {
+			case wsRun:
+				if done != nil {
+					ws.sendError(cmds.Errorf(cmds.ErrClient, "a command is running already"))
+					continue
+				}
+				req, err := newInvocationRequest(ctx, h.root, msg.invocation)
+				if err != nil {
+					ws.sendError(err)
+					continue
+				}
+				cancelRun, done = h.runWebSocket(ws, r, req)
+			case wsCancel:
+				if cancelRun != nil {
+					cancelRun()
+				}
+			default:
+				ws.sendError(cmds.Errorf(cmds.ErrClient, "unknown message type %q", msg.Type))
+			}
+		case <-done:
+			cancelRun, done = nil, nil
+		}
+	}
+}
+
+// runWebSocket starts running req, sent over the WebSocket of r, sending its
+// output to ws. The returned channel is closed once the command is done.
+func (h *handler) runWebSocket(ws *wsConn, r *http.Request, req *cmds.Request) (context.CancelFunc, chan struct{}) {
+	ctx, cancel := context.WithCancel(req.Context)
+	req.Context = ctx
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		re := &wsEmitter{ws: ws}
+		if err := h.runInvocation(r, req, re); err != nil {
+			re.CloseWithError(err)
+		}
+	}()
+	return cancel, done
+}
+
+// wsConn serializes the messages written to a WebSocket.
+type wsConn struct {
+	l    sync.Mutex
+	conn *websocket.Conn
+}
+
+func (ws *wsConn) send(msg wsMessage) error {
+	ws.l.Lock()
+	defer ws.l.Unlock()
+	return ws.conn.WriteJSON(msg)
+}
+
+func (ws *wsConn) sendError(err error) error {
+	return ws.send(wsMessage{Type: wsError, Error: cmdsError(err)})
+}
+
+// wsEmitter sends the values of a command as value messages over a
+// WebSocket.
+type wsEmitter struct {
+	ws *wsConn
+
+	l      sync.Mutex
+	closed bool
+}
+
+func (re *wsEmitter) Emit(value interface{}) error {
+	// if we got a channel, instead emit values received on there.
+	if ch, ok := value.(chan interface{}); ok {
+		value = (<-chan interface{})(ch)
+	}
+	if ch, isChan := value.(<-chan interface{}); isChan {
+		return cmds.EmitChan(re, ch)
+	}
+
+	re.l.Lock()
+	defer re.l.Unlock()
+
+	if re.closed {
+		return cmds.ErrClosedEmitter
+	}
+
+	// ignore those
+	if value == nil {
+		return nil
+	}
+
+	var isSingle bool
+	if single, ok := value.(cmds.Single); ok {
+		value = single.Value
+		isSingle = true
+	}
+
+	var err error
+	switch v := value.(type) {
+	case error:
+		return re.closeWithError(v)
+	case io.Reader:
+		// streams are sent in chunks of text
+		buf := make([]byte, 4096)
+		for {
+			n, rerr := v.Read(buf)
+			if n > 0 {
+				if err = re.sendValue(string(buf[:n])); err != nil {
+					break
+				}
+			}
+			if rerr != nil {
+				if rerr != io.EOF {
+					err = rerr
+				}
+				break
+			}
+		}
+	default:
+		err = re.sendValue(value)
+	}
+
+	if isSingle && err == nil {
+		err = re.closeWithError(nil)
+	}
+	return err
+}
+
+func (re *wsEmitter) sendValue(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return re.ws.send(wsMessage{Type: wsValue, Value: data})
+}
+
+func (re *wsEmitter) SetLength(l uint64) {}
+
+func (re *wsEmitter) Close() error {
+	return re.CloseWithError(nil)
+}
+
+func (re *wsEmitter) CloseWithError(err error) error {
+	re.l.Lock()
+	defer re.l.Unlock()
+
+	return re.closeWithError(err)
+}
+
+func (re *wsEmitter) closeWithError(err error) error {
+	if re.closed {
+		return cmds.ErrClosingClosedEmitter
+	}
+	re.closed = true
+
+	if err != nil && err != io.EOF {
+		return re.ws.sendError(err)
+	}
+	return re.ws.send(wsMessage{Type: wsEnd})
+}
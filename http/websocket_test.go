@@ -0,0 +1,130 @@
+package http
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestWebSocket(t *testing.T) {
+	canceled := make(chan struct{}, 2)
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"ticks": {
+				Options: []cmds.Option{
+					cmds.StringOption("prefix", "what to prefix the ticks with"),
+				},
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					prefix, _ := req.Options["prefix"].(string)
+					for i := 0; ; i++ {
+						if err := re.Emit(prefix + strings.Repeat("|", i)); err != nil {
+							return err
+						}
+						select {
+						case <-req.Context.Done():
+							canceled <- struct{}{}
+							return req.Context.Err()
+						case <-time.After(10 * time.Millisecond):
+						}
+					}
+				},
+			},
+			"version": {
+				Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+					return cmds.EmitOnce(re, "0.1.2")
+				},
+			},
+		},
+	}
+
+	cfg := originCfg(defaultOrigins)
+	cfg.APIPath = "/api/v0"
+	cfg.WebSocketPath = "/ws"
+	srv := httptest.NewServer(NewHandler(testEnv{t: t}, root, cfg))
+	defer srv.Close()
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v0/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	send := func(conn *websocket.Conn, msg string) {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(conn *websocket.Conn) string {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	conn := dial()
+	defer conn.Close()
+
+	send(conn, `{"type": "run", "path": ["ticks"], "options": {"prefix": "t"}}`)
+	for i, expected := range []string{
+		`{"type":"value","value":"t"}`,
+		`{"type":"value","value":"t|"}`,
+		`{"type":"value","value":"t||"}`,
+	} {
+		if msg := read(conn); msg != expected+"\n" {
+			t.Fatalf("message %d: expected %s, got %s", i, expected, msg)
+		}
+	}
+
+	send(conn, `{"type": "cancel"}`)
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the cancel message to cancel the command")
+	}
+	for {
+		msg := read(conn)
+		if strings.HasPrefix(msg, `{"type":"value"`) {
+			continue
+		}
+		expected := `{"type":"error","error":{"Message":"context canceled","Code":0,"Type":"error"}}`
+		if msg != expected+"\n" {
+			t.Fatalf("expected %s, got %s", expected, msg)
+		}
+		break
+	}
+
+	// the connection can run more commands
+	send(conn, `{"type": "run", "path": ["version"]}`)
+	for _, expected := range []string{
+		`{"type":"value","value":"0.1.2"}`,
+		`{"type":"end"}`,
+	} {
+		if msg := read(conn); msg != expected+"\n" {
+			t.Fatalf("expected %s, got %s", expected, msg)
+		}
+	}
+
+	send(conn, `{"type": "run", "path": ["nope"]}`)
+	expected := `{"type":"error","error":{"Message":"unknown command [\"nope\"]","Code":5,"Type":"error"}}`
+	if msg := read(conn); msg != expected+"\n" {
+		t.Fatalf("expected %s, got %s", expected, msg)
+	}
+
+	// closing the connection cancels the command too
+	other := dial()
+	send(other, `{"type": "run", "path": ["ticks"]}`)
+	read(other)
+	other.Close()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected closing the connection to cancel the command")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Types of Command options
@@ -18,10 +19,16 @@ const (
 	Float   = reflect.Float64
 	String  = reflect.String
 	Strings = reflect.Array
+
+	StringMap = reflect.Map
 )
 
 type OptMap map[string]interface{}
 
+// SecretMask is shown instead of the values of secret options in the help
+// text and the request log.
+const SecretMask = "****"
+
 // Option is used to specify a field that will be provided by a consumer
 type Option interface {
 	Name() string    // the main name of the option
@@ -36,11 +43,108 @@ type Option interface {
 	Parse(str string) (interface{}, error)
 }
 
+// OptionDetails is implemented by options with more to them than an Option,
+// like the options of this package. Use Details to get the details of any
+// Option.
+type OptionDetails interface {
+	TypeName() string       // the name of the type shown in the help text
+	RawDescription() string // the description without the default value
+
+	Hidden() bool                               // whether the option is hidden from the help text
+	EnvVar() string                             // the environment variable the option is read from
+	Aliases() []string                          // names that are accepted but listed separately
+	Deprecated() string                         // the deprecation message of the option
+	Group() string                              // the heading the option is listed under
+	Count() bool                                // whether the option counts its occurrences, see CountOption
+	Enum() []string                             // the values the option is restricted to
+	EnumFold() bool                             // whether the enum values match case-insensitively
+	Validate(value interface{}) error           // checks parsed values
+	Normalize(raw string) (string, error)       // rewrites values before they're parsed
+	CompleteFunc() func(prefix string) []string // offers values in shell completions
+	Required() bool                             // whether the option must be set
+	Secret() bool                               // whether the value is masked in prompts, the help text and logs
+}
+
+// Details returns the details of opt. An Option that doesn't implement
+// OptionDetails has none: it's visible, optional and takes any value.
+func Details(opt Option) OptionDetails {
+	if d, ok := opt.(OptionDetails); ok {
+		return d
+	}
+	return plainOption{opt}
+}
+
+// plainOption gives an Option without details the zero details.
+type plainOption struct {
+	Option
+}
+
+func (o plainOption) TypeName() string                           { return o.Type().String() }
+func (o plainOption) RawDescription() string                     { return o.Description() }
+func (o plainOption) Hidden() bool                               { return false }
+func (o plainOption) EnvVar() string                             { return "" }
+func (o plainOption) Aliases() []string                          { return nil }
+func (o plainOption) Deprecated() string                         { return "" }
+func (o plainOption) Group() string                              { return "" }
+func (o plainOption) Count() bool                                { return false }
+func (o plainOption) Enum() []string                             { return nil }
+func (o plainOption) EnumFold() bool                             { return false }
+func (o plainOption) Validate(value interface{}) error           { return nil }
+func (o plainOption) Normalize(raw string) (string, error)       { return raw, nil }
+func (o plainOption) CompleteFunc() func(prefix string) []string { return nil }
+func (o plainOption) Required() bool                             { return false }
+func (o plainOption) Secret() bool                               { return false }
+
+// DetailedOption is an Option with details, as returned by the option
+// constructors of this package. The With methods set a detail and return the
+// option, so they can be chained:
+//
+//	StringOption("format", "The output format.").WithEnum("json", "text").WithRequired(true)
+//
+// WithDefault returns an Option, so it has to come last.
+type DetailedOption interface {
+	Option
+	OptionDetails
+
+	WithHidden(bool) DetailedOption                                 // hides the option from the help text
+	WithEnvVar(string) DetailedOption                               // reads the option from the environment variable if not passed
+	WithAliases(...string) DetailedOption                           // adds names that are accepted but listed separately
+	WithDeprecated(string) DetailedOption                           // marks the option as deprecated with the given message
+	WithGroup(string) DetailedOption                                // lists the option under the given heading in the help text
+	WithEnum(...string) DetailedOption                              // restricts the values of the option to the given set
+	WithEnumFold(bool) DetailedOption                               // matches the enum values case-insensitively
+	WithValidator(func(value interface{}) error) DetailedOption     // checks parsed values
+	WithNormalizer(func(raw string) (string, error)) DetailedOption // rewrites values before they're parsed
+	WithCompleteFunc(func(prefix string) []string) DetailedOption   // offers values in shell completions
+	WithRequired(bool) DetailedOption                               // requires the option to be set
+	WithSecret(bool) DetailedOption                                 // masks the value of the option in prompts, the help text and logs
+}
+
 type option struct {
 	names       []string
 	kind        reflect.Kind
 	description string
 	defaultVal  interface{}
+	hidden      bool
+	envVar      string
+	aliases     []string
+	deprecated  string
+	group       string
+	count       bool
+	enum        []string
+	enumFold    bool
+	validator   func(interface{}) error
+	normalizer  func(string) (string, error)
+	complete    func(string) []string
+	required    bool
+	secret      bool
+	duration    bool
+}
+
+// isDuration reports whether opt was made with DurationOption.
+func isDuration(opt Option) bool {
+	o, ok := opt.(*option)
+	return ok && o.duration
 }
 
 func (o *option) Name() string {
@@ -55,6 +159,13 @@ func (o *option) Type() reflect.Kind {
 	return o.kind
 }
 
+func (o *option) TypeName() string {
+	if o.duration {
+		return "duration"
+	}
+	return o.kind.String()
+}
+
 func (o *option) Description() string {
 	if len(o.description) == 0 {
 		return ""
@@ -63,16 +174,33 @@ func (o *option) Description() string {
 		o.description += "."
 	}
 	if o.defaultVal != nil {
+		var def interface{} = o.defaultVal
+		if o.secret {
+			def = SecretMask
+		}
 		if strings.Contains(o.description, "<<default>>") {
 			return strings.Replace(o.description, "<<default>>",
-				fmt.Sprintf("Default: %v.", o.defaultVal), -1)
+				fmt.Sprintf("Default: %v.", def), -1)
 		} else {
-			return fmt.Sprintf("%s Default: %v.", o.description, o.defaultVal)
+			return fmt.Sprintf("%s Default: %v.", o.description, def)
 		}
 	}
 	return o.description
 }
 
+// RawDescription returns the description without the default value, for
+// places like the help text that list the default separately.
+func (o *option) RawDescription() string {
+	if len(o.description) == 0 {
+		return ""
+	}
+	desc := o.description
+	if !strings.HasSuffix(desc, ".") {
+		desc += "."
+	}
+	return strings.NewReplacer(" (<<default>>)", "", "(<<default>>)", "", " <<default>>", "", "<<default>>", "").Replace(desc)
+}
+
 type converter func(string) (interface{}, error)
 
 var converters = map[reflect.Kind]converter{
@@ -124,16 +252,55 @@ var converters = map[reflect.Kind]converter{
 }
 
 func (o *option) Parse(v string) (interface{}, error) {
+	v, err := o.Normalize(v)
+	if err != nil {
+		return nil, err
+	}
+	val, err := o.parse(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Validate(val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (o *option) parse(v string) (interface{}, error) {
+	if o.duration {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("option %q takes a duration such as \"30s\", but was passed %q", o.Name(), v)
+		}
+		return d, nil
+	}
+
+	if o.Type() == StringMap {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("option %q takes key=value arguments, but was passed %q", o.Name(), v)
+		}
+		return map[string]string{key: value}, nil
+	}
+
 	conv, ok := converters[o.Type()]
 	if !ok {
 		return nil, fmt.Errorf("option %q takes %s arguments, but was passed %q", o.Name(), o.Type(), v)
 	}
 
+	if o.Type() == String {
+		return checkEnum(o, v)
+	}
+
 	return conv(v)
 }
 
 // constructor helper functions
 func NewOption(kind reflect.Kind, names ...string) Option {
+	return newOption(kind, names...)
+}
+
+func newOption(kind reflect.Kind, names ...string) *option {
 	var desc string
 
 	if len(names) >= 2 {
@@ -153,6 +320,12 @@ func (o *option) WithDefault(v interface{}) Option {
 		panic(fmt.Errorf("cannot use nil as a default"))
 	}
 
+	// durations are strings on the wire, but time.Durations in the OptMap
+	if _, ok := v.(time.Duration); ok && isDuration(o) {
+		o.defaultVal = v
+		return o
+	}
+
 	// if type of value does not match the option type
 	if vKind, oKind := reflect.TypeOf(v).Kind(), o.Type(); vKind != oKind {
 		// if the reason they do not match is not because of Slice vs Array equivalence
@@ -169,38 +342,207 @@ func (o *option) Default() interface{} {
 	return o.defaultVal
 }
 
+func (o *option) WithHidden(hidden bool) DetailedOption {
+	o.hidden = hidden
+	return o
+}
+
+func (o *option) Hidden() bool {
+	return o.hidden
+}
+
+func (o *option) WithEnvVar(name string) DetailedOption {
+	o.envVar = name
+	return o
+}
+
+func (o *option) EnvVar() string {
+	return o.envVar
+}
+
+func (o *option) WithAliases(aliases ...string) DetailedOption {
+	o.aliases = append(o.aliases, aliases...)
+	return o
+}
+
+func (o *option) Aliases() []string {
+	return o.aliases
+}
+
+func (o *option) WithDeprecated(msg string) DetailedOption {
+	o.deprecated = msg
+	return o
+}
+
+func (o *option) Deprecated() string {
+	return o.deprecated
+}
+
+func (o *option) WithGroup(group string) DetailedOption {
+	o.group = group
+	return o
+}
+
+func (o *option) Group() string {
+	return o.group
+}
+
+func (o *option) Count() bool {
+	return o.count
+}
+
+func (o *option) WithEnum(values ...string) DetailedOption {
+	o.enum = values
+	return o
+}
+
+func (o *option) Enum() []string {
+	return o.enum
+}
+
+func (o *option) WithEnumFold(fold bool) DetailedOption {
+	o.enumFold = fold
+	return o
+}
+
+func (o *option) EnumFold() bool {
+	return o.enumFold
+}
+
+func (o *option) WithValidator(fn func(value interface{}) error) DetailedOption {
+	o.validator = fn
+	return o
+}
+
+func (o *option) WithRequired(required bool) DetailedOption {
+	o.required = required
+	return o
+}
+
+func (o *option) Required() bool {
+	return o.required
+}
+
+func (o *option) WithSecret(secret bool) DetailedOption {
+	o.secret = secret
+	return o
+}
+
+func (o *option) Secret() bool {
+	return o.secret
+}
+
+func (o *option) WithNormalizer(fn func(raw string) (string, error)) DetailedOption {
+	o.normalizer = fn
+	return o
+}
+
+// Normalize runs the normalizer of the option, if any, on a value before it's
+// converted to the type of the option.
+func (o *option) Normalize(raw string) (string, error) {
+	if o.normalizer == nil {
+		return raw, nil
+	}
+	v, err := o.normalizer(raw)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %w", o.Name(), err)
+	}
+	return v, nil
+}
+
+func (o *option) WithCompleteFunc(fn func(prefix string) []string) DetailedOption {
+	o.complete = fn
+	return o
+}
+
+func (o *option) CompleteFunc() func(prefix string) []string {
+	return o.complete
+}
+
+// Validate runs the validator of the option, if any, on a value that has
+// already been converted to the type of the option.
+func (o *option) Validate(value interface{}) error {
+	if o.validator == nil {
+		return nil
+	}
+	if err := o.validator(value); err != nil {
+		return fmt.Errorf("--%s: %w", o.Name(), err)
+	}
+	return nil
+}
+
+// checkEnum returns the value of the enum of opt matching v. If opt has no
+// enum, v is returned as is.
+func checkEnum(opt *option, v string) (string, error) {
+	enum := opt.Enum()
+	if len(enum) == 0 {
+		return v, nil
+	}
+	for _, e := range enum {
+		if e == v || (opt.EnumFold() && strings.EqualFold(e, v)) {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("invalid value %q for --%s: must be one of %s", v, opt.Name(), strings.Join(enum, ", "))
+}
+
 // TODO handle description separately. this will take care of the panic case in
 // NewOption
 
 // For all func {Type}Option(...string) functions, the last variadic argument
 // is treated as the description field.
 
-func BoolOption(names ...string) Option {
-	return NewOption(Bool, names...)
+func BoolOption(names ...string) DetailedOption {
+	return newOption(Bool, names...)
 }
-func IntOption(names ...string) Option {
-	return NewOption(Int, names...)
+func IntOption(names ...string) DetailedOption {
+	return newOption(Int, names...)
 }
-func UintOption(names ...string) Option {
-	return NewOption(Uint, names...)
+func UintOption(names ...string) DetailedOption {
+	return newOption(Uint, names...)
 }
-func Int64Option(names ...string) Option {
-	return NewOption(Int64, names...)
+func Int64Option(names ...string) DetailedOption {
+	return newOption(Int64, names...)
 }
-func Uint64Option(names ...string) Option {
-	return NewOption(Uint64, names...)
+func Uint64Option(names ...string) DetailedOption {
+	return newOption(Uint64, names...)
 }
-func FloatOption(names ...string) Option {
-	return NewOption(Float, names...)
+func FloatOption(names ...string) DetailedOption {
+	return newOption(Float, names...)
 }
-func StringOption(names ...string) Option {
-	return NewOption(String, names...)
+func StringOption(names ...string) DetailedOption {
+	return newOption(String, names...)
+}
+
+// StringMapOption is a command option that collects key=value pairs into a
+// map[string]string. It can be passed several times, later values overwrite
+// earlier ones with the same key.
+func StringMapOption(names ...string) DetailedOption {
+	return newOption(StringMap, names...)
+}
+
+// DurationOption is a command option taking a duration such as "30s" or
+// "1h30m", parsed with time.ParseDuration into a time.Duration. Its Type is
+// String, durations are passed as strings on the command line and over HTTP.
+func DurationOption(names ...string) DetailedOption {
+	opt := newOption(String, names...)
+	opt.duration = true
+	return opt
+}
+
+// CountOption is an int option counting how often it was passed, so that
+// `-vvv` or `-v -v -v` both result in 3. Passing a number, as in `-v=2`,
+// adds that number to the count.
+func CountOption(names ...string) DetailedOption {
+	opt := newOption(Int, names...)
+	opt.count = true
+	return opt
 }
 
 // StringsOption is a command option that can handle a slice of strings
-func StringsOption(names ...string) Option {
+func StringsOption(names ...string) DetailedOption {
 	return &stringsOption{
-		Option:    NewOption(Strings, names...),
+		option:    newOption(Strings, names...),
 		delimiter: "",
 	}
 }
@@ -211,35 +553,166 @@ func StringsOption(names ...string) Option {
 // even `command --option=val1,val2 --option=val3,val4`.
 //
 // A delimiter of "" is invalid
-func DelimitedStringsOption(delimiter string, names ...string) Option {
+func DelimitedStringsOption(delimiter string, names ...string) DetailedOption {
 	if delimiter == "" {
 		panic("cannot create a DelimitedStringsOption with no delimiter")
 	}
 	return &stringsOption{
-		Option:    NewOption(Strings, names...),
+		option:    newOption(Strings, names...),
 		delimiter: delimiter,
 	}
 }
 
+// ListOption is a StringsOption splitting its values at commas, so that
+// `--tags a,b,c` results in ["a" "b" "c"]. Whitespace around the elements is
+// trimmed and empty elements are dropped. An escaped comma, as in `a\,b`,
+// stands for itself.
+func ListOption(names ...string) DetailedOption {
+	return DelimitedListOption(",", names...)
+}
+
+// DelimitedListOption is like ListOption, but splits at delimiter rather than
+// at commas.
+//
+// A delimiter of "" is invalid
+func DelimitedListOption(delimiter string, names ...string) DetailedOption {
+	if delimiter == "" {
+		panic("cannot create a DelimitedListOption with no delimiter")
+	}
+	return &stringsOption{
+		option:    newOption(Strings, names...),
+		delimiter: delimiter,
+		list:      true,
+	}
+}
+
 type stringsOption struct {
-	Option
+	*option
 	delimiter string
+
+	// list enables trimming, dropping empty elements and escaping the
+	// delimiter
+	list bool
 }
 
 func (s *stringsOption) WithDefault(v interface{}) Option {
 	if v == nil {
-		return s.Option.WithDefault(v)
+		return s.option.WithDefault(v)
 	}
 
 	defVal := v.([]string)
-	s.Option = s.Option.WithDefault(defVal)
+	s.option.WithDefault(defVal)
+	return s
+}
+
+func (s *stringsOption) WithHidden(hidden bool) DetailedOption {
+	s.option.WithHidden(hidden)
+	return s
+}
+
+func (s *stringsOption) WithEnvVar(name string) DetailedOption {
+	s.option.WithEnvVar(name)
+	return s
+}
+
+func (s *stringsOption) WithAliases(aliases ...string) DetailedOption {
+	s.option.WithAliases(aliases...)
+	return s
+}
+
+func (s *stringsOption) WithDeprecated(msg string) DetailedOption {
+	s.option.WithDeprecated(msg)
+	return s
+}
+
+func (s *stringsOption) WithGroup(group string) DetailedOption {
+	s.option.WithGroup(group)
+	return s
+}
+
+func (s *stringsOption) WithEnum(values ...string) DetailedOption {
+	s.option.WithEnum(values...)
+	return s
+}
+
+func (s *stringsOption) WithEnumFold(fold bool) DetailedOption {
+	s.option.WithEnumFold(fold)
+	return s
+}
+
+func (s *stringsOption) WithValidator(fn func(value interface{}) error) DetailedOption {
+	s.option.WithValidator(fn)
+	return s
+}
+
+func (s *stringsOption) WithNormalizer(fn func(raw string) (string, error)) DetailedOption {
+	s.option.WithNormalizer(fn)
+	return s
+}
+
+func (s *stringsOption) WithCompleteFunc(fn func(prefix string) []string) DetailedOption {
+	s.option.WithCompleteFunc(fn)
+	return s
+}
+
+func (s *stringsOption) WithRequired(required bool) DetailedOption {
+	s.option.WithRequired(required)
+	return s
+}
+
+func (s *stringsOption) WithSecret(secret bool) DetailedOption {
+	s.option.WithSecret(secret)
 	return s
 }
 
 func (s *stringsOption) Parse(v string) (interface{}, error) {
-	if s.delimiter == "" {
-		return []string{v}, nil
+	values := []string{v}
+	if s.list {
+		values = splitList(v, s.delimiter)
+	} else if s.delimiter != "" {
+		values = strings.Split(v, s.delimiter)
 	}
 
-	return strings.Split(v, s.delimiter), nil
+	for i, v := range values {
+		v, err := s.Normalize(v)
+		if err != nil {
+			return nil, err
+		}
+		if values[i], err = checkEnum(s.option, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Validate(values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// splitList splits v at delimiter, except where it's escaped with a
+// backslash, trimming the elements and dropping empty ones.
+func splitList(v, delimiter string) []string {
+	values := []string{}
+	var elem strings.Builder
+	add := func() {
+		if e := strings.TrimSpace(elem.String()); e != "" {
+			values = append(values, e)
+		}
+		elem.Reset()
+	}
+
+	for i := 0; i < len(v); {
+		switch {
+		case strings.HasPrefix(v[i:], `\`+delimiter):
+			elem.WriteString(delimiter)
+			i += 1 + len(delimiter)
+		case strings.HasPrefix(v[i:], delimiter):
+			add()
+			i += len(delimiter)
+		default:
+			elem.WriteByte(v[i])
+			i++
+		}
+	}
+	add()
+	return values
 }
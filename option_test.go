@@ -1,10 +1,16 @@
 package cmds
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLackOfDescriptionOfOptionDoesNotPanic(t *testing.T) {
@@ -96,3 +102,294 @@ func TestDescription(t *testing.T) {
 		}
 	}
 }
+
+func TestRawDescription(t *testing.T) {
+	for _, tc := range []struct {
+		opt  Option
+		desc string
+	}{
+		{opt: StringOption("str", "some random option"), desc: "some random option."},
+		{opt: StringOption("str", "some random option").WithDefault("random=4"), desc: "some random option."},
+		{opt: StringOption("str", "some random option (<<default>>)").WithDefault("random=4"), desc: "some random option."},
+		{opt: StringOption("str", ""), desc: ""},
+	} {
+		if desc := Details(tc.opt).RawDescription(); desc != tc.desc {
+			t.Errorf("expected %q but got %q", tc.desc, desc)
+		}
+	}
+}
+
+func TestHiddenOption(t *testing.T) {
+	if BoolOption("visible").Hidden() {
+		t.Fatal("options should not be hidden by default")
+	}
+
+	opt := StringsOption("secret", "An internal option.").WithHidden(true)
+	if !opt.Hidden() {
+		t.Fatal("expected option to be hidden")
+	}
+
+	// WithHidden must not drop the strings option wrapper
+	v, err := opt.Parse("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.([]string); !ok {
+		t.Fatalf("expected []string, got %T", v)
+	}
+}
+
+func TestEnumOption(t *testing.T) {
+	format := StringOption("format", "Output format.").WithEnum("json", "text", "xml")
+
+	v, err := format.Parse("json")
+	if err != nil || v != "json" {
+		t.Fatalf("expected json, got %v (%v)", v, err)
+	}
+
+	_, err = format.Parse("yaml")
+	expected := `invalid value "yaml" for --format: must be one of json, text, xml`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+
+	if _, err := format.Parse("JSON"); err == nil {
+		t.Fatal("expected enum matching to be case-sensitive by default")
+	}
+	v, err = format.WithEnumFold(true).Parse("JSON")
+	if err != nil || v != "json" {
+		t.Fatalf("expected JSON to fold to json, got %v (%v)", v, err)
+	}
+
+	formats := DelimitedStringsOption(",", "formats", "Output formats.").WithEnum("json", "text")
+	v, err = formats.Parse("json,text")
+	if err != nil || len(v.([]string)) != 2 {
+		t.Fatalf("expected both values to be accepted, got %v (%v)", v, err)
+	}
+	if _, err := formats.Parse("json,xml"); err == nil {
+		t.Fatal("expected an error for a value outside of the enum")
+	}
+}
+
+func TestOptionValidator(t *testing.T) {
+	port := IntOption("port", "The port to listen on.").WithValidator(func(v interface{}) error {
+		if p := v.(int); p < 1 || p > 65535 {
+			return fmt.Errorf("port %d out of range 1-65535", p)
+		}
+		return nil
+	})
+
+	v, err := port.Parse("8080")
+	if err != nil || v != 8080 {
+		t.Fatalf("expected 8080, got %v (%v)", v, err)
+	}
+
+	_, err = port.Parse("70000")
+	expected := "--port: port 70000 out of range 1-65535"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+
+	// the validator gets the converted value of strings options too
+	var got interface{}
+	peers := StringsOption("peer", "Peers.").WithValidator(func(v interface{}) error {
+		got = v
+		return nil
+	})
+	if _, err := peers.Parse("a"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := got.([]string); !ok || len(s) != 1 || s[0] != "a" {
+		t.Fatalf("expected validator to get []string{\"a\"}, got %#v", got)
+	}
+}
+
+func TestDurationOption(t *testing.T) {
+	opt := DurationOption("timeout", "How long to wait.")
+	if opt.TypeName() != "duration" {
+		t.Fatalf("expected type name duration, got %q", opt.TypeName())
+	}
+	// durations are passed as strings
+	if opt.Type() != String {
+		t.Fatalf("expected type string, got %s", opt.Type())
+	}
+
+	v, err := opt.Parse("1m30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 90*time.Second {
+		t.Fatalf("expected 1m30s, got %v", v)
+	}
+	if v, ok := (OptMap{"timeout": v}).GetDuration("timeout"); !ok || v != 90*time.Second {
+		t.Fatalf("expected GetDuration to return 1m30s, got %v", v)
+	}
+
+	_, err = opt.Parse("soon")
+	if err == nil || !strings.Contains(err.Error(), `"timeout"`) {
+		t.Fatalf("expected an error naming the option, got %v", err)
+	}
+
+	// defaults are durations as well
+	def := DurationOption("timeout", "How long to wait.").WithDefault(time.Second)
+	if def.Default() != time.Second {
+		t.Fatalf("unexpected default %v", def.Default())
+	}
+
+	// string values, as sent over HTTP, are parsed as well
+	cmd := &Command{Options: []Option{opt}}
+	req, err := NewRequest(context.Background(), nil, OptMap{"timeout": "2s"}, nil, nil, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Options["timeout"] != 2*time.Second {
+		t.Fatalf("expected the timeout to be parsed, got %#v", req.Options["timeout"])
+	}
+	req, err = NewRequest(context.Background(), nil, OptMap{"timeout": 3 * time.Second}, nil, nil, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Options["timeout"] != 3*time.Second {
+		t.Fatalf("expected the timeout to be kept, got %#v", req.Options["timeout"])
+	}
+	if _, err := NewRequest(context.Background(), nil, OptMap{"timeout": "soon"}, nil, nil, cmd); err == nil {
+		t.Fatal("expected an error for an invalid duration")
+	}
+}
+
+func TestOptionNormalizer(t *testing.T) {
+	trim := func(raw string) (string, error) {
+		return strings.ToLower(strings.TrimSpace(raw)), nil
+	}
+
+	format := StringOption("format", "The format.").WithEnum("json", "xml").WithNormalizer(trim)
+	if v, err := format.Parse("  JSON "); err != nil || v != "json" {
+		t.Errorf("expected json, got %v (%v)", v, err)
+	}
+	// every item of a delimited strings option is normalized
+	tags := DelimitedStringsOption(",", "tag", "Tags.").WithNormalizer(trim)
+	if v, err := tags.Parse("a, B ,c"); err != nil || !reflect.DeepEqual(v, []string{"a", "b", "c"}) {
+		t.Errorf("expected [a b c], got %v (%v)", v, err)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	expandHome := func(raw string) (string, error) {
+		if !strings.HasPrefix(raw, "~") {
+			return raw, nil
+		}
+		if raw != "~" && !strings.HasPrefix(raw, "~/") {
+			return "", errors.New("~user paths are not supported")
+		}
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, raw[1:]), nil
+	}
+	path := StringOption("config", "The config file.").WithNormalizer(expandHome)
+	for raw, expected := range map[string]string{
+		"~":             home,
+		"~/config.json": filepath.Join(home, "config.json"),
+		"/etc/config":   "/etc/config",
+		"a~/b":          "a~/b",
+	} {
+		if v, err := path.Parse(raw); err != nil || v != expected {
+			t.Errorf("%s: expected %s, got %v (%v)", raw, expected, v, err)
+		}
+	}
+
+	_, err := path.Parse("~bob/config")
+	if err == nil || err.Error() != "--config: ~user paths are not supported" {
+		t.Errorf("expected the error to name the option, got %v", err)
+	}
+
+	// values of requests built from strings are normalized too
+	root := &Command{Options: []Option{format}}
+	req, err := NewRequest(context.Background(), nil, OptMap{"format": " XML"}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Options["format"] != "xml" {
+		t.Errorf("expected xml, got %v", req.Options["format"])
+	}
+}
+
+func TestListOption(t *testing.T) {
+	tags := ListOption("tags", "Tags.")
+	for raw, expected := range map[string][]string{
+		"a,b,c":          {"a", "b", "c"},
+		"a":              {"a"},
+		" a , b ,\tc ":   {"a", "b", "c"},
+		"a,,b,":          {"a", "b"},
+		" , ":            {},
+		`a\,b,c`:         {"a,b", "c"},
+		`x\,,y`:          {"x,", "y"},
+		`a\b,c`:          {`a\b`, "c"},
+		"path a, path b": {"path a", "path b"},
+	} {
+		v, err := tags.Parse(raw)
+		if err != nil {
+			t.Errorf("%q: %s", raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, expected) {
+			t.Errorf("%q: expected %q, got %q", raw, expected, v)
+		}
+	}
+
+	paths := DelimitedListOption(":", "path", "Search path.")
+	if v, err := paths.Parse(`/bin: /usr/bin :c\:/tools`); err != nil || !reflect.DeepEqual(v, []string{"/bin", "/usr/bin", "c:/tools"}) {
+		t.Errorf("expected [/bin /usr/bin c:/tools], got %q (%v)", v, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an empty delimiter")
+		}
+	}()
+	DelimitedListOption("", "path", "Search path.")
+}
+
+// plainOpt implements only Option, like options of other packages might.
+type plainOpt struct {
+	name string
+}
+
+func (o plainOpt) Name() string                        { return o.name }
+func (o plainOpt) Names() []string                     { return []string{o.name} }
+func (o plainOpt) Type() reflect.Kind                  { return String }
+func (o plainOpt) Description() string                 { return "A plain option." }
+func (o plainOpt) WithDefault(interface{}) Option      { return o }
+func (o plainOpt) Default() interface{}                { return nil }
+func (o plainOpt) Parse(s string) (interface{}, error) { return s, nil }
+
+func TestDetails(t *testing.T) {
+	d := Details(plainOpt{"color"})
+	if d.TypeName() != "string" || d.RawDescription() != "A plain option." {
+		t.Errorf("unexpected type name %q or description %q", d.TypeName(), d.RawDescription())
+	}
+	if d.Hidden() || d.Required() || d.Secret() || d.Count() || len(d.Enum()) > 0 || len(d.Aliases()) > 0 {
+		t.Error("expected a plain option to have no details")
+	}
+	if v, err := d.Normalize(" x "); err != nil || v != " x " {
+		t.Errorf("expected the value to be left alone, got %q (%v)", v, err)
+	}
+
+	opt := StringOption("format", "f", "The format.").WithEnum("json", "text")
+	if enum := Details(opt).Enum(); !reflect.DeepEqual(enum, []string{"json", "text"}) {
+		t.Errorf("expected the enum of the option, got %v", enum)
+	}
+
+	root := &Command{Options: []Option{plainOpt{"color"}}}
+	req, err := NewRequest(context.Background(), nil, OptMap{"color": "red"}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.FillDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if req.Options["color"] != "red" {
+		t.Errorf("expected color red, got %v", req.Options["color"])
+	}
+}
@@ -0,0 +1,187 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ErrOptionNotSet is returned by the OptMap accessors when the option has no
+// value.
+var ErrOptionNotSet = errors.New("cmds: option not set")
+
+// GetString returns the value of the option called name as a string. The
+// second return value is false if the option is not set or not a string.
+func (m OptMap) GetString(name string) (string, bool) {
+	v, err := m.GetStringE(name)
+	return v, err == nil
+}
+
+// GetStringE is like GetString, but returns an error describing why the value
+// could not be returned.
+func (m OptMap) GetStringE(name string) (string, error) {
+	v, ok := m[name]
+	if !ok {
+		return "", ErrOptionNotSet
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.String {
+		return "", optionTypeError(name, v, "string")
+	}
+	return rv.String(), nil
+}
+
+// GetInt returns the value of the option called name as an int, parsing it if
+// it was given as a string. The second return value is false if the option is
+// not set or can't be converted.
+func (m OptMap) GetInt(name string) (int, bool) {
+	v, err := m.GetIntE(name)
+	return v, err == nil
+}
+
+// GetIntE is like GetInt, but returns an error describing why the value could
+// not be returned.
+func (m OptMap) GetIntE(name string) (int, error) {
+	v, ok := m[name]
+	if !ok {
+		return 0, ErrOptionNotSet
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i < math.MinInt || i > math.MaxInt {
+			return 0, fmt.Errorf("option %q: value %d overflows int", name, i)
+		}
+		return int(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > math.MaxInt {
+			return 0, fmt.Errorf("option %q: value %d overflows int", name, u)
+		}
+		return int(u), nil
+	case reflect.String:
+		i, err := strconv.ParseInt(rv.String(), 0, 0)
+		if err != nil {
+			return 0, fmt.Errorf("option %q: %w", name, err)
+		}
+		return int(i), nil
+	default:
+		return 0, optionTypeError(name, v, "int")
+	}
+}
+
+// GetBool returns the value of the option called name as a bool, parsing it
+// if it was given as a string. The second return value is false if the option
+// is not set or can't be converted.
+func (m OptMap) GetBool(name string) (bool, bool) {
+	v, err := m.GetBoolE(name)
+	return v, err == nil
+}
+
+// GetBoolE is like GetBool, but returns an error describing why the value
+// could not be returned.
+func (m OptMap) GetBoolE(name string) (bool, error) {
+	v, ok := m[name]
+	if !ok {
+		return false, ErrOptionNotSet
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		b, err := strconv.ParseBool(rv.String())
+		if err != nil {
+			return false, fmt.Errorf("option %q: %w", name, err)
+		}
+		return b, nil
+	default:
+		return false, optionTypeError(name, v, "bool")
+	}
+}
+
+// GetDuration returns the value of the option called name as a
+// time.Duration, parsing it if it was given as a string such as "10s". The
+// second return value is false if the option is not set or can't be
+// converted.
+func (m OptMap) GetDuration(name string) (time.Duration, bool) {
+	v, err := m.GetDurationE(name)
+	return v, err == nil
+}
+
+// GetDurationE is like GetDuration, but returns an error describing why the
+// value could not be returned.
+func (m OptMap) GetDurationE(name string) (time.Duration, error) {
+	v, ok := m[name]
+	if !ok {
+		return 0, ErrOptionNotSet
+	}
+
+	switch v := v.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("option %q: %w", name, err)
+		}
+		return d, nil
+	default:
+		return 0, optionTypeError(name, v, "duration")
+	}
+}
+
+// GetStringSlice returns the value of the option called name as a []string,
+// as set by a StringsOption, holding the values of all occurrences of the
+// option in order. The second return value is false if the option is not set
+// or not a slice.
+func (m OptMap) GetStringSlice(name string) ([]string, bool) {
+	v, err := m.GetStringSliceE(name)
+	return v, err == nil
+}
+
+// GetStringSliceE is like GetStringSlice, but returns an error describing why
+// the value could not be returned.
+func (m OptMap) GetStringSliceE(name string) ([]string, error) {
+	v, ok := m[name]
+	if !ok {
+		return nil, ErrOptionNotSet
+	}
+	ss, ok := v.([]string)
+	if !ok {
+		return nil, optionTypeError(name, v, "[]string")
+	}
+	return ss, nil
+}
+
+// GetStringMap returns the value of the option called name as a
+// map[string]string, as set by a StringMapOption. The second return value is
+// false if the option is not set or not a map.
+func (m OptMap) GetStringMap(name string) (map[string]string, bool) {
+	v, err := m.GetStringMapE(name)
+	return v, err == nil
+}
+
+// GetStringMapE is like GetStringMap, but returns an error describing why the
+// value could not be returned.
+func (m OptMap) GetStringMapE(name string) (map[string]string, error) {
+	v, ok := m[name]
+	if !ok {
+		return nil, ErrOptionNotSet
+	}
+	sm, ok := v.(map[string]string)
+	if !ok {
+		return nil, optionTypeError(name, v, "map[string]string")
+	}
+	return sm, nil
+}
+
+func optionTypeError(name string, v interface{}, want string) error {
+	return fmt.Errorf("option %q: cannot convert %T to %s", name, v, want)
+}
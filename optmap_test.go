@@ -0,0 +1,138 @@
+package cmds
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptMapAccessors(t *testing.T) {
+	opts := OptMap{
+		"name":      "bob",
+		"encoding":  EncodingType("json"),
+		"count":     3,
+		"count64":   int64(5),
+		"countStr":  "0x10",
+		"quiet":     true,
+		"quietStr":  "false",
+		"timeout":   10 * time.Second,
+		"timeoutSt": "1m30s",
+		"peers":     []string{"a", "b"},
+		"bad":       "nope",
+	}
+
+	t.Run("string", func(t *testing.T) {
+		if v, ok := opts.GetString("name"); !ok || v != "bob" {
+			t.Errorf("expected bob, got %q %v", v, ok)
+		}
+		if v, ok := opts.GetString("encoding"); !ok || v != "json" {
+			t.Errorf("expected json, got %q %v", v, ok)
+		}
+		if _, ok := opts.GetString("missing"); ok {
+			t.Error("expected missing option to not be found")
+		}
+		if _, err := opts.GetStringE("missing"); !errors.Is(err, ErrOptionNotSet) {
+			t.Errorf("expected ErrOptionNotSet, got %v", err)
+		}
+		if _, err := opts.GetStringE("peers"); err == nil {
+			t.Error("expected an error for a []string value")
+		}
+	})
+
+	t.Run("int", func(t *testing.T) {
+		for name, want := range map[string]int{"count": 3, "count64": 5, "countStr": 16} {
+			if v, ok := opts.GetInt(name); !ok || v != want {
+				t.Errorf("%s: expected %d, got %d %v", name, want, v, ok)
+			}
+		}
+		if _, ok := opts.GetInt("missing"); ok {
+			t.Error("expected missing option to not be found")
+		}
+		if _, err := opts.GetIntE("missing"); !errors.Is(err, ErrOptionNotSet) {
+			t.Errorf("expected ErrOptionNotSet, got %v", err)
+		}
+		if _, err := opts.GetIntE("bad"); err == nil {
+			t.Error("expected an error for a non-numeric string")
+		}
+		if _, err := opts.GetIntE("quiet"); err == nil {
+			t.Error("expected an error for a bool value")
+		}
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		if v, ok := opts.GetBool("quiet"); !ok || !v {
+			t.Errorf("expected true, got %v %v", v, ok)
+		}
+		if v, ok := opts.GetBool("quietStr"); !ok || v {
+			t.Errorf("expected false, got %v %v", v, ok)
+		}
+		if _, ok := opts.GetBool("missing"); ok {
+			t.Error("expected missing option to not be found")
+		}
+		if _, err := opts.GetBoolE("missing"); !errors.Is(err, ErrOptionNotSet) {
+			t.Errorf("expected ErrOptionNotSet, got %v", err)
+		}
+		if _, err := opts.GetBoolE("bad"); err == nil {
+			t.Error("expected an error for an invalid bool string")
+		}
+		if _, err := opts.GetBoolE("count"); err == nil {
+			t.Error("expected an error for an int value")
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		if v, ok := opts.GetDuration("timeout"); !ok || v != 10*time.Second {
+			t.Errorf("expected 10s, got %v %v", v, ok)
+		}
+		if v, ok := opts.GetDuration("timeoutSt"); !ok || v != 90*time.Second {
+			t.Errorf("expected 1m30s, got %v %v", v, ok)
+		}
+		if _, ok := opts.GetDuration("missing"); ok {
+			t.Error("expected missing option to not be found")
+		}
+		if _, err := opts.GetDurationE("missing"); !errors.Is(err, ErrOptionNotSet) {
+			t.Errorf("expected ErrOptionNotSet, got %v", err)
+		}
+		if _, err := opts.GetDurationE("bad"); err == nil {
+			t.Error("expected an error for an invalid duration string")
+		}
+		if _, err := opts.GetDurationE("count"); err == nil {
+			t.Error("expected an error for an int value")
+		}
+	})
+}
+
+func TestOptMapStringMap(t *testing.T) {
+	opts := OptMap{
+		"label": map[string]string{"k": "v"},
+		"name":  "bob",
+	}
+
+	if v, ok := opts.GetStringMap("label"); !ok || v["k"] != "v" {
+		t.Errorf("expected map with k=v, got %v %v", v, ok)
+	}
+	if _, err := opts.GetStringMapE("missing"); !errors.Is(err, ErrOptionNotSet) {
+		t.Errorf("expected ErrOptionNotSet, got %v", err)
+	}
+	if _, err := opts.GetStringMapE("name"); err == nil {
+		t.Error("expected an error for a string value")
+	}
+}
+
+func TestOptMapStringSlice(t *testing.T) {
+	opts := OptMap{
+		"header": []string{"A", "B"},
+		"name":   "bob",
+	}
+
+	if v, ok := opts.GetStringSlice("header"); !ok || !reflect.DeepEqual(v, []string{"A", "B"}) {
+		t.Errorf("expected [A B], got %v %v", v, ok)
+	}
+	if _, err := opts.GetStringSliceE("missing"); !errors.Is(err, ErrOptionNotSet) {
+		t.Errorf("expected ErrOptionNotSet, got %v", err)
+	}
+	if _, err := opts.GetStringSliceE("name"); err == nil {
+		t.Error("expected an error for a string value")
+	}
+}
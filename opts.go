@@ -16,13 +16,19 @@ const (
 	HiddenShort  = "H"
 	Ignore       = "ignore"
 	IgnoreRules  = "ignore-rules-path"
+	DryRunOpt    = "dry-run"
+	LimitOpt     = "limit"
+	OffsetOpt    = "offset"
 )
 
 // options that are used by this package
-var OptionEncodingType = StringOption(EncLong, EncShort, "The encoding type the output should be encoded with (json, xml, or text)").WithDefault("text")
+var OptionEncodingType = StringOption(EncLong, EncShort, "The encoding type the output should be encoded with (json, xml, text, table, or csv)").WithDefault("text")
 var OptionRecursivePath = BoolOption(RecLong, RecShort, "Add directory paths recursively")
 var OptionStreamChannels = BoolOption(ChanOpt, "Stream channel output")
-var OptionTimeout = StringOption(TimeoutOpt, "Set a global timeout on the command")
+var OptionDryRun = BoolOption(DryRunOpt, "Show what the command would do without doing it")
+var OptionLimit = IntOption(LimitOpt, "Output at most this many results")
+var OptionOffset = IntOption(OffsetOpt, "Skip this many results before the output")
+var OptionTimeout = DurationOption(TimeoutOpt, "Set a global timeout on the command")
 var OptionDerefArgs = BoolOption(DerefLong, "Symlinks supplied in arguments are dereferenced")
 var OptionStdinName = StringOption(StdinName, "Assign a name if the file source is stdin.")
 var OptionHidden = BoolOption(Hidden, HiddenShort, "Include files that are hidden. Only takes effect on recursive add.")
@@ -0,0 +1,92 @@
+package cmds
+
+import (
+	"reflect"
+)
+
+// paginate wraps the Run function of a Paginated command to page what it
+// emits according to the --limit and --offset options.
+//
+// A slice emitted as a Single value, i.e. the whole result at once, is paged
+// by its elements. Other values are paged as a stream: the first offset values
+// are dropped, and the emitter is closed once limit values went through, so
+// further calls to Emit fail and Run should return.
+func paginate(next Function) Function {
+	return func(req *Request, re ResponseEmitter, env Environment) error {
+		offset, err := pagingOption(req, OffsetOpt)
+		if err != nil {
+			return err
+		}
+		limit, err := pagingOption(req, LimitOpt)
+		if err != nil {
+			return err
+		}
+		if _, ok := req.Options[LimitOpt]; !ok {
+			limit = -1
+		}
+		if offset == 0 && limit < 0 {
+			return next(req, re, env)
+		}
+		return next(req, &pagingEmitter{ResponseEmitter: re, skip: offset, left: limit}, env)
+	}
+}
+
+// pagingOption returns the value of a paging option, 0 if it's not set.
+func pagingOption(req *Request, name string) (int, error) {
+	n, err := req.Options.GetIntE(name)
+	switch {
+	case err == ErrOptionNotSet:
+		return 0, nil
+	case err != nil:
+		return 0, Errorf(ErrClient, "%s", err)
+	case n < 0:
+		return 0, Errorf(ErrClient, "--%s must not be negative, got %d", name, n)
+	}
+	return n, nil
+}
+
+type pagingEmitter struct {
+	ResponseEmitter
+
+	// skip is the number of values still to be dropped, left the number of
+	// values still to be emitted or -1 for no limit
+	skip, left int
+}
+
+func (re *pagingEmitter) Emit(value interface{}) error {
+	if single, ok := value.(Single); ok {
+		if v := reflect.ValueOf(single.Value); v.Kind() == reflect.Slice {
+			return re.ResponseEmitter.Emit(Single{re.page(v).Interface()})
+		}
+	}
+
+	if re.left == 0 {
+		// --limit=0 leaves the emitter open until the first value
+		re.ResponseEmitter.Close()
+		return ErrClosedEmitter
+	}
+	if re.skip > 0 {
+		re.skip--
+		return nil
+	}
+	if err := re.ResponseEmitter.Emit(value); err != nil {
+		return err
+	}
+	if re.left > 0 {
+		re.left--
+		if re.left == 0 {
+			return re.ResponseEmitter.Close()
+		}
+	}
+	return nil
+}
+
+// page returns the elements of the slice v on the page.
+func (re *pagingEmitter) page(v reflect.Value) reflect.Value {
+	start := min(re.skip, v.Len())
+	end := v.Len()
+	if re.left >= 0 {
+		end = min(start+re.left, end)
+	}
+	return v.Slice(start, end)
+}
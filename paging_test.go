@@ -0,0 +1,89 @@
+package cmds
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPaginated(t *testing.T) {
+	root := &Command{
+		Options: []Option{OptionLimit, OptionOffset},
+		Subcommands: map[string]*Command{
+			"stream": {
+				Paginated: true,
+				Run: func(req *Request, re ResponseEmitter, env Environment) error {
+					for i := 0; i < 10; i++ {
+						if err := re.Emit(i); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+			"list": {
+				Paginated: true,
+				Run: func(req *Request, re ResponseEmitter, env Environment) error {
+					return EmitOnce(re, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+				},
+			},
+			"unpaged": {
+				Run: func(req *Request, re ResponseEmitter, env Environment) error {
+					return re.Emit(0)
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		path     string
+		opts     OptMap
+		expected []interface{}
+	}{
+		{path: "stream", opts: OptMap{}, expected: []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{path: "stream", opts: OptMap{OffsetOpt: 7}, expected: []interface{}{7, 8, 9}},
+		{path: "stream", opts: OptMap{LimitOpt: 3}, expected: []interface{}{0, 1, 2}},
+		{path: "stream", opts: OptMap{OffsetOpt: 4, LimitOpt: 2}, expected: []interface{}{4, 5}},
+		{path: "stream", opts: OptMap{OffsetOpt: 8, LimitOpt: 5}, expected: []interface{}{8, 9}},
+		{path: "stream", opts: OptMap{LimitOpt: 0}, expected: nil},
+		{path: "list", opts: OptMap{OffsetOpt: 4, LimitOpt: 2}, expected: []interface{}{[]int{4, 5}}},
+		{path: "list", opts: OptMap{OffsetOpt: 20}, expected: []interface{}{[]int{}}},
+		{path: "unpaged", opts: OptMap{LimitOpt: 0}, expected: []interface{}{0}},
+	} {
+		req, err := NewRequest(context.Background(), []string{tc.path}, tc.opts, nil, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		re, res := NewChanResponsePair(req)
+		done := make(chan []interface{})
+		go func() {
+			var values []interface{}
+			for {
+				v, err := res.Next()
+				if err != nil {
+					done <- values
+					return
+				}
+				values = append(values, v)
+			}
+		}()
+		if err := NewExecutor(root).Execute(req, re, nil); err != nil {
+			t.Errorf("%s %v: %s", tc.path, tc.opts, err)
+		}
+		if values := <-done; !reflect.DeepEqual(values, tc.expected) {
+			t.Errorf("%s %v: expected %v, got %v", tc.path, tc.opts, tc.expected, values)
+		}
+	}
+
+	req, err := NewRequest(context.Background(), []string{"stream"}, OptMap{OffsetOpt: -1}, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, res := NewChanResponsePair(req)
+	if err := NewExecutor(root).Execute(req, re, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.Next(); err == nil || err.Error() != "--offset must not be negative, got -1" {
+		t.Errorf("expected a negative offset error, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// progressBarWidth is the number of cells in a rendered progress bar.
+const progressBarWidth = 30
+
+// Progress can be emitted by commands to report the progress of a long
+// running operation, e.g. an upload. A Total of zero means the total is
+// unknown.
+//
+// The text encoder renders progress as a bar that is updated in place when
+// writing to a terminal, and as one line per update otherwise. The JSON
+// encoder writes each update as an object with Type "progress", so it can be
+// told apart from the results of the command.
+type Progress struct {
+	Current int64
+	Total   int64
+	Message string
+}
+
+// Done returns whether the operation has finished.
+func (p *Progress) Done() bool {
+	return p.Total > 0 && p.Current >= p.Total
+}
+
+func (p *Progress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Current int64
+		Total   int64
+		Message string
+		Type    string
+	}{
+		Current: p.Current,
+		Total:   p.Total,
+		Message: p.Message,
+		Type:    "progress",
+	})
+}
+
+// bar renders p as a progress bar, e.g. "[=====>    ]  50% message".
+func (p *Progress) bar() string {
+	if p.Total <= 0 {
+		return strings.TrimSpace(fmt.Sprintf("%d %s", p.Current, p.Message))
+	}
+
+	current := p.Current
+	if current > p.Total {
+		current = p.Total
+	}
+	filled := int(current * progressBarWidth / p.Total)
+	cells := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		cells += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return strings.TrimSpace(fmt.Sprintf("[%s] %3d%% %s", cells, current*100/p.Total, p.Message))
+}
+
+// line renders p as a plain line of text, for output that isn't a terminal.
+func (p *Progress) line() string {
+	if p.Total <= 0 {
+		return strings.TrimSpace(fmt.Sprintf("%d %s", p.Current, p.Message))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%d/%d %s", p.Current, p.Total, p.Message))
+}
+
+// writeProgress writes p to w, redrawing the current line of a terminal.
+func writeProgress(w io.Writer, p *Progress) error {
+	if !isTerminal(w) {
+		_, err := fmt.Fprintln(w, p.line())
+		return err
+	}
+
+	end := ""
+	if p.Done() {
+		end = "\n"
+	}
+	// clear the rest of the line, the previous update may have been longer
+	_, err := fmt.Fprintf(w, "\r%s\033[K%s", p.bar(), end)
+	return err
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
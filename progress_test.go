@@ -0,0 +1,59 @@
+package cmds
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	for _, tc := range []struct {
+		p        Progress
+		expected string
+	}{
+		{Progress{Current: 0, Total: 10, Message: "adding"}, "[>                             ]   0% adding"},
+		{Progress{Current: 5, Total: 10}, "[===============>              ]  50%"},
+		{Progress{Current: 12, Total: 10}, "[==============================] 100%"},
+		{Progress{Current: 1234, Message: "bytes"}, "1234 bytes"},
+	} {
+		if bar := tc.p.bar(); bar != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, bar)
+		}
+	}
+}
+
+func TestProgressEncoding(t *testing.T) {
+	req := &Request{}
+	values := []interface{}{&Progress{Current: 1, Total: 2, Message: "adding"}, "result"}
+
+	var text bytes.Buffer
+	enc := Encoders[TextNewline](req)(&text)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := "1/2 adding\nresult\n"; text.String() != expected {
+		t.Errorf("expected text %q, got %q", expected, text.String())
+	}
+
+	var js bytes.Buffer
+	enc = Encoders[JSON](req)(&js)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := json.NewDecoder(&js)
+	var progress map[string]interface{}
+	if err := dec.Decode(&progress); err != nil {
+		t.Fatal(err)
+	}
+	if progress["Type"] != "progress" || progress["Current"] != float64(1) || progress["Total"] != float64(2) {
+		t.Errorf("unexpected progress object %v", progress)
+	}
+	var result string
+	if err := dec.Decode(&result); err != nil || result != "result" {
+		t.Errorf("expected the result to be unchanged, got %q %v", result, err)
+	}
+}
@@ -37,7 +37,7 @@ func (rl *ReqLog) Add(req *Request) *ReqLogEntry {
 		StartTime: time.Now(),
 		Active:    true,
 		Command:   strings.Join(req.Path, "/"),
-		Options:   req.Options,
+		Options:   maskedOptions(req),
 		Args:      req.Arguments,
 		ID:        rl.nextID,
 	}
@@ -46,6 +46,35 @@ func (rl *ReqLog) Add(req *Request) *ReqLogEntry {
 	return rle
 }
 
+// maskedOptions returns the options of req with the values of secret options
+// replaced by SecretMask. The options of req are left as they are.
+func maskedOptions(req *Request) map[string]interface{} {
+	if req.Root == nil {
+		return req.Options
+	}
+	optDefs, err := req.Root.GetOptions(req.Path)
+	if err != nil {
+		return req.Options
+	}
+
+	var masked map[string]interface{}
+	for name := range req.Options {
+		if opt, ok := optDefs[name]; ok && Details(opt).Secret() {
+			if masked == nil {
+				masked = make(map[string]interface{}, len(req.Options))
+				for k, v := range req.Options {
+					masked[k] = v
+				}
+			}
+			masked[name] = SecretMask
+		}
+	}
+	if masked == nil {
+		return req.Options
+	}
+	return masked
+}
+
 // AddEntry adds an entry to the log.
 func (rl *ReqLog) AddEntry(rle *ReqLogEntry) {
 	rl.lock.Lock()
@@ -36,3 +36,30 @@ func TestReqLog(t *testing.T) {
 	}
 
 }
+
+func TestReqLogSecret(t *testing.T) {
+	root := &Command{
+		Options: []Option{
+			StringOption("password", "the password").WithSecret(true),
+			StringOption("user", "the user name"),
+		},
+	}
+	req := &Request{
+		Root:    root,
+		Command: root,
+		Options: OptMap{"password": "hunter2", "user": "alice"},
+	}
+
+	rle := (&ReqLog{}).Add(req)
+	if rle.Options["password"] != SecretMask {
+		t.Errorf("expected the password to be masked, got %v", rle.Options["password"])
+	}
+	if rle.Options["user"] != "alice" {
+		t.Errorf("expected the user to be logged, got %v", rle.Options["user"])
+	}
+
+	// the request still has the value
+	if v, _ := req.Options["password"].(string); v != "hunter2" {
+		t.Errorf("expected the request to keep the password, got %v", req.Options["password"])
+	}
+}
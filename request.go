@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/ipfs/boxo/files"
 )
@@ -17,6 +18,14 @@ type Request struct {
 	Arguments []string
 	Options   OptMap
 
+	// ExtraOptions are the unknown options passed on the command line to a
+	// command that allows them, as they were given. The value of an unknown
+	// option is only included if it's attached with "=".
+	ExtraOptions []string
+
+	// DryRun is set before the command is run when the dry-run option is set.
+	DryRun bool
+
 	Files files.Directory
 
 	bodyArgs *arguments
@@ -79,6 +88,147 @@ func (req *Request) ParseBodyArgs() error {
 	return s.Err()
 }
 
+// GetArgument returns the value of the string argument called name, as
+// declared in the command's Arguments. For a variadic argument it returns the
+// first value. The second return value is false if the argument wasn't given.
+func (req *Request) GetArgument(name string) (string, bool) {
+	values := req.GetArguments(name)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// GetIntArgument returns the value of the argument called name converted to an
+// int. It returns ErrArgumentNotSet if the argument wasn't given.
+func (req *Request) GetIntArgument(name string) (int, error) {
+	v, err := req.getTypedArgument(name, Int)
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+// GetBoolArgument returns the value of the argument called name converted to a
+// bool. It returns ErrArgumentNotSet if the argument wasn't given.
+func (req *Request) GetBoolArgument(name string) (bool, error) {
+	v, err := req.getTypedArgument(name, Bool)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+func (req *Request) getTypedArgument(name string, t reflect.Kind) (interface{}, error) {
+	for i, argDef := range req.bindArguments() {
+		if argDef.Name == name {
+			argDef.ValueType = t
+			return convertArgument(argDef, i+1, req.Arguments[i])
+		}
+	}
+	return nil, ErrArgumentNotSet
+}
+
+// GetArguments returns all values of the string argument called name. Only a
+// variadic argument can have more than one value.
+func (req *Request) GetArguments(name string) []string {
+	var values []string
+	for i, argDef := range req.bindArguments() {
+		if argDef.Name == name {
+			values = append(values, req.Arguments[i])
+		}
+	}
+	return values
+}
+
+// bindArguments returns the definitions of the string arguments the values in
+// req.Arguments are bound to, in order. Values left over once all arguments
+// are bound aren't included.
+func (req *Request) bindArguments() []Argument {
+	if req.Command == nil {
+		return nil
+	}
+
+	// file arguments end up in req.Files, so only string arguments take
+	// part in binding req.Arguments
+	var argDefs []Argument
+	for _, argDef := range req.Command.Arguments {
+		if argDef.Type == ArgString {
+			argDefs = append(argDefs, argDef)
+		}
+	}
+	if len(argDefs) == 0 {
+		return nil
+	}
+
+	var remRequired int
+	for _, argDef := range argDefs {
+		if argDef.Required {
+			remRequired++
+		}
+	}
+
+	// bind the values the same way the parser does: optional arguments are
+	// skipped while there are only enough values left for the required ones
+	var bound []Argument
+	iArgDef := 0
+	for i := range req.Arguments {
+		for iArgDef < len(argDefs)-1 && len(req.Arguments)-i <= remRequired && !argDefs[iArgDef].Required {
+			iArgDef++
+		}
+
+		argDef := argDefs[len(argDefs)-1]
+		if iArgDef < len(argDefs) {
+			argDef = argDefs[iArgDef]
+		} else if !argDef.Variadic {
+			break
+		}
+		if argDef.Required && iArgDef < len(argDefs) {
+			remRequired--
+		}
+
+		bound = append(bound, argDef)
+		iArgDef++
+	}
+	return bound
+}
+
+// Clone returns a copy of req that can be changed without affecting req. The
+// options, extra options, arguments and path are copied, including the slices and maps of
+// multi-valued options. The context, the commands and the files are shared.
+func (req *Request) Clone() *Request {
+	clone := *req
+	clone.Path = cloneStrings(req.Path)
+	clone.Arguments = cloneStrings(req.Arguments)
+	clone.ExtraOptions = cloneStrings(req.ExtraOptions)
+	if req.Options != nil {
+		clone.Options = make(OptMap, len(req.Options))
+		for name, v := range req.Options {
+			switch v := v.(type) {
+			case []string:
+				clone.Options[name] = cloneStrings(v)
+			case map[string]string:
+				m := make(map[string]string, len(v))
+				for k, s := range v {
+					m[k] = s
+				}
+				clone.Options[name] = m
+			default:
+				clone.Options[name] = v
+			}
+		}
+	}
+	return &clone
+}
+
+// cloneStrings copies s, keeping nil as nil.
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
+
 // SetOption sets a request option.
 func (req *Request) SetOption(name string, value interface{}) {
 	optDefs, err := req.Root.GetOptions(req.Path)
@@ -99,6 +249,53 @@ func (req *Request) SetOption(name string, value interface{}) {
 	req.Options[name] = value
 }
 
+// WithValue stores val under key in the context of req, for example for a
+// PreRunHook or a middleware to pass the authenticated user on to Run. As with
+// context.WithValue, key should be of an unexported type of the package
+// defining it, or a Key, so it can't collide with the keys of other packages.
+func (req *Request) WithValue(key, val interface{}) {
+	if req.Context == nil {
+		req.Context = context.Background()
+	}
+	req.Context = context.WithValue(req.Context, key, val)
+}
+
+// Value returns the value stored under key in the context of req, or nil.
+func (req *Request) Value(key interface{}) interface{} {
+	if req.Context == nil {
+		return nil
+	}
+	return req.Context.Value(key)
+}
+
+// Key is a key for request values of type T. Every Key created by NewKey is
+// distinct, even if the names are the same.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new key for request values of type T. The name is only
+// used for debugging.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// Set stores v under k in the context of req.
+func (k *Key[T]) Set(req *Request, v T) {
+	req.WithValue(k, v)
+}
+
+// Get returns the value stored under k in the context of req, and whether
+// there is one.
+func (k *Key[T]) Get(req *Request) (T, bool) {
+	v, ok := req.Value(k).(T)
+	return v, ok
+}
+
+func (k *Key[T]) String() string {
+	return "cmds.Key(" + k.name + ")"
+}
+
 func checkAndConvertOptions(root *Command, opts OptMap, path []string) (OptMap, error) {
 	optDefs, err := root.GetOptions(path)
 	options := make(OptMap)
@@ -117,7 +314,20 @@ func checkAndConvertOptions(root *Command, opts OptMap, path []string) (OptMap,
 		}
 
 		kind := reflect.TypeOf(v).Kind()
-		if kind != opt.Type() {
+		if str, ok := v.(string); ok && isDuration(opt) {
+			val, err := opt.Parse(str)
+			if err != nil {
+				return options, err
+			}
+			options[k] = val
+		} else if str, ok := v.(string); ok && opt.Type() == String {
+			// values passed as strings still have to be normalized
+			val, err := Details(opt).Normalize(str)
+			if err != nil {
+				return options, err
+			}
+			options[k] = val
+		} else if _, ok := v.(time.Duration); kind != opt.Type() && !(ok && isDuration(opt)) {
 			if opt.Type() == Strings {
 				if _, ok := v.([]string); !ok {
 					return options, fmt.Errorf("option %q should be type %q, but got type %q",
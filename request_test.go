@@ -0,0 +1,301 @@
+package cmds
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestGetArgument(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"cp": {
+				Arguments: []Argument{
+					StringArg("src", true, false, "source"),
+					StringArg("mode", false, false, "mode"),
+					StringArg("dst", true, true, "destinations"),
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		args []string
+		src  string
+		mode string
+		dst  []string
+	}{
+		{args: []string{"a", "b"}, src: "a", dst: []string{"b"}},
+		{args: []string{"a", "0644", "b", "c"}, src: "a", mode: "0644", dst: []string{"b", "c"}},
+	} {
+		req, err := NewRequest(context.Background(), []string{"cp"}, nil, tc.args, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v, ok := req.GetArgument("src"); !ok || v != tc.src {
+			t.Errorf("%v: expected src %q, got %q %v", tc.args, tc.src, v, ok)
+		}
+		if v, ok := req.GetArgument("mode"); ok != (tc.mode != "") || v != tc.mode {
+			t.Errorf("%v: expected mode %q, got %q %v", tc.args, tc.mode, v, ok)
+		}
+		if v := req.GetArguments("dst"); !reflect.DeepEqual(v, tc.dst) {
+			t.Errorf("%v: expected dst %v, got %v", tc.args, tc.dst, v)
+		}
+		if _, ok := req.GetArgument("nope"); ok {
+			t.Errorf("%v: expected unknown argument to not be found", tc.args)
+		}
+	}
+}
+
+func TestTypedArguments(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"head": {
+				Arguments: []Argument{
+					StringArg("count", true, false, "number of lines").WithValueType(Int),
+					StringArg("file", false, false, "file to read"),
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		args  []string
+		count int
+		file  string
+		err   string
+	}{
+		{args: []string{"10"}, count: 10},
+		{args: []string{"0x10", "log.txt"}, count: 16, file: "log.txt"},
+		{args: []string{"ten"}, err: `error in argument 1 (count): "ten" is not an integer`},
+		{args: []string{"1", "log.txt", "extra"}, count: 1, file: "log.txt"},
+	} {
+		req, err := NewRequest(context.Background(), []string{"head"}, nil, tc.args, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = req.Command.CheckArguments(req)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%v: expected error %q, got %v", tc.args, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %s", tc.args, err)
+		}
+
+		if v, err := req.GetIntArgument("count"); err != nil || v != tc.count {
+			t.Errorf("%v: expected count %d, got %d %v", tc.args, tc.count, v, err)
+		}
+		if v, _ := req.GetArgument("file"); v != tc.file {
+			t.Errorf("%v: expected file %q, got %q", tc.args, tc.file, v)
+		}
+	}
+
+	req, err := NewRequest(context.Background(), []string{"head"}, nil, []string{"12"}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := req.GetIntArgument("file"); !errors.Is(err, ErrArgumentNotSet) {
+		t.Errorf("expected ErrArgumentNotSet, got %v", err)
+	}
+	if _, err := req.GetBoolArgument("count"); err == nil {
+		t.Error("expected an error converting 12 to bool")
+	}
+}
+
+func TestVariadicMinCount(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"add": {
+				Arguments: []Argument{
+					StringArg("dest", true, false, "destination"),
+					StringArg("files", true, true, "files to add").WithMinCount(2),
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"d", "a", "b"}},
+		{args: []string{"d", "a", "b", "c"}},
+		{args: []string{"d", "a"}, err: "expected at least 2 arguments for <files>, got 1"},
+	} {
+		req, err := NewRequest(context.Background(), []string{"add"}, nil, tc.args, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = req.Command.CheckArguments(req)
+		if tc.err == "" && err != nil {
+			t.Errorf("%v: unexpected error: %s", tc.args, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("%v: expected error %q, got %v", tc.args, tc.err, err)
+		}
+	}
+}
+
+func TestArgError(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"cut": {
+				Arguments: []Argument{
+					StringArg("file", true, false, "file to read"),
+					StringArg("count", true, false, "number of fields").WithValueType(Int),
+					StringArg("verbose", false, false, "print more").WithValueType(Bool),
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		args []string
+		err  ArgError
+		msg  string
+	}{
+		{
+			args: []string{"log.txt", "abc"},
+			err:  ArgError{Name: "count", Position: 2, Value: "abc", Type: Int, Err: strconv.ErrSyntax},
+			msg:  `error in argument 2 (count): "abc" is not an integer`,
+		},
+		{
+			args: []string{"log.txt", "99999999999999999999"},
+			err:  ArgError{Name: "count", Position: 2, Value: "99999999999999999999", Type: Int, Err: strconv.ErrRange},
+			msg:  `error in argument 2 (count): "99999999999999999999" is out of range`,
+		},
+		{
+			args: []string{"log.txt", "3", "maybe"},
+			err:  ArgError{Name: "verbose", Position: 3, Value: "maybe", Type: Bool, Err: strconv.ErrSyntax},
+			msg:  `error in argument 3 (verbose): "maybe" is not a boolean`,
+		},
+	} {
+		req, err := NewRequest(context.Background(), []string{"cut"}, nil, tc.args, nil, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = req.Command.CheckArguments(req)
+		var argErr *ArgError
+		if !errors.As(err, &argErr) {
+			t.Fatalf("%v: expected an ArgError, got %v", tc.args, err)
+		}
+		if *argErr != tc.err {
+			t.Errorf("%v: expected %+v, got %+v", tc.args, tc.err, *argErr)
+		}
+		if !errors.Is(err, tc.err.Err) {
+			t.Errorf("%v: expected the error to wrap %v", tc.args, tc.err.Err)
+		}
+		if err.Error() != tc.msg {
+			t.Errorf("%v: expected message %q, got %q", tc.args, tc.msg, err)
+		}
+	}
+}
+
+func TestRequestClone(t *testing.T) {
+	root := &Command{
+		Options: []Option{
+			StringOption("name", "a name"),
+			StringsOption("tag", "tags"),
+			StringMapOption("header", "headers"),
+		},
+	}
+	opts := OptMap{
+		"name":   "a",
+		"tag":    []string{"x", "y"},
+		"header": map[string]string{"k": "v"},
+	}
+	req, err := NewRequest(context.Background(), nil, opts, []string{"arg"}, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := req.Clone()
+	if !reflect.DeepEqual(clone.Options, req.Options) || !reflect.DeepEqual(clone.Arguments, req.Arguments) {
+		t.Fatalf("expected the clone to equal the request, got %v %v", clone.Options, clone.Arguments)
+	}
+	if clone.Context != req.Context || clone.Root != req.Root {
+		t.Error("expected the clone to share the context and the commands")
+	}
+
+	clone.SetOption("name", "b")
+	clone.Options["tag"].([]string)[0] = "z"
+	clone.Options["header"].(map[string]string)["k"] = "w"
+	clone.Arguments[0] = "other"
+	delete(clone.Options, "tag")
+
+	expected := OptMap{
+		"name":   "a",
+		"tag":    []string{"x", "y"},
+		"header": map[string]string{"k": "v"},
+	}
+	for name, v := range expected {
+		if !reflect.DeepEqual(req.Options[name], v) {
+			t.Errorf("expected %s of the original to stay %v, got %v", name, v, req.Options[name])
+		}
+	}
+	if req.Arguments[0] != "arg" {
+		t.Errorf("expected the arguments of the original to stay, got %v", req.Arguments)
+	}
+}
+
+type userKey struct{}
+
+func TestRequestValues(t *testing.T) {
+	roleKey := NewKey[string]("role")
+	otherRoleKey := NewKey[string]("role")
+
+	var got []interface{}
+	root := &Command{
+		PreRunHook: func(req *Request) error {
+			roleKey.Set(req, "admin")
+			return nil
+		},
+		Subcommands: map[string]*Command{
+			"whoami": {
+				PreRun: func(req *Request, env Environment) error {
+					req.WithValue(userKey{}, "alice")
+					return nil
+				},
+				Run: func(req *Request, re ResponseEmitter, env Environment) error {
+					role, ok := roleKey.Get(req)
+					_, otherOk := otherRoleKey.Get(req)
+					got = append(got, req.Value(userKey{}), role, ok, otherOk, req.Value("missing"))
+					return nil
+				},
+			},
+		},
+	}
+
+	req, err := NewRequest(context.Background(), []string{"whoami"}, nil, nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, res := NewChanResponsePair(req)
+	if err := NewExecutor(root).Execute(req, re, nil); err != nil {
+		t.Fatal(err)
+	}
+	res.Next()
+
+	expected := []interface{}{"alice", "admin", true, false, nil}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	// requests without a context get one
+	var empty Request
+	empty.WithValue(userKey{}, "bob")
+	if v := empty.Value(userKey{}); v != "bob" {
+		t.Fatalf("expected bob, got %v", v)
+	}
+	if v := (&Request{}).Value(userKey{}); v != nil {
+		t.Fatalf("expected no value, got %v", v)
+	}
+}
@@ -9,6 +9,7 @@ import (
 var (
 	ErrClosedEmitter        = errors.New("cmds: emit on closed emitter")
 	ErrClosingClosedEmitter = errors.New("cmds: closing closed emitter")
+	ErrBufferLimit          = errors.New("cmds: buffer limit exceeded")
 )
 
 // Single can be used to signal to any ResponseEmitter that only one value will be emitted.
@@ -30,6 +31,39 @@ func EmitOnce(re ResponseEmitter, v interface{}) error {
 	return re.Emit(Single{v})
 }
 
+// EmitAndClose is like EmitOnce, but also closes re. Run should return right after it, the executor can't report
+// errors on a closed emitter.
+func EmitAndClose(re ResponseEmitter, v interface{}) error {
+	if err := EmitOnce(re, v); err != nil {
+		return err
+	}
+	// some emitters close themselves after a Single
+	if err := re.Close(); err != ErrClosingClosedEmitter {
+		return err
+	}
+	return nil
+}
+
+// ExpectOne reads the single value of res, the counterpart of EmitAndClose. It fails if res has no value or
+// more than one.
+func ExpectOne(res Response) (interface{}, error) {
+	v, err := res.Next()
+	if err == io.EOF {
+		return nil, errors.New("cmds: expected a single value, got none")
+	} else if err != nil {
+		return nil, err
+	}
+
+	switch _, err := res.Next(); err {
+	case io.EOF:
+		return v, nil
+	case nil:
+		return nil, errors.New("cmds: expected a single value, got more")
+	default:
+		return nil, err
+	}
+}
+
 // ResponseEmitter encodes and sends the command code's output to the client.
 // It is all a command can write to.
 type ResponseEmitter interface {
@@ -71,6 +105,118 @@ func Copy(re ResponseEmitter, res Response) error {
 	}
 }
 
+// TeeEmitter returns a ResponseEmitter that forwards every call to all of the
+// given emitters. Every emitter gets the call even if an earlier one fails; the
+// errors of all failing emitters are returned joined.
+//
+// Once an Emit failed, later calls to Emit do nothing and return nil, so a
+// command ignoring the error doesn't keep writing to a broken emitter. Close
+// and CloseWithError return the errors of the failed Emit along with their
+// own.
+func TeeEmitter(emitters ...ResponseEmitter) ResponseEmitter {
+	return &teeEmitter{emitters: emitters}
+}
+
+type teeEmitter struct {
+	emitters []ResponseEmitter
+
+	// emitErr is the error of the first failed Emit
+	emitErr error
+}
+
+func (t *teeEmitter) Close() error {
+	return errors.Join(t.emitErr, t.each(func(re ResponseEmitter) error { return re.Close() }))
+}
+
+func (t *teeEmitter) CloseWithError(err error) error {
+	return errors.Join(t.emitErr, t.each(func(re ResponseEmitter) error { return re.CloseWithError(err) }))
+}
+
+func (t *teeEmitter) SetLength(length uint64) {
+	for _, re := range t.emitters {
+		re.SetLength(length)
+	}
+}
+
+func (t *teeEmitter) Emit(value interface{}) error {
+	if t.emitErr != nil {
+		return nil
+	}
+	t.emitErr = t.each(func(re ResponseEmitter) error { return re.Emit(value) })
+	return t.emitErr
+}
+
+func (t *teeEmitter) each(fn func(ResponseEmitter) error) error {
+	var errs []error
+	for _, re := range t.emitters {
+		if err := fn(re); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FilterEmitter returns a ResponseEmitter that passes every emitted value
+// through fn before emitting it to next. fn returns the value to emit, which
+// may differ from the one it was given, and whether to emit it at all.
+func FilterEmitter(next ResponseEmitter, fn func(v interface{}) (interface{}, bool)) ResponseEmitter {
+	return &filterEmitter{ResponseEmitter: next, fn: fn}
+}
+
+type filterEmitter struct {
+	ResponseEmitter
+	fn func(v interface{}) (interface{}, bool)
+}
+
+func (re *filterEmitter) Emit(value interface{}) error {
+	value, keep := re.fn(value)
+	if !keep {
+		return nil
+	}
+	return re.ResponseEmitter.Emit(value)
+}
+
+// BufferedEmitter returns a ResponseEmitter that holds up to max emitted values
+// and emits them to next when it's closed. Emitting more than max values fails
+// with an error wrapping ErrBufferLimit; the values held so far are still
+// emitted on close.
+func BufferedEmitter(next ResponseEmitter, max int) ResponseEmitter {
+	return &bufferedEmitter{ResponseEmitter: next, max: max}
+}
+
+type bufferedEmitter struct {
+	ResponseEmitter
+	max    int
+	values []interface{}
+}
+
+func (re *bufferedEmitter) Emit(value interface{}) error {
+	if len(re.values) >= re.max {
+		return fmt.Errorf("%w: more than %d values", ErrBufferLimit, re.max)
+	}
+	re.values = append(re.values, value)
+	return nil
+}
+
+func (re *bufferedEmitter) Close() error {
+	return re.CloseWithError(nil)
+}
+
+func (re *bufferedEmitter) CloseWithError(err error) error {
+	values := re.values
+	re.values = nil
+	for _, v := range values {
+		if emitErr := re.ResponseEmitter.Emit(v); emitErr != nil {
+			re.ResponseEmitter.CloseWithError(emitErr)
+			return emitErr
+		}
+	}
+	if err == nil {
+		return re.ResponseEmitter.Close()
+	}
+	return re.ResponseEmitter.CloseWithError(err)
+}
+
 func EmitChan(re ResponseEmitter, ch <-chan interface{}) error {
 	for v := range ch {
 		err := re.Emit(v)
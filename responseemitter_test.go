@@ -2,8 +2,10 @@ package cmds
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"testing"
 )
 
@@ -164,3 +166,243 @@ func TestError(t *testing.T) {
 		}
 	}
 }
+
+func TestTeeEmitter(t *testing.T) {
+	a, b := &recordingEmitter{}, &recordingEmitter{}
+	re := TeeEmitter(a, b)
+	for _, v := range []interface{}{"x", 1, "y"} {
+		if err := re.Emit(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := re.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{"x", 1, "y"}
+	if !reflect.DeepEqual(a.values, expected) || !reflect.DeepEqual(b.values, expected) {
+		t.Errorf("expected both emitters to receive %v, got %v and %v", expected, a.values, b.values)
+	}
+	if !a.closed || !b.closed {
+		t.Error("expected both emitters to be closed")
+	}
+}
+
+func TestTeeEmitterError(t *testing.T) {
+	errFailed := errors.New("sink failed")
+	failing, ok := &recordingEmitter{err: errFailed}, &recordingEmitter{}
+	re := TeeEmitter(failing, ok)
+
+	if err := re.Emit("x"); !errors.Is(err, errFailed) {
+		t.Fatalf("expected %q, got %v", errFailed, err)
+	}
+	if !reflect.DeepEqual(ok.values, []interface{}{"x"}) {
+		t.Errorf("expected the working emitter to receive the value, got %v", ok.values)
+	}
+	if err := re.Close(); !errors.Is(err, errFailed) {
+		t.Fatalf("expected %q, got %v", errFailed, err)
+	}
+	if !ok.closed {
+		t.Error("expected the working emitter to be closed")
+	}
+}
+
+func TestTeeEmitterFirstError(t *testing.T) {
+	errFailed := errors.New("sink failed")
+	failing, ok := &recordingEmitter{}, &recordingEmitter{}
+	re := TeeEmitter(failing, ok)
+
+	if err := re.Emit("a"); err != nil {
+		t.Fatal(err)
+	}
+	// fail on the second value
+	failing.err = errFailed
+	if err := re.Emit("b"); !errors.Is(err, errFailed) {
+		t.Fatalf("expected %q, got %v", errFailed, err)
+	}
+	for _, v := range []interface{}{"c", "d"} {
+		if err := re.Emit(v); err != nil {
+			t.Errorf("expected emitting after an error to do nothing, got %v", err)
+		}
+	}
+	if expected := []interface{}{"a", "b"}; !reflect.DeepEqual(ok.values, expected) {
+		t.Errorf("expected %v to be emitted, got %v", expected, ok.values)
+	}
+
+	// the emit error is reported even though closing succeeds
+	failing.err = nil
+	if err := re.Close(); !errors.Is(err, errFailed) {
+		t.Fatalf("expected %q, got %v", errFailed, err)
+	}
+	if !failing.closed || !ok.closed {
+		t.Error("expected both emitters to be closed")
+	}
+}
+
+func TestFilterEmitter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fn       func(v interface{}) (interface{}, bool)
+		expected []interface{}
+	}{
+		{
+			name:     "pass-through",
+			fn:       func(v interface{}) (interface{}, bool) { return v, true },
+			expected: []interface{}{"a", "secret", "b"},
+		},
+		{
+			name: "transform",
+			fn: func(v interface{}) (interface{}, bool) {
+				if v == "secret" {
+					return "<redacted>", true
+				}
+				return v, true
+			},
+			expected: []interface{}{"a", "<redacted>", "b"},
+		},
+		{
+			name:     "drop",
+			fn:       func(v interface{}) (interface{}, bool) { return v, v != "secret" },
+			expected: []interface{}{"a", "b"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &recordingEmitter{}
+			re := FilterEmitter(next, tc.fn)
+			for _, v := range []interface{}{"a", "secret", "b"} {
+				if err := re.Emit(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := re.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(next.values, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, next.values)
+			}
+			if !next.closed {
+				t.Error("expected the emitter to be closed")
+			}
+		})
+	}
+}
+
+func TestBufferedEmitter(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		count int
+		err   bool
+	}{
+		{name: "under", count: 2},
+		{name: "at", count: 3},
+		{name: "over", count: 4, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &recordingEmitter{}
+			re := BufferedEmitter(next, 3)
+
+			var emitErr error
+			for i := 0; i < tc.count && emitErr == nil; i++ {
+				emitErr = re.Emit(i)
+				if emitErr == nil && len(next.values) != 0 {
+					t.Fatalf("expected values to be held until close, got %v", next.values)
+				}
+			}
+			if tc.err {
+				if !errors.Is(emitErr, ErrBufferLimit) {
+					t.Fatalf("expected a buffer limit error, got %v", emitErr)
+				}
+				if emitErr.Error() != "cmds: buffer limit exceeded: more than 3 values" {
+					t.Errorf("unexpected error message %q", emitErr)
+				}
+			} else if emitErr != nil {
+				t.Fatal(emitErr)
+			}
+
+			if err := re.CloseWithError(emitErr); err != nil {
+				t.Fatal(err)
+			}
+			expected := []interface{}{0, 1, 2}[:min(tc.count, 3)]
+			if !reflect.DeepEqual(next.values, expected) {
+				t.Errorf("expected %v, got %v", expected, next.values)
+			}
+			if !next.closed {
+				t.Error("expected the emitter to be closed")
+			}
+		})
+	}
+}
+
+func TestEmitAndCloseExpectOne(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		run    func(re ResponseEmitter) error
+		value  interface{}
+		errMsg string
+	}{
+		{
+			name:  "once",
+			run:   func(re ResponseEmitter) error { return EmitAndClose(re, "value") },
+			value: "value",
+		},
+		{
+			name:   "none",
+			run:    func(re ResponseEmitter) error { return re.Close() },
+			errMsg: "cmds: expected a single value, got none",
+		},
+		{
+			name: "many",
+			run: func(re ResponseEmitter) error {
+				if err := re.Emit("a"); err != nil {
+					return err
+				}
+				if err := re.Emit("b"); err != nil {
+					return err
+				}
+				return re.Close()
+			},
+			errMsg: "cmds: expected a single value, got more",
+		},
+		{
+			name:   "error",
+			run:    func(re ResponseEmitter) error { return re.CloseWithError(errors.New("failed")) },
+			errMsg: "failed",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := NewRequest(context.Background(), nil, nil, nil, nil, &Command{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			re, res := NewChanResponsePair(req)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- tc.run(re)
+			}()
+
+			v, err := ExpectOne(res)
+			if tc.errMsg != "" {
+				if err == nil || err.Error() != tc.errMsg {
+					t.Errorf("expected error %q, got %v", tc.errMsg, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if v != tc.value {
+				t.Errorf("expected %v, got %v", tc.value, v)
+			}
+
+			// ExpectOne stops reading after the second value
+			go func() {
+				for {
+					if _, err := res.Next(); err != nil {
+						return
+					}
+				}
+			}()
+			if err := <-errCh; err != nil {
+				t.Errorf("emitting failed: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package cmds
+
+import (
+	"sort"
+
+	levenshtein "github.com/texttheater/golang-levenshtein/levenshtein"
+)
+
+// DefaultSuggestDistance is the maximum edit distance between a mistyped
+// command name and the suggestions returned by SuggestCommands.
+const DefaultSuggestDistance = 2
+
+// SuggestCommands returns the names of the subcommands that are closest to
+// the last element of path, which is expected to not resolve to a command.
+// Subcommands are looked up on the command addressed by the rest of path.
+//
+// Only names within DefaultSuggestDistance edits are returned, sorted by
+// ascending distance and then by name. Hidden subcommands are never
+// suggested.
+func SuggestCommands(root *Command, path []string) []string {
+	return SuggestCommandsWithin(root, path, DefaultSuggestDistance)
+}
+
+// SuggestCommandsWithin is like SuggestCommands, but returns names within
+// maxDistance edits of the mistyped name.
+func SuggestCommandsWithin(root *Command, path []string, maxDistance int) []string {
+	if root == nil || len(path) == 0 {
+		return nil
+	}
+
+	parent, err := root.Get(path[:len(path)-1])
+	if err != nil {
+		return nil
+	}
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+
+	typed := []rune(path[len(path)-1])
+	var suggestions []suggestion
+	for name, sub := range parent.Subcommands {
+		if sub.Hidden {
+			continue
+		}
+		d := levenshtein.DistanceForStrings(typed, []rune(name), levenshtein.DefaultOptionsWithSub)
+		if d <= maxDistance {
+			suggestions = append(suggestions, suggestion{name, d})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].name < suggestions[j].name
+	})
+
+	names := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		names[i] = s.name
+	}
+	return names
+}
@@ -0,0 +1,47 @@
+package cmds
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestCommands(t *testing.T) {
+	root := &Command{
+		Subcommands: map[string]*Command{
+			"cat": {},
+			"cap": {},
+			"get": {},
+			"cut": {Hidden: true},
+			"config": {
+				Subcommands: map[string]*Command{
+					"show": {},
+					"edit": {},
+				},
+			},
+		},
+	}
+
+	type testcase struct {
+		path        []string
+		maxDistance int
+		expected    []string
+	}
+
+	for _, tc := range []testcase{
+		{path: []string{"clat"}, maxDistance: DefaultSuggestDistance, expected: []string{"cat", "cap"}},
+		{path: []string{"cta"}, maxDistance: DefaultSuggestDistance, expected: []string{"cap", "cat"}},
+		{path: []string{"config", "shwo"}, maxDistance: DefaultSuggestDistance, expected: []string{"show"}},
+		{path: []string{"xyzzy"}, maxDistance: DefaultSuggestDistance, expected: []string{}},
+		{path: []string{"gte"}, maxDistance: 1, expected: []string{}},
+		{path: []string{"nope", "show"}, maxDistance: DefaultSuggestDistance, expected: nil},
+	} {
+		got := SuggestCommandsWithin(root, tc.path, tc.maxDistance)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("path %v: expected %v, got %v", tc.path, tc.expected, got)
+		}
+	}
+
+	if got := SuggestCommands(root, []string{"clat"}); !reflect.DeepEqual(got, []string{"cat", "cap"}) {
+		t.Errorf("expected [cat cap], got %v", got)
+	}
+}
@@ -0,0 +1,171 @@
+package cmds
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// rowValues returns the items of v if it's a slice or an array, v otherwise.
+func rowValues(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{v}
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+// tableRow returns the column names and the cells of v, which must be a
+// struct, a map or a pointer to one. The columns of a struct are its exported
+// fields in order, the columns of a map its keys in sorted order.
+func tableRow(v interface{}) (columns, cells []string, err error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil, fmt.Errorf("cannot encode %T as a table row", v)
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() {
+				columns = append(columns, f.Name)
+				cells = append(cells, fmt.Sprintf("%v", rv.Field(i).Interface()))
+			}
+		}
+	case reflect.Map:
+		byKey := make(map[string]string, rv.Len())
+		for _, k := range rv.MapKeys() {
+			key := fmt.Sprintf("%v", k.Interface())
+			columns = append(columns, key)
+			byKey[key] = fmt.Sprintf("%v", rv.MapIndex(k).Interface())
+		}
+		sort.Strings(columns)
+		for _, key := range columns {
+			cells = append(cells, byKey[key])
+		}
+	default:
+		return nil, nil, fmt.Errorf("cannot encode %T as a table row", v)
+	}
+	return columns, cells, nil
+}
+
+// tableEncoder encodes structs and maps as the rows of a table with a column
+// per field or key. The header row names the columns. As the columns are
+// aligned to the widest cell, the rows are collected and written by Close.
+// The items of a slice are written as separate rows.
+type tableEncoder struct {
+	w       io.Writer
+	columns []string
+	known   map[string]bool
+	rows    []map[string]string
+}
+
+func (e *tableEncoder) Encode(v interface{}) error {
+	for _, item := range rowValues(v) {
+		columns, cells, err := tableRow(item)
+		if err != nil {
+			return err
+		}
+
+		row := make(map[string]string, len(columns))
+		for i, col := range columns {
+			e.addColumn(col)
+			row[col] = cells[i]
+		}
+		e.rows = append(e.rows, row)
+	}
+	return nil
+}
+
+func (e *tableEncoder) addColumn(name string) {
+	if e.known == nil {
+		e.known = make(map[string]bool)
+	}
+	if !e.known[name] {
+		e.known[name] = true
+		e.columns = append(e.columns, name)
+	}
+}
+
+// Close writes the table if any row has been encoded.
+func (e *tableEncoder) Close() error {
+	if len(e.rows) == 0 {
+		return nil
+	}
+
+	widths := make([]int, len(e.columns))
+	for i, col := range e.columns {
+		widths[i] = runewidth.StringWidth(col)
+		for _, row := range e.rows {
+			widths[i] = max(widths[i], runewidth.StringWidth(row[col]))
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(cell func(col string) string) {
+		var line strings.Builder
+		for i, col := range e.columns {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(runewidth.FillRight(cell(col), widths[i]))
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteString("\n")
+	}
+	writeRow(func(col string) string { return col })
+	for _, row := range e.rows {
+		writeRow(func(col string) string { return row[col] })
+	}
+
+	e.rows = nil
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+// csvEncoder encodes structs and maps as CSV records, preceded by a header
+// record with the columns of the first value. All values must have the same
+// columns. The items of a slice are written as separate records.
+type csvEncoder struct {
+	w      *csv.Writer
+	header []string
+}
+
+func (e *csvEncoder) Encode(v interface{}) error {
+	for _, item := range rowValues(v) {
+		columns, cells, err := tableRow(item)
+		if err != nil {
+			return err
+		}
+
+		if e.header == nil {
+			e.header = columns
+			if err := e.w.Write(columns); err != nil {
+				return err
+			}
+		} else if !slices.Equal(columns, e.header) {
+			return fmt.Errorf("cannot encode %T as CSV: columns %s don't match the header %s",
+				item, strings.Join(columns, ","), strings.Join(e.header, ","))
+		}
+		if err := e.w.Write(cells); err != nil {
+			return err
+		}
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}